@@ -0,0 +1,72 @@
+// gsadmin 远程控制台客户端
+//
+//	gsadmin -unix /var/run/game.sock module list
+//	gsadmin -addr 127.0.0.1:9999 -token xxx
+//
+// 带命令参数时执行一条命令后退出，否则从标准输入逐行读取命令
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/cmdline"
+)
+
+func main() {
+	unixPath := flag.String("unix", "", "admin unix socket path")
+	addr := flag.String("addr", "", "admin tcp address, e.g. 127.0.0.1:9999")
+	token := flag.String("token", os.Getenv("GSADMIN_TOKEN"), "admin token, default $GSADMIN_TOKEN")
+	timeout := flag.Duration("timeout", 5*time.Second, "dial timeout")
+	flag.Parse()
+
+	network, address := "unix", *unixPath
+	if *addr != "" {
+		network, address = "tcp", *addr
+	}
+	if address == "" {
+		fmt.Fprintln(os.Stderr, "one of -unix or -addr is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+	c, err := cmdline.DialAdmin(network, address, *token, *timeout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "connect failed:", err)
+		os.Exit(1)
+	}
+	defer c.Close()
+
+	if flag.NArg() > 0 {
+		if !run(c, strings.Join(flag.Args(), " ")) {
+			os.Exit(1)
+		}
+		return
+	}
+	scanner := bufio.NewScanner(os.Stdin)
+	fmt.Print("> ")
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "quit":
+			return
+		case "":
+		default:
+			run(c, line)
+		}
+		fmt.Print("> ")
+	}
+}
+
+func run(c *cmdline.AdminClient, line string) bool {
+	out, err := c.Exec(line)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return false
+	}
+	fmt.Print(out)
+	return true
+}
@@ -182,8 +182,10 @@ func (o *Object) ProcessSeqnum() {
 // If so, deallocate this object.
 func (o *Object) checkTermAcks() {
 	name := o.GetTreeName()
-	logger.Logger.Debugf("(%v) object checkTermAcks terminating=%v processedSeqnum=%v sentSeqnum=%v termAcks=%v ", name, o.terminating, o.processedSeqnum, o.sentSeqnum, o.termAcks)
-	if o.terminating && o.processedSeqnum == o.sentSeqnum && o.termAcks == 0 {
+	//sentSeqnum在发送命令的协程中递增
+	sentSeqnum := atomic.LoadUint32(&o.sentSeqnum)
+	logger.Logger.Debugf("(%v) object checkTermAcks terminating=%v processedSeqnum=%v sentSeqnum=%v termAcks=%v ", name, o.terminating, o.processedSeqnum, sentSeqnum, o.termAcks)
+	if o.terminating && o.processedSeqnum == sentSeqnum && o.termAcks == 0 {
 
 		//  Sanity check. There should be no active children at this point.
 
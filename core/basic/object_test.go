@@ -90,3 +90,31 @@ func TestSendCommandLoop(t *testing.T) {
 	}
 	fmt.Println("TestSendCommandLoop", slice, len(slice))
 }
+
+func TestProcessSeqnumWhileSending(t *testing.T) {
+	n := 100
+	opt := Options{
+		Interval: time.Second,
+		MaxDone:  n,
+	}
+	c := make(chan int, n)
+	o := NewObject(1, "test1", opt, nil)
+	o.Active()
+	//命令在对象协程中处理序号,发送方在各自协程中递增序号
+	for i := 0; i < n; i++ {
+		go func(tag int) {
+			o.SendCommand(CommandWrapper(func(oo *Object) error {
+				defer oo.ProcessSeqnum()
+				c <- tag
+				return nil
+			}), true)
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case <-c:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Command be droped")
+		}
+	}
+}
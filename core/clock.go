@@ -0,0 +1,136 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+var AppClock = NewClock()
+
+// Clock 框架时钟
+// 在真实时间的基础上支持偏移量和缩放，便于测试跨天、跨周等逻辑
+// 虚拟时间 = 锚点虚拟时间 + (当前真实时间 - 锚点真实时间) * 缩放系数 + 偏移量
+type Clock struct {
+	lock        sync.RWMutex
+	anchorReal  time.Time
+	anchorVirt  time.Time
+	offset      time.Duration
+	scale       float64
+	changedHook []func(old, now time.Time)
+}
+
+func NewClock() *Clock {
+	tNow := time.Now()
+	return &Clock{
+		anchorReal: tNow,
+		anchorVirt: tNow,
+		scale:      1,
+	}
+}
+
+func (c *Clock) now(real time.Time) time.Time {
+	if c.scale == 1 {
+		return c.anchorVirt.Add(real.Sub(c.anchorReal) + c.offset)
+	}
+	elapsed := time.Duration(float64(real.Sub(c.anchorReal)) * c.scale)
+	return c.anchorVirt.Add(elapsed + c.offset)
+}
+
+// Now 获取当前时间
+func (c *Clock) Now() time.Time {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.offset == 0 && c.scale == 1 && c.anchorReal.Equal(c.anchorVirt) {
+		return time.Now()
+	}
+	return c.now(time.Now())
+}
+
+// Since 与time.Since相同，基于框架时钟
+func (c *Clock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Offset 获取当前时间偏移量
+func (c *Clock) Offset() time.Duration {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.offset
+}
+
+// Scale 获取当前时间缩放系数
+func (c *Clock) Scale() float64 {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.scale
+}
+
+// SetOffset 设置时间偏移量
+func (c *Clock) SetOffset(offset time.Duration) {
+	c.lock.Lock()
+	old := c.now(time.Now())
+	c.offset = offset
+	tNow := c.now(time.Now())
+	hooks := c.changedHook
+	c.lock.Unlock()
+	c.fireChanged(hooks, old, tNow)
+}
+
+// AddOffset 在当前偏移量的基础上增加偏移，用于时间快进
+func (c *Clock) AddOffset(d time.Duration) {
+	c.lock.Lock()
+	old := c.now(time.Now())
+	c.offset += d
+	tNow := c.now(time.Now())
+	hooks := c.changedHook
+	c.lock.Unlock()
+	c.fireChanged(hooks, old, tNow)
+}
+
+// SetScale 设置时间缩放系数，scale必须大于0
+// 修改缩放系数不会导致时间跳变，只影响之后时间流逝的速度
+func (c *Clock) SetScale(scale float64) {
+	if scale <= 0 {
+		return
+	}
+	c.lock.Lock()
+	real := time.Now()
+	c.anchorVirt = c.now(real).Add(-c.offset)
+	c.anchorReal = real
+	c.scale = scale
+	c.lock.Unlock()
+}
+
+// Reset 恢复为真实时间
+func (c *Clock) Reset() {
+	c.lock.Lock()
+	real := time.Now()
+	old := c.now(real)
+	c.anchorReal = real
+	c.anchorVirt = real
+	c.offset = 0
+	c.scale = 1
+	hooks := c.changedHook
+	c.lock.Unlock()
+	c.fireChanged(hooks, old, real)
+}
+
+// RegisteChangedHook 注册时间跳变回调，在偏移量发生变化或者Reset时调用，向前和向后跳变都会调用
+// 回调在修改时钟的协程中执行
+func (c *Clock) RegisteChangedHook(f func(old, now time.Time)) {
+	c.lock.Lock()
+	c.changedHook = append(c.changedHook, f)
+	c.lock.Unlock()
+}
+
+func (c *Clock) fireChanged(hooks []func(old, now time.Time), old, now time.Time) {
+	for _, f := range hooks {
+		f(old, now)
+	}
+}
+
+// Now 获取框架当前时间，游戏逻辑的时间应该使用该函数替代time.Now()
+// 耗时统计、超时期限以及跨进程比较的时间受偏移和缩放影响，应该使用time.Now()
+func Now() time.Time {
+	return AppClock.Now()
+}
@@ -0,0 +1,40 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockOffset(t *testing.T) {
+	c := NewClock()
+	var jumped time.Duration
+	c.RegisteChangedHook(func(old, now time.Time) {
+		jumped = now.Sub(old)
+	})
+	c.AddOffset(24 * time.Hour)
+	if d := c.Now().Sub(time.Now()); d < 24*time.Hour-time.Second || d > 24*time.Hour+time.Second {
+		t.Fatalf("offset not applied, diff=%v", d)
+	}
+	if jumped < 24*time.Hour-time.Second {
+		t.Fatalf("changed hook not fired, jumped=%v", jumped)
+	}
+	c.Reset()
+	if d := c.Now().Sub(time.Now()); d > time.Second || d < -time.Second {
+		t.Fatalf("reset failed, diff=%v", d)
+	}
+}
+
+func TestClockScale(t *testing.T) {
+	c := NewClock()
+	c.SetOffset(time.Hour)
+	before := c.Now()
+	c.SetScale(100)
+	after := c.Now()
+	if after.Sub(before) > time.Second || after.Before(before) {
+		t.Fatal("time jumped when scale changed")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if d := c.Now().Sub(after); d < time.Second {
+		t.Fatalf("scale not applied, elapsed=%v", d)
+	}
+}
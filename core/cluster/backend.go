@@ -0,0 +1,87 @@
+package cluster
+
+import (
+	"fmt"
+	"sync"
+)
+
+var backends = make(map[string]BackendCreator)
+
+// Backend 服务注册的存储后端
+// Watch 启动监听，成员发生变化时以全量快照回调notify，回调可以在任意协程中发生
+type Backend interface {
+	Register(self *ServiceInfo) error
+	Heartbeat(self *ServiceInfo) error
+	Unregister(self *ServiceInfo) error
+	Watch(notify func(services []*ServiceInfo)) error
+	Close()
+}
+
+type BackendCreator func(c *Configuration) (Backend, error)
+
+func RegisteBackend(name string, creator BackendCreator) {
+	if _, exist := backends[name]; exist {
+		panic(fmt.Sprintf("repeate registe cluster backend:%v", name))
+	}
+	backends[name] = creator
+}
+
+func createBackend(c *Configuration) (Backend, error) {
+	creator, exist := backends[c.Backend]
+	if !exist {
+		return nil, fmt.Errorf("cluster backend [%v] not registed", c.Backend)
+	}
+	return creator(c)
+}
+
+// StaticBackend 静态配置的集群，成员不会变化，也不需要心跳
+type StaticBackend struct {
+	lock     sync.Mutex
+	services []*ServiceInfo
+	self     *ServiceInfo
+}
+
+func NewStaticBackend(services []ServiceInfo) *StaticBackend {
+	b := &StaticBackend{}
+	for i := 0; i < len(services); i++ {
+		si := services[i].clone()
+		si.LastHeartbeat = 0
+		b.services = append(b.services, si)
+	}
+	return b
+}
+
+func (b *StaticBackend) Register(self *ServiceInfo) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.self = self.clone()
+	b.self.LastHeartbeat = 0
+	return nil
+}
+
+func (b *StaticBackend) Heartbeat(self *ServiceInfo) error {
+	return nil
+}
+
+func (b *StaticBackend) Unregister(self *ServiceInfo) error {
+	return nil
+}
+
+func (b *StaticBackend) Watch(notify func(services []*ServiceInfo)) error {
+	b.lock.Lock()
+	services := append([]*ServiceInfo(nil), b.services...)
+	if b.self != nil {
+		services = append(services, b.self)
+	}
+	b.lock.Unlock()
+	notify(services)
+	return nil
+}
+
+func (b *StaticBackend) Close() {}
+
+func init() {
+	RegisteBackend(Backend_Static, func(c *Configuration) (Backend, error) {
+		return NewStaticBackend(c.Services), nil
+	})
+}
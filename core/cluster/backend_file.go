@@ -0,0 +1,164 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/fsnotify/fsnotify"
+)
+
+const fileBackendExt = ".svc"
+
+// FileBackend 基于共享目录的服务注册，每个节点写一个文件，心跳时刷新文件内容
+// 目录变化通过fsnotify通知，同时按心跳间隔轮询，兼容不支持文件通知的网络文件系统
+// Close之后可以重新Watch，支持模块在运行时停止后再启动
+type FileBackend struct {
+	dir      string
+	interval time.Duration
+	lock     sync.Mutex
+	watcher  *fsnotify.Watcher
+	quit     chan struct{}
+}
+
+func NewFileBackend(dir string, interval time.Duration) (*FileBackend, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	return &FileBackend{
+		dir:      dir,
+		interval: interval,
+	}, nil
+}
+
+func (b *FileBackend) fileName(si *ServiceInfo) string {
+	return filepath.Join(b.dir, si.Key()+fileBackendExt)
+}
+
+func (b *FileBackend) Register(self *ServiceInfo) error {
+	return b.Heartbeat(self)
+}
+
+// Heartbeat 先写临时文件再改名，读取方不会读到写了一半的文件
+func (b *FileBackend) Heartbeat(self *ServiceInfo) error {
+	data, err := json.Marshal(self)
+	if err != nil {
+		return err
+	}
+	name := b.fileName(self)
+	tmp := name + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+func (b *FileBackend) Unregister(self *ServiceInfo) error {
+	err := os.Remove(b.fileName(self))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (b *FileBackend) Watch(notify func(services []*ServiceInfo)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err = watcher.Add(b.dir); err != nil {
+		watcher.Close()
+		return err
+	}
+	quit := make(chan struct{})
+	b.lock.Lock()
+	if b.watcher != nil {
+		b.lock.Unlock()
+		watcher.Close()
+		return fmt.Errorf("cluster file backend %v already watching", b.dir)
+	}
+	b.watcher = watcher
+	b.quit = quit
+	b.lock.Unlock()
+	notify(b.load())
+	go b.watchRoutine(watcher, quit, notify)
+	return nil
+}
+
+func (b *FileBackend) watchRoutine(watcher *fsnotify.Watcher, quit chan struct{}, notify func(services []*ServiceInfo)) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if strings.HasSuffix(ev.Name, fileBackendExt) {
+				notify(b.load())
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Logger.Warnf("cluster file backend watch %v error: %v", b.dir, err)
+		case <-ticker.C:
+			notify(b.load())
+		case <-quit:
+			return
+		}
+	}
+}
+
+func (b *FileBackend) load() []*ServiceInfo {
+	files, err := filepath.Glob(filepath.Join(b.dir, "*"+fileBackendExt))
+	if err != nil {
+		logger.Logger.Warnf("cluster file backend load %v error: %v", b.dir, err)
+		return nil
+	}
+	var services []*ServiceInfo
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			//文件可能刚好被删除
+			continue
+		}
+		si := &ServiceInfo{}
+		if err = json.Unmarshal(data, si); err != nil {
+			logger.Logger.Warnf("cluster file backend parse %v error: %v", f, err)
+			continue
+		}
+		services = append(services, si)
+	}
+	return services
+}
+
+func (b *FileBackend) Close() {
+	b.lock.Lock()
+	watcher, quit := b.watcher, b.quit
+	b.watcher, b.quit = nil, nil
+	b.lock.Unlock()
+	if quit != nil {
+		close(quit)
+	}
+	if watcher != nil {
+		watcher.Close()
+	}
+}
+
+func init() {
+	RegisteBackend(Backend_File, func(c *Configuration) (Backend, error) {
+		if c.Dir == "" {
+			return nil, fmt.Errorf("cluster file backend need Dir")
+		}
+		return NewFileBackend(c.Dir, c.HeartbeatInterval)
+	})
+}
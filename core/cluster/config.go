@@ -0,0 +1,65 @@
+package cluster
+
+import (
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+)
+
+const (
+	Backend_Static = "static"
+	Backend_File   = "file"
+
+	DefaultHeartbeatInterval = 3 * time.Second
+)
+
+var Config = Configuration{}
+
+type Configuration struct {
+	//本节点的信息，Type为空表示只发现其它节点，不注册自己
+	Self ServiceInfo
+	//static或者file，默认为static
+	Backend string
+	//static后端的节点列表
+	Services []ServiceInfo
+	//file后端的共享目录
+	Dir string
+	//心跳间隔(毫秒)
+	HeartbeatInterval time.Duration
+	//超过该时间(毫秒)没有心跳认为节点已经下线，默认为3倍心跳间隔
+	TTL time.Duration
+}
+
+func (c *Configuration) Name() string {
+	return "cluster"
+}
+
+func (c *Configuration) Init() error {
+	if c.Backend == "" {
+		c.Backend = Backend_Static
+	}
+	if c.HeartbeatInterval <= 0 {
+		c.HeartbeatInterval = DefaultHeartbeatInterval
+	} else {
+		c.HeartbeatInterval = time.Millisecond * c.HeartbeatInterval
+	}
+	if c.TTL <= 0 {
+		c.TTL = 3 * c.HeartbeatInterval
+	} else {
+		c.TTL = time.Millisecond * c.TTL
+	}
+	backend, err := createBackend(c)
+	if err != nil {
+		return err
+	}
+	ClusterModule.Setup(c.Self, backend, c.HeartbeatInterval, c.TTL)
+	return nil
+}
+
+func (c *Configuration) Close() error {
+	return nil
+}
+
+func init() {
+	core.RegistePackage(&Config)
+}
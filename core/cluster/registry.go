@@ -0,0 +1,395 @@
+package cluster
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/acoderup/goserver.v1/core/module"
+	"github.com/stathat/consistent"
+)
+
+const (
+	MemberEvent_Join int = iota
+	MemberEvent_Leave
+	MemberEvent_Update
+)
+
+var (
+	ClusterModule = NewRegistry()
+
+	ErrNoService = errors.New("no service available")
+)
+
+// MemberEvent 成员变化事件
+type MemberEvent struct {
+	Type    int
+	Service *ServiceInfo
+}
+
+// MemberHandler 成员变化回调，在订阅者自己的Object中执行
+type MemberHandler func(e *MemberEvent)
+
+type subscriber struct {
+	obj     *basic.Object
+	svcType string
+	h       MemberHandler
+}
+
+// Registry 服务注册与发现，同时也是一个Module：Update中发送心跳并检查成员存活
+type Registry struct {
+	lock          sync.RWMutex
+	self          *ServiceInfo
+	backend       Backend
+	heartbeat     time.Duration
+	ttl           time.Duration
+	lastHeartbeat time.Time
+	snapshot      []*ServiceInfo
+	services      map[string]map[int]*ServiceInfo
+	rings         map[string]*consistent.Consistent
+	subscribers   []*subscriber
+	started       bool
+	//串行化成员计算和事件投递，保证订阅者按顺序收到事件
+	refreshLock sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		heartbeat: DefaultHeartbeatInterval,
+		ttl:       3 * DefaultHeartbeatInterval,
+		services:  make(map[string]map[int]*ServiceInfo),
+		rings:     make(map[string]*consistent.Consistent),
+	}
+}
+
+// Setup 设置本节点和存储后端，必须在Start之前调用
+func (this *Registry) Setup(self ServiceInfo, backend Backend, heartbeat, ttl time.Duration) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if self.Type != "" {
+		this.self = self.clone()
+	}
+	this.backend = backend
+	if heartbeat > 0 {
+		this.heartbeat = heartbeat
+	}
+	if ttl > 0 {
+		this.ttl = ttl
+	}
+}
+
+// Start 注册本节点并开始监听成员变化
+func (this *Registry) Start() error {
+	this.lock.Lock()
+	if this.started || this.backend == nil {
+		this.lock.Unlock()
+		return nil
+	}
+	this.started = true
+	self := this.beat(time.Now())
+	this.lock.Unlock()
+
+	if self != nil {
+		if err := this.backend.Register(self); err != nil {
+			return err
+		}
+		logger.Logger.Infof("cluster register self %v", self)
+	}
+	return this.backend.Watch(this.onSnapshot)
+}
+
+// Stop 注销本节点并停止监听
+func (this *Registry) Stop() {
+	this.lock.Lock()
+	if !this.started {
+		this.lock.Unlock()
+		return
+	}
+	this.started = false
+	self := this.self
+	this.lock.Unlock()
+
+	if self != nil {
+		if err := this.backend.Unregister(self); err != nil {
+			logger.Logger.Warnf("cluster unregister self %v error: %v", self, err)
+		}
+	}
+	this.backend.Close()
+}
+
+// Tick 发送心跳并检查成员存活
+func (this *Registry) Tick() {
+	this.lock.Lock()
+	if !this.started {
+		this.lock.Unlock()
+		return
+	}
+	var self *ServiceInfo
+	if nowTime := time.Now(); nowTime.Sub(this.lastHeartbeat) >= this.heartbeat {
+		self = this.beat(nowTime)
+	}
+	this.lock.Unlock()
+	if self != nil {
+		if err := this.backend.Heartbeat(self); err != nil {
+			logger.Logger.Warnf("cluster heartbeat %v error: %v", self, err)
+		}
+	}
+	this.refresh(nil)
+}
+
+// beat 记录本节点的心跳时间，返回给后端使用的副本，调用时需要持有lock
+// 心跳时间会写入后端被其他进程比较，使用墙上时间而不是core.Now()
+func (this *Registry) beat(nowTime time.Time) *ServiceInfo {
+	if this.self == nil {
+		return nil
+	}
+	this.lastHeartbeat = nowTime
+	this.self.LastHeartbeat = nowTime.UnixNano()
+	return this.self.clone()
+}
+
+func (this *Registry) onSnapshot(services []*ServiceInfo) {
+	this.lock.RLock()
+	started := this.started
+	this.lock.RUnlock()
+	//停止之后后端协程可能还有快照在投递
+	if started {
+		this.refresh(services)
+	}
+}
+
+// refresh 用新的快照(为nil时使用上一次的快照)重新计算存活的成员，并通知订阅者
+// 快照回调和心跳可能在不同协程中同时调用，整个过程持有refreshLock，避免事件乱序
+func (this *Registry) refresh(snapshot []*ServiceInfo) {
+	this.refreshLock.Lock()
+	defer this.refreshLock.Unlock()
+	nowTime := time.Now()
+	var events []*MemberEvent
+	this.lock.Lock()
+	if snapshot != nil {
+		this.snapshot = snapshot
+	}
+	alive := make(map[string]map[int]*ServiceInfo)
+	for _, si := range this.snapshot {
+		if !si.isAlive(nowTime, this.ttl) {
+			continue
+		}
+		byId, exist := alive[si.Type]
+		if !exist {
+			byId = make(map[int]*ServiceInfo)
+			alive[si.Type] = byId
+		}
+		byId[si.Id] = si
+	}
+	for svcType, byId := range alive {
+		old := this.services[svcType]
+		for id, si := range byId {
+			if prev, exist := old[id]; !exist {
+				events = append(events, &MemberEvent{Type: MemberEvent_Join, Service: si.clone()})
+			} else if !prev.sameEndpoint(si) {
+				events = append(events, &MemberEvent{Type: MemberEvent_Update, Service: si.clone()})
+			}
+		}
+	}
+	for svcType, old := range this.services {
+		for id, si := range old {
+			if _, exist := alive[svcType][id]; !exist {
+				events = append(events, &MemberEvent{Type: MemberEvent_Leave, Service: si.clone()})
+			}
+		}
+	}
+	this.services = alive
+	if len(events) > 0 {
+		this.rebuildRings()
+	}
+	subscribers := this.subscribers
+	this.lock.Unlock()
+
+	for _, e := range events {
+		logger.Logger.Infof("cluster member %v: %v", eventName(e.Type), e.Service)
+		for _, sub := range subscribers {
+			if sub.svcType == "" || sub.svcType == e.Service.Type {
+				sub.notify(e)
+			}
+		}
+	}
+}
+
+func (this *Registry) rebuildRings() {
+	this.rings = make(map[string]*consistent.Consistent)
+	for svcType, byId := range this.services {
+		ring := consistent.New()
+		for id := range byId {
+			ring.Add(strconv.Itoa(id))
+		}
+		this.rings[svcType] = ring
+	}
+}
+
+// Subscribe 订阅成员变化，svcType为空表示订阅所有类型；订阅时已存在的成员会以Join事件推送
+func (this *Registry) Subscribe(obj *basic.Object, svcType string, h MemberHandler) {
+	sub := &subscriber{obj: obj, svcType: svcType, h: h}
+	this.refreshLock.Lock()
+	defer this.refreshLock.Unlock()
+	this.lock.Lock()
+	this.subscribers = append(this.subscribers, sub)
+	var existing []*ServiceInfo
+	for t, byId := range this.services {
+		if svcType == "" || svcType == t {
+			for _, si := range byId {
+				existing = append(existing, si.clone())
+			}
+		}
+	}
+	this.lock.Unlock()
+	sortServices(existing)
+	for _, si := range existing {
+		sub.notify(&MemberEvent{Type: MemberEvent_Join, Service: si})
+	}
+}
+
+// Unsubscribe 取消Object的所有订阅
+func (this *Registry) Unsubscribe(obj *basic.Object) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	subscribers := make([]*subscriber, 0, len(this.subscribers))
+	for _, sub := range this.subscribers {
+		if sub.obj != obj {
+			subscribers = append(subscribers, sub)
+		}
+	}
+	this.subscribers = subscribers
+}
+
+func (sub *subscriber) notify(e *MemberEvent) {
+	sub.obj.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		sub.h(e)
+		return nil
+	}), true)
+}
+
+// GetService 按类型和id查找存活的服务
+func (this *Registry) GetService(svcType string, id int) *ServiceInfo {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	if si, exist := this.services[svcType][id]; exist {
+		return si.clone()
+	}
+	return nil
+}
+
+// GetServices 获取某个类型的所有存活服务，按id排序
+func (this *Registry) GetServices(svcType string) []*ServiceInfo {
+	this.lock.RLock()
+	services := make([]*ServiceInfo, 0, len(this.services[svcType]))
+	for _, si := range this.services[svcType] {
+		services = append(services, si.clone())
+	}
+	this.lock.RUnlock()
+	sortServices(services)
+	return services
+}
+
+// RandomService 随机选择一个服务
+func (this *Registry) RandomService(svcType string) (*ServiceInfo, error) {
+	services := this.GetServices(svcType)
+	if len(services) == 0 {
+		return nil, ErrNoService
+	}
+	return services[rand.Intn(len(services))], nil
+}
+
+// HashService 按一致性hash选择服务，成员变化时只有少量key会迁移
+func (this *Registry) HashService(svcType string, key string) (*ServiceInfo, error) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	ring, exist := this.rings[svcType]
+	if !exist {
+		return nil, ErrNoService
+	}
+	member, err := ring.Get(key)
+	if err != nil {
+		return nil, ErrNoService
+	}
+	id, _ := strconv.Atoi(member)
+	if si, exist := this.services[svcType][id]; exist {
+		return si.clone(), nil
+	}
+	return nil, ErrNoService
+}
+
+func sortServices(services []*ServiceInfo) {
+	sort.Slice(services, func(i, j int) bool {
+		if services[i].Type != services[j].Type {
+			return services[i].Type < services[j].Type
+		}
+		return services[i].Id < services[j].Id
+	})
+}
+
+func eventName(t int) string {
+	switch t {
+	case MemberEvent_Join:
+		return "join"
+	case MemberEvent_Leave:
+		return "leave"
+	case MemberEvent_Update:
+		return "update"
+	}
+	return "unknown"
+}
+
+func (this *Registry) ModuleName() string {
+	return module.ModuleName_Cluster
+}
+
+func (this *Registry) Init() {
+	if err := this.Start(); err != nil {
+		logger.Logger.Errorf("cluster registry start error: %v", err)
+	}
+}
+
+func (this *Registry) Update() {
+	this.Tick()
+}
+
+func (this *Registry) Shutdown() {
+	this.Stop()
+	module.UnregisteModule(this)
+}
+
+// 包级别的便捷函数，操作默认的ClusterModule
+
+func GetService(svcType string, id int) *ServiceInfo {
+	return ClusterModule.GetService(svcType, id)
+}
+
+func GetServices(svcType string) []*ServiceInfo {
+	return ClusterModule.GetServices(svcType)
+}
+
+func RandomService(svcType string) (*ServiceInfo, error) {
+	return ClusterModule.RandomService(svcType)
+}
+
+func HashService(svcType string, key string) (*ServiceInfo, error) {
+	return ClusterModule.HashService(svcType, key)
+}
+
+func Subscribe(obj *basic.Object, svcType string, h MemberHandler) {
+	ClusterModule.Subscribe(obj, svcType, h)
+}
+
+func Unsubscribe(obj *basic.Object) {
+	ClusterModule.Unsubscribe(obj)
+}
+
+func init() {
+	module.RegisteModule(ClusterModule, time.Second, 0)
+}
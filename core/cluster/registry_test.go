@@ -0,0 +1,236 @@
+package cluster
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/basic"
+)
+
+func newSubscriber(id int) (*basic.Object, chan *MemberEvent) {
+	o := basic.NewObject(id, "cluster-test", basic.Options{MaxDone: 1024, QueueBacklog: 1024}, nil)
+	core.LaunchChild(o)
+	return o, make(chan *MemberEvent, 16)
+}
+
+func waitEvent(t *testing.T, ch chan *MemberEvent, typ int, key string) {
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case e := <-ch:
+			if e.Type == typ && e.Service.Key() == key {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("wait %v %v timeout", eventName(typ), key)
+		}
+	}
+}
+
+func TestStaticRouting(t *testing.T) {
+	r := NewRegistry()
+	r.Setup(ServiceInfo{Type: "gate", Id: 1}, NewStaticBackend([]ServiceInfo{
+		{Type: "game", Id: 1, Ip: "10.0.0.1", Port: 9001},
+		{Type: "game", Id: 2, Ip: "10.0.0.2", Port: 9001},
+		{Type: "game", Id: 3, Ip: "10.0.0.3", Port: 9001},
+	}), 0, 0)
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	if si := r.GetService("game", 2); si == nil || si.Ip != "10.0.0.2" {
+		t.Fatalf("GetService=%v", si)
+	}
+	if r.GetService("gate", 1) == nil {
+		t.Fatal("self not registed")
+	}
+	if n := len(r.GetServices("game")); n != 3 {
+		t.Fatalf("GetServices len=%v", n)
+	}
+	if _, err := r.RandomService("world"); err != ErrNoService {
+		t.Fatalf("RandomService err=%v", err)
+	}
+	first, err := r.HashService("game", "player_10086")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if si, _ := r.HashService("game", "player_10086"); si.Id != first.Id {
+			t.Fatal("consistent hash not stable")
+		}
+	}
+}
+
+func TestFileBackendMembership(t *testing.T) {
+	dir := t.TempDir()
+	newFileRegistry := func(self ServiceInfo) *Registry {
+		b, err := NewFileBackend(dir, 50*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := NewRegistry()
+		r.Setup(self, b, 50*time.Millisecond, 300*time.Millisecond)
+		if err = r.Start(); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	a := newFileRegistry(ServiceInfo{Type: "gate", Id: 1, Ip: "127.0.0.1", Port: 8001})
+	defer a.Stop()
+	obj, events := newSubscriber(2000)
+	a.Subscribe(obj, "game", func(e *MemberEvent) { events <- e })
+
+	b := newFileRegistry(ServiceInfo{Type: "game", Id: 7, Ip: "127.0.0.1", Port: 9007})
+	waitEvent(t, events, MemberEvent_Join, "game-7")
+	if si := a.GetService("game", 7); si == nil || si.Port != 9007 {
+		t.Fatalf("GetService=%v", si)
+	}
+
+	//节点正常下线
+	b.Stop()
+	waitEvent(t, events, MemberEvent_Leave, "game-7")
+
+	//节点没有注销就停止心跳，超过TTL后下线
+	stale := &ServiceInfo{Type: "game", Id: 8, LastHeartbeat: time.Now().UnixNano()}
+	data, _ := json.Marshal(stale)
+	if err := os.WriteFile(filepath.Join(dir, stale.Key()+fileBackendExt), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, events, MemberEvent_Join, "game-8")
+	deadline := time.Now().Add(3 * time.Second)
+	for a.GetService("game", 8) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("stale service not expired")
+		}
+		time.Sleep(20 * time.Millisecond)
+		a.Tick()
+	}
+	waitEvent(t, events, MemberEvent_Leave, "game-8")
+}
+
+func TestLivenessIgnoresAppClock(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewFileBackend(dir, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	r.Setup(ServiceInfo{Type: "gate", Id: 1}, b, 50*time.Millisecond, time.Minute)
+	if err = r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	//其他进程写入的心跳使用墙上时间，本进程调整逻辑时钟不能让它过期
+	peer := &ServiceInfo{Type: "game", Id: 9, LastHeartbeat: time.Now().UnixNano()}
+	data, _ := json.Marshal(peer)
+	if err = os.WriteFile(filepath.Join(dir, peer.Key()+fileBackendExt), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for r.GetService("game", 9) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("peer not joined")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	core.AppClock.AddOffset(time.Hour)
+	defer core.AppClock.Reset()
+	r.Tick()
+	if r.GetService("game", 9) == nil {
+		t.Fatal("peer expired after app clock jump")
+	}
+	if self := r.GetService("gate", 1); self != nil && time.Unix(0, self.LastHeartbeat).After(time.Now().Add(time.Minute)) {
+		t.Fatalf("heartbeat written with app clock: %v", time.Unix(0, self.LastHeartbeat))
+	}
+}
+
+func TestFileBackendRestart(t *testing.T) {
+	dir := t.TempDir()
+	b, err := NewFileBackend(dir, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	r.Setup(ServiceInfo{Type: "gate", Id: 1}, b, time.Minute, time.Hour)
+	if err = r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	r.Stop()
+	if err = r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	//重新启动后仍然能收到目录变化
+	peer := &ServiceInfo{Type: "game", Id: 10, LastHeartbeat: time.Now().UnixNano()}
+	data, _ := json.Marshal(peer)
+	if err = os.WriteFile(filepath.Join(dir, peer.Key()+fileBackendExt), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for r.GetService("game", 10) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("peer not joined after restart")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestRefreshOrder(t *testing.T) {
+	r := NewRegistry()
+	r.Setup(ServiceInfo{}, NewStaticBackend(nil), time.Minute, time.Hour)
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	obj, _ := newSubscriber(2001)
+	events := make(chan *MemberEvent, 1024)
+	r.Subscribe(obj, "game", func(e *MemberEvent) { events <- e })
+
+	//多个协程同时刷新快照，订阅者收到的Join和Leave必须交替出现
+	peer := []*ServiceInfo{{Type: "game", Id: 1, LastHeartbeat: time.Now().UnixNano()}}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				if i%2 == 0 {
+					r.onSnapshot(peer)
+				} else {
+					r.onSnapshot([]*ServiceInfo{})
+				}
+				r.Tick()
+			}
+		}()
+	}
+	wg.Wait()
+	r.onSnapshot([]*ServiceInfo{})
+	joined := false
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case e := <-events:
+			if (e.Type == MemberEvent_Join) == joined {
+				t.Fatalf("event %v out of order", eventName(e.Type))
+			}
+			joined = e.Type == MemberEvent_Join
+		case <-timeout:
+			t.Fatal("wait events timeout")
+		}
+		if !joined && len(events) == 0 {
+			time.Sleep(50 * time.Millisecond)
+			if len(events) == 0 {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package cluster
+
+import (
+	"fmt"
+	"time"
+)
+
+// ServiceInfo 集群中的一个服务节点
+type ServiceInfo struct {
+	//服务类型，例如gate、game、world、dbproxy
+	Type string
+	Id   int
+	Ip   string
+	Port int
+	Meta map[string]string
+	//最后一次心跳的时间(UnixNano)，0表示静态配置的节点，永远存活
+	LastHeartbeat int64
+}
+
+func (si *ServiceInfo) Key() string {
+	return fmt.Sprintf("%v-%v", si.Type, si.Id)
+}
+
+func (si *ServiceInfo) Addr() string {
+	return fmt.Sprintf("%v:%v", si.Ip, si.Port)
+}
+
+func (si *ServiceInfo) String() string {
+	return fmt.Sprintf("%v(%v)", si.Key(), si.Addr())
+}
+
+func (si *ServiceInfo) isAlive(now time.Time, ttl time.Duration) bool {
+	if si.LastHeartbeat == 0 || ttl <= 0 {
+		return true
+	}
+	return now.Sub(time.Unix(0, si.LastHeartbeat)) <= ttl
+}
+
+// sameEndpoint 除心跳外的信息是否相同
+func (si *ServiceInfo) sameEndpoint(other *ServiceInfo) bool {
+	if si.Ip != other.Ip || si.Port != other.Port || len(si.Meta) != len(other.Meta) {
+		return false
+	}
+	for k, v := range si.Meta {
+		if ov, exist := other.Meta[k]; !exist || ov != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (si *ServiceInfo) clone() *ServiceInfo {
+	c := *si
+	if si.Meta != nil {
+		c.Meta = make(map[string]string, len(si.Meta))
+		for k, v := range si.Meta {
+			c.Meta[k] = v
+		}
+	}
+	return &c
+}
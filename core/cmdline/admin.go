@@ -0,0 +1,367 @@
+package cmdline
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/acoderup/goserver.v1/core/module"
+)
+
+// 远程控制台协议，按行文本交互
+// 客户端连接后先发送 "auth token"，之后每行一条命令
+// 服务器的每个回复为 "ok|err 长度\n" 加上长度字节的内容
+// 命令前加上 "json " 时返回json格式的CmdResult
+
+const (
+	DefaultAdminIdleTimeout = 5 * time.Minute
+	DefaultAdminExecTimeout = 30 * time.Second
+	adminStatusOK           = "ok"
+	adminStatusErr          = "err"
+	adminMaxLine            = 64 * 1024
+)
+
+var (
+	DefaultAdmin = NewAdminServer()
+
+	ErrAdminNotLoopback = errors.New("admin tcp address must be loopback")
+	ErrAdminNoToken     = errors.New("admin tcp listener requires a token")
+	ErrAdminForbidden   = errors.New("admin http without token only accepts loopback clients")
+)
+
+// AdminServer 远程控制台，执行RegisteCmd注册的命令并把输出返回给客户端
+type AdminServer struct {
+	//执行命令的object，为空时使用module.AppModule.Object
+	Executor  *basic.Object
+	lock      sync.Mutex
+	token     string
+	unixPath  string
+	listeners []net.Listener
+	//连接是否正在执行命令
+	conns map[net.Conn]bool
+	quit  bool
+	wg    sync.WaitGroup
+}
+
+func NewAdminServer() *AdminServer {
+	return &AdminServer{conns: make(map[net.Conn]bool)}
+}
+
+// Start 监听unix socket和回环tcp地址，任一为空则不监听
+func (this *AdminServer) Start(unixPath, tcpAddr, token string) error {
+	if tcpAddr != "" {
+		if token == "" {
+			return ErrAdminNoToken
+		}
+		if err := checkLoopback(tcpAddr); err != nil {
+			return err
+		}
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.token = token
+	this.quit = false
+	if unixPath != "" {
+		//上次异常退出残留的socket文件
+		os.Remove(unixPath)
+		l, err := listenUnix(unixPath)
+		if err != nil {
+			return err
+		}
+		if err = os.Chmod(unixPath, 0600); err != nil {
+			l.Close()
+			return err
+		}
+		this.unixPath = unixPath
+		this.serve(l)
+	}
+	if tcpAddr != "" {
+		l, err := net.Listen("tcp", tcpAddr)
+		if err != nil {
+			this.closeListeners()
+			return err
+		}
+		this.serve(l)
+	}
+	return nil
+}
+
+// Addrs 监听的地址
+func (this *AdminServer) Addrs() []net.Addr {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	var addrs []net.Addr
+	for _, l := range this.listeners {
+		addrs = append(addrs, l.Addr())
+	}
+	return addrs
+}
+
+// Close 关闭监听和空闲的连接，不等待，正在执行命令的连接回复结果后关闭
+// 可以在执行命令的Object中调用，例如exit命令触发的HOOK_AFTER_STOP
+func (this *AdminServer) Close() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.quit = true
+	this.closeListeners()
+	for conn, busy := range this.conns {
+		if !busy {
+			conn.Close()
+		}
+	}
+}
+
+// Stop 关闭监听和所有连接，等待所有连接的协程退出
+// 会等待正在执行的命令，不能在执行命令的Object中调用
+func (this *AdminServer) Stop() {
+	this.lock.Lock()
+	this.quit = true
+	this.closeListeners()
+	for conn := range this.conns {
+		conn.Close()
+	}
+	this.lock.Unlock()
+	this.wg.Wait()
+}
+
+func (this *AdminServer) closeListeners() {
+	for _, l := range this.listeners {
+		l.Close()
+	}
+	this.listeners = nil
+	if this.unixPath != "" {
+		os.Remove(this.unixPath)
+		this.unixPath = ""
+	}
+}
+
+func (this *AdminServer) serve(l net.Listener) {
+	logger.Logger.Infof("admin console listen on %v://%v", l.Addr().Network(), l.Addr())
+	this.listeners = append(this.listeners, l)
+	this.wg.Add(1)
+	go func() {
+		defer this.wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			this.lock.Lock()
+			if this.quit {
+				this.lock.Unlock()
+				conn.Close()
+				return
+			}
+			this.conns[conn] = false
+			this.wg.Add(1)
+			this.lock.Unlock()
+			go this.handleConn(conn)
+		}
+	}()
+}
+
+func (this *AdminServer) handleConn(conn net.Conn) {
+	defer func() {
+		this.lock.Lock()
+		delete(this.conns, conn)
+		this.lock.Unlock()
+		conn.Close()
+		this.wg.Done()
+	}()
+	idle := Config.AdminIdleTimeout
+	if idle <= 0 {
+		idle = DefaultAdminIdleTimeout
+	}
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), adminMaxLine)
+	authed := false
+	for {
+		conn.SetReadDeadline(time.Now().Add(idle))
+		if !scanner.Scan() {
+			return
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if !authed {
+			if !this.auth(line) {
+				logger.Logger.Warnf("admin console auth failed from %v", conn.RemoteAddr())
+				writeAdminReply(conn, adminStatusErr, "auth failed")
+				return
+			}
+			authed = true
+			writeAdminReply(conn, adminStatusOK, "")
+			continue
+		}
+		if line == "" {
+			writeAdminReply(conn, adminStatusOK, "")
+			continue
+		}
+		if !this.setBusy(conn, true) {
+			return
+		}
+		status, out := this.execLine(line)
+		err := writeAdminReply(conn, status, out)
+		if !this.setBusy(conn, false) || err != nil {
+			return
+		}
+	}
+}
+
+// setBusy 标记连接是否正在执行命令，服务器已经关闭时返回false
+func (this *AdminServer) setBusy(conn net.Conn, busy bool) bool {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.quit {
+		return false
+	}
+	this.conns[conn] = busy
+	return true
+}
+
+func (this *AdminServer) auth(line string) bool {
+	if line != "auth" && !strings.HasPrefix(line, "auth ") {
+		return false
+	}
+	token := strings.TrimSpace(strings.TrimPrefix(line, "auth"))
+	this.lock.Lock()
+	expect := this.token
+	this.lock.Unlock()
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expect)) == 1
+}
+
+// Exec 在Executor中执行命令，等待执行完成后返回结果
+// 超过AdminExecTimeout时返回"still running"的错误，命令仍会在Executor中执行完，输出被丢弃
+func (this *AdminServer) Exec(line string) *CmdResult {
+	params := strings.Fields(line)
+	if len(params) == 0 {
+		return &CmdResult{Error: "empty command"}
+	}
+	res := &CmdResult{Command: params[0]}
+	exec, exist := getCmd(params[0])
+	if !exist {
+		res.Error = fmt.Sprintf("unknown command: %v, try help", params[0])
+		return res
+	}
+	logger.Logger.Infof("admin console exec: %v", line)
+	buf := &bytes.Buffer{}
+	cmd := &cmdlineCommand{exec: exec, args: params[1:], w: buf, done: make(chan struct{})}
+	obj := this.Executor
+	if obj == nil {
+		obj = module.AppModule.Object
+	}
+	if obj == nil {
+		cmd.data, cmd.err = runCmd(buf, exec, params[1:])
+	} else {
+		timeout := Config.AdminExecTimeout
+		if timeout <= 0 {
+			timeout = DefaultAdminExecTimeout
+		}
+		if !obj.SendCommand(cmd, true) {
+			res.Error = "send command failed"
+			return res
+		}
+		select {
+		case <-cmd.done:
+		case <-time.After(timeout):
+			res.Error = fmt.Sprintf("command %v still running after %v, its output will be discarded", params[0], timeout)
+			return res
+		}
+	}
+	res.Output, res.Data = buf.String(), cmd.data
+	if cmd.err != nil {
+		res.Error = cmd.err.Error()
+	}
+	return res
+}
+
+// execLine 文本模式输出命令的文本和数据，"json cmd..."返回json格式的CmdResult
+func (this *AdminServer) execLine(line string) (string, string) {
+	if rest, ok := strings.CutPrefix(line, "json "); ok {
+		buf, err := json.Marshal(this.Exec(rest))
+		if err != nil {
+			return adminStatusErr, err.Error()
+		}
+		return adminStatusOK, string(buf) + "\n"
+	}
+	res := this.Exec(line)
+	out := &bytes.Buffer{}
+	out.WriteString(res.Output)
+	if res.Error != "" {
+		if out.Len() == 0 {
+			out.WriteString(res.Error)
+		}
+		return adminStatusErr, out.String()
+	}
+	writeData(out, res.Data)
+	return adminStatusOK, out.String()
+}
+
+// HTTPHandler 以json返回命令结果，命令通过cmd参数或者POST的json{"cmd": "..."}传递
+// token不为空时需要在请求头 Authorization: Bearer token 中携带，为空时只接受回环地址的请求
+func (this *AdminServer) HTTPHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if token == "" {
+			if err := checkLoopback(r.RemoteAddr); err != nil {
+				logger.Logger.Warnf("admin http rejected request from %v", r.RemoteAddr)
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(&CmdResult{Error: ErrAdminForbidden.Error()})
+				return
+			}
+		} else {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(&CmdResult{Error: "auth failed"})
+				return
+			}
+		}
+		line := r.URL.Query().Get("cmd")
+		if r.Method == http.MethodPost {
+			var req struct {
+				Cmd string `json:"cmd"`
+			}
+			if err := json.NewDecoder(io.LimitReader(r.Body, adminMaxLine)).Decode(&req); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(&CmdResult{Error: err.Error()})
+				return
+			}
+			line = req.Cmd
+		}
+		res := this.Exec(line)
+		if res.Error != "" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+func writeAdminReply(w io.Writer, status, out string) error {
+	_, err := fmt.Fprintf(w, "%s %d\n%s", status, len(out), out)
+	return err
+}
+
+func checkLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return ErrAdminNotLoopback
+}
@@ -0,0 +1,168 @@
+package cmdline
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/basic"
+)
+
+type echoExecuter struct{}
+
+func (this echoExecuter) Execute(args []string) {}
+func (this echoExecuter) ShowUsage()            {}
+func (this echoExecuter) ExecuteTo(w io.Writer, args []string) {
+	fmt.Fprintln(w, strings.Join(args, " "))
+}
+func (this echoExecuter) ShowUsageTo(w io.Writer) {
+	fmt.Fprintln(w, "usage: echo args...")
+}
+
+// blockExecuter 等待release后才返回，用于测试执行超时
+type blockExecuter struct {
+	release chan struct{}
+}
+
+func (this blockExecuter) Execute(args []string) {}
+func (this blockExecuter) ShowUsage()            {}
+func (this blockExecuter) ExecuteTo(w io.Writer, args []string) {
+	<-this.release
+}
+func (this blockExecuter) ShowUsageTo(w io.Writer) {}
+
+type legacyExecuter struct{}
+
+func (this legacyExecuter) Execute(args []string) {}
+func (this legacyExecuter) ShowUsage()            {}
+
+func init() {
+	RegisteCmd("echo", &echoExecuter{})
+	RegisteCmd("legacy", &legacyExecuter{})
+}
+
+func TestAdminConsole(t *testing.T) {
+	obj := basic.NewObject(core.ObjId_CoreId, "admin-test", basic.Options{MaxDone: 16, QueueBacklog: 16}, nil)
+	core.LaunchChild(obj)
+	srv := NewAdminServer()
+	srv.Executor = obj
+	sock := filepath.Join(t.TempDir(), "admin.sock")
+	if err := srv.Start(sock, "127.0.0.1:0", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+	addrs := srv.Addrs()
+	if len(addrs) != 2 {
+		t.Fatalf("listen addrs=%v", addrs)
+	}
+
+	if _, err := DialAdmin("tcp", addrs[1].String(), "wrong", time.Second); err == nil {
+		t.Fatal("wrong token accepted")
+	}
+	for _, addr := range []adminAddr{{"unix", sock}, {"tcp", addrs[1].String()}} {
+		c, err := DialAdmin(addr.network, addr.addr, "secret", time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out, err := c.Exec("echo hello admin"); err != nil || out != "hello admin\n" {
+			t.Fatalf("%v echo out=%q err=%v", addr.network, out, err)
+		}
+		if out, err := c.Exec("help echo"); err != nil || !strings.Contains(out, "usage: echo") {
+			t.Fatalf("%v help out=%q err=%v", addr.network, out, err)
+		}
+		if out, err := c.Exec("legacy"); err != nil || !strings.Contains(out, "server stdout") {
+			t.Fatalf("%v legacy out=%q err=%v", addr.network, out, err)
+		}
+		if out, err := c.Exec("json echo hi"); err != nil || !strings.Contains(out, `"output":"hi\n"`) {
+			t.Fatalf("%v json out=%q err=%v", addr.network, out, err)
+		}
+		if _, err := c.Exec("nosuchcmd"); err == nil {
+			t.Fatalf("%v unknown command accepted", addr.network)
+		}
+		c.Close()
+	}
+}
+
+type adminAddr struct {
+	network string
+	addr    string
+}
+
+func TestAdminTCPRestrictions(t *testing.T) {
+	srv := NewAdminServer()
+	if err := srv.Start("", "127.0.0.1:0", ""); err != ErrAdminNoToken {
+		t.Fatalf("tcp without token err=%v", err)
+	}
+	if err := srv.Start("", "0.0.0.0:0", "secret"); err != ErrAdminNotLoopback {
+		t.Fatalf("non loopback err=%v", err)
+	}
+}
+
+func TestAdminExecStillRunning(t *testing.T) {
+	obj := basic.NewObject(core.ObjId_CoreId, "admin-block", basic.Options{MaxDone: 16, QueueBacklog: 16}, nil)
+	core.LaunchChild(obj)
+	release := make(chan struct{})
+	defer close(release)
+	RegisteCmd("block", &blockExecuter{release: release})
+	old := Config.AdminExecTimeout
+	Config.AdminExecTimeout = 20 * time.Millisecond
+	defer func() { Config.AdminExecTimeout = old }()
+
+	srv := NewAdminServer()
+	srv.Executor = obj
+	if res := srv.Exec("block"); !strings.Contains(res.Error, "still running") {
+		t.Fatalf("timeout error=%q", res.Error)
+	}
+}
+
+func TestAdminExit(t *testing.T) {
+	obj := basic.NewObject(core.ObjId_CoreId, "admin-exit", basic.Options{MaxDone: 16, QueueBacklog: 16}, nil)
+	core.LaunchChild(obj)
+	DefaultAdmin.Executor = obj
+	defer func() { DefaultAdmin.Executor = nil }()
+	sock := filepath.Join(t.TempDir(), "admin.sock")
+	if err := DefaultAdmin.Start(sock, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	defer DefaultAdmin.Stop()
+	idle, err := DialAdmin("unix", sock, "", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer idle.Close()
+	c, err := DialAdmin("unix", sock, "", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	//exit在Executor中触发HOOK_AFTER_STOP关闭控制台，执行命令的连接仍然收到回复
+	start := time.Now()
+	if out, err := c.Exec("exit"); err != nil || out != "server stopping...\n" {
+		t.Fatalf("exit out=%q err=%v", out, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("exit took %v", d)
+	}
+	if _, err = idle.Exec("echo hi"); err == nil {
+		t.Fatal("idle connection not closed after exit")
+	}
+	if _, err = DialAdmin("unix", sock, "", 100*time.Millisecond); err == nil {
+		t.Fatal("admin console still listening after exit")
+	}
+	done := make(chan struct{})
+	obj.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		close(done)
+		return nil
+	}), true)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("executor blocked after exit")
+	}
+}
@@ -0,0 +1,16 @@
+//go:build unix
+
+package cmdline
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnix 创建socket文件时屏蔽group和other的权限，避免Listen和Chmod之间的窗口期被其他用户连接
+// umask是进程级别的，Start在启动阶段调用，不会和其它创建文件的协程交错
+func listenUnix(path string) (net.Listener, error) {
+	old := syscall.Umask(0077)
+	defer syscall.Umask(old)
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,10 @@
+package cmdline
+
+import (
+	"net"
+)
+
+// listenUnix windows的socket文件权限由所在目录的ACL控制
+func listenUnix(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
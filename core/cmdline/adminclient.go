@@ -0,0 +1,62 @@
+package cmdline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// AdminClient 远程控制台客户端
+type AdminClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// DialAdmin 连接远程控制台并认证，network为unix或者tcp
+func DialAdmin(network, addr, token string, timeout time.Duration) (*AdminClient, error) {
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &AdminClient{conn: conn, r: bufio.NewReader(conn)}
+	if _, err = c.Exec("auth " + token); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Exec 执行一条命令，返回命令的输出
+func (c *AdminClient) Exec(line string) (string, error) {
+	if strings.ContainsAny(line, "\r\n") {
+		return "", errors.New("command must be a single line")
+	}
+	if _, err := fmt.Fprintf(c.conn, "%s\n", line); err != nil {
+		return "", err
+	}
+	header, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	var status string
+	var n int
+	if _, err = fmt.Sscanf(header, "%s %d\n", &status, &n); err != nil {
+		return "", fmt.Errorf("bad admin reply %q: %v", header, err)
+	}
+	out := make([]byte, n)
+	if _, err = io.ReadFull(c.r, out); err != nil {
+		return "", err
+	}
+	if status != adminStatusOK {
+		return "", errors.New(string(out))
+	}
+	return string(out), nil
+}
+
+func (c *AdminClient) Close() error {
+	return c.conn.Close()
+}
@@ -3,6 +3,7 @@ package cmdline
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -31,6 +32,36 @@ type cmdExecuter interface {
 	ShowUsage()
 }
 
+// CmdWriterExecuter 输出写到w的命令，远程控制台执行时可以把输出返回给客户端
+// 只实现cmdExecuter的命令，输出仍然打印在服务器的标准输出上
+type CmdWriterExecuter interface {
+	ExecuteTo(w io.Writer, args []string)
+	ShowUsageTo(w io.Writer)
+}
+
+func executeTo(w io.Writer, exec cmdExecuter, args []string) {
+	if we, ok := exec.(CmdWriterExecuter); ok {
+		we.ExecuteTo(w, args)
+		return
+	}
+	exec.Execute(args)
+	fmt.Fprintln(w, "(output printed on server stdout)")
+}
+
+func showUsageTo(w io.Writer, exec cmdExecuter) {
+	if we, ok := exec.(CmdWriterExecuter); ok {
+		we.ShowUsageTo(w)
+		return
+	}
+	exec.ShowUsage()
+	fmt.Fprintln(w, "(usage printed on server stdout)")
+}
+
+func getCmd(cmdName string) (cmdExecuter, bool) {
+	exec, exist := cmdpool[strings.ToLower(cmdName)]
+	return exec, exist
+}
+
 func NewCmdArgParser(args []string) *CmdArgParser {
 	parser := &CmdArgParser{
 		cmeKV: make(map[string]string),
@@ -67,7 +98,7 @@ func (cw *cmdGoroutineWapper) Start() {
 					if len(params) >= 1 {
 						cmdName := strings.ToLower(params[0])
 						if cmdExecute, exist := cmdpool[cmdName]; exist {
-							PostCmd(module.AppModule.Object, cmdExecute, params[1:])
+							PostCmdTo(module.AppModule.Object, os.Stdout, cmdExecute, params[1:])
 						}
 					}
 				}
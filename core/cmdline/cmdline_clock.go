@@ -0,0 +1,77 @@
+package cmdline
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+)
+
+type clockExecuter struct {
+}
+
+func (this clockExecuter) Execute(args []string) {
+	this.ExecuteTo(os.Stdout, args)
+}
+
+func (this clockExecuter) ExecuteTo(w io.Writer, args []string) {
+	if len(args) == 0 {
+		this.show(w)
+		return
+	}
+	switch args[0] {
+	case "reset":
+		core.AppClock.Reset()
+	case "offset", "add", "scale":
+		if len(args) < 2 {
+			this.ShowUsageTo(w)
+			return
+		}
+		if args[0] == "scale" {
+			scale, err := strconv.ParseFloat(args[1], 64)
+			if err != nil || scale <= 0 {
+				fmt.Fprintln(w, "invalid scale:", args[1])
+				return
+			}
+			core.AppClock.SetScale(scale)
+			break
+		}
+		d, err := time.ParseDuration(args[1])
+		if err != nil {
+			fmt.Fprintln(w, "invalid duration:", args[1], err)
+			return
+		}
+		if args[0] == "offset" {
+			core.AppClock.SetOffset(d)
+		} else {
+			core.AppClock.AddOffset(d)
+		}
+	default:
+		this.ShowUsageTo(w)
+		return
+	}
+	this.show(w)
+}
+
+func (this clockExecuter) show(w io.Writer) {
+	fmt.Fprintln(w, "now:", core.Now().Format("2006-01-02 15:04:05.000"), "offset:", core.AppClock.Offset(), "scale:", core.AppClock.Scale())
+}
+
+func (this clockExecuter) ShowUsage() {
+	this.ShowUsageTo(os.Stdout)
+}
+
+func (this clockExecuter) ShowUsageTo(w io.Writer) {
+	fmt.Fprintln(w, "usage: clock [reset | offset duration | add duration | scale factor]")
+	fmt.Fprintln(w, "\t", "clock offset 24h    set the clock offset to 24h")
+	fmt.Fprintln(w, "\t", "clock add 1h30m     fast-forward the clock by 1h30m")
+	fmt.Fprintln(w, "\t", "clock scale 60      one real second counts as one minute")
+	fmt.Fprintln(w, "\t", "clock reset         back to real time")
+}
+
+func init() {
+	RegisteCmd("clock", &clockExecuter{})
+}
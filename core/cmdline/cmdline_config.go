@@ -0,0 +1,31 @@
+package cmdline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/acoderup/goserver.v1/core"
+)
+
+func init() {
+	RegisteCommand(&Command{
+		Name:  "config",
+		Usage: "show the effective merged config, secrets redacted",
+		Flags: []*Flag{{Name: "package", Short: "p", Usage: "only show this package"}},
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			name := ctx.String("package")
+			if name == "" && len(ctx.Args) > 0 {
+				name = ctx.Args[0]
+			}
+			settings := core.EffectiveConfig()
+			if name == "" {
+				return settings, nil
+			}
+			v, exist := settings[strings.ToLower(name)]
+			if !exist {
+				return nil, fmt.Errorf("package %v not found in config", name)
+			}
+			return v, nil
+		},
+	})
+}
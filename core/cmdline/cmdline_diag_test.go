@@ -0,0 +1,77 @@
+package cmdline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTableString(t *testing.T) {
+	tb := NewTable("name", "count")
+	tb.AddRow("a", 1)
+	tb.AddRow("long name", 100)
+	want := "| name      | count |\n|-----------|-------|\n| a         | 1     |\n| long name | 100   |"
+	if tb.String() != want {
+		t.Fatalf("table:\n%v", tb)
+	}
+}
+
+func TestDiagCommands(t *testing.T) {
+	srv := NewAdminServer()
+	for _, line := range []string{"gc -run", "goroutines", "panics", "recyclers", "stats -sort=name", "stats modules", "cpuprof"} {
+		res := srv.Exec(line)
+		if res.Error != "" {
+			t.Fatalf("%v error=%v output=%v", line, res.Error, res.Output)
+		}
+		if _, ok := res.Data.(*Table); !ok {
+			t.Fatalf("%v data is %T", line, res.Data)
+		}
+	}
+	if res := srv.Exec("goroutines -stack"); !strings.Contains(res.Output, "goroutine") {
+		t.Fatalf("goroutine stacks not dumped: %v", res.Output)
+	}
+}
+
+func TestLogLevelCommand(t *testing.T) {
+	srv := NewAdminServer()
+	old := srv.Exec("loglevel").Data.(*Table).Rows[0][0]
+	defer srv.Exec("loglevel " + old)
+	res := srv.Exec("loglevel -set=error")
+	if res.Error != "" || res.Data.(*Table).Rows[0][0] != "error" {
+		t.Fatalf("loglevel error=%v data=%v", res.Error, res.Data)
+	}
+	if res := srv.Exec("loglevel -set=loud"); res.Error == "" {
+		t.Fatal("invalid level accepted")
+	}
+}
+
+func TestCPUProfCommand(t *testing.T) {
+	srv := NewAdminServer()
+	file := filepath.Join(t.TempDir(), "cpu.pprof")
+	if res := srv.Exec("cpuprof start -file " + file); res.Error != "" {
+		t.Fatal(res.Error)
+	}
+	if res := srv.Exec("cpuprof start -file " + file); res.Error == "" {
+		t.Fatal("second cpu profile started")
+	}
+	if res := srv.Exec("cpuprof stop"); res.Error != "" {
+		t.Fatal(res.Error)
+	}
+	if fi, err := os.Stat(file); err != nil || fi.Size() == 0 {
+		t.Fatalf("profile file stat=%v err=%v", fi, err)
+	}
+	if res := srv.Exec("cpuprof stop"); res.Error == "" {
+		t.Fatal("stop without running profile succeeded")
+	}
+}
+
+func TestConfigCommand(t *testing.T) {
+	srv := NewAdminServer()
+	if res := srv.Exec("config"); res.Error != "" {
+		t.Fatalf("config error=%v", res.Error)
+	}
+	if res := srv.Exec("config -p nosuchpackage"); res.Error == "" {
+		t.Fatal("unknown package accepted")
+	}
+}
@@ -2,6 +2,8 @@ package cmdline
 
 import (
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/acoderup/goserver.v1/core/module"
 )
@@ -10,11 +12,20 @@ type exitExecuter struct {
 }
 
 func (this exitExecuter) Execute(args []string) {
+	this.ExecuteTo(os.Stdout, args)
+}
+
+func (this exitExecuter) ExecuteTo(w io.Writer, args []string) {
+	fmt.Fprintln(w, "server stopping...")
 	module.Stop()
 }
 
 func (this exitExecuter) ShowUsage() {
-	fmt.Println("usage: exit")
+	this.ShowUsageTo(os.Stdout)
+}
+
+func (this exitExecuter) ShowUsageTo(w io.Writer) {
+	fmt.Fprintln(w, "usage: exit")
 }
 
 func init() {
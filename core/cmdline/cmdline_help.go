@@ -2,32 +2,56 @@ package cmdline
 
 import (
 	"fmt"
+	"io"
+	"os"
 )
 
 type helpExecuter struct {
 }
 
 func (this helpExecuter) Execute(args []string) {
+	this.ExecuteTo(os.Stdout, args)
+}
+
+func (this helpExecuter) ExecuteTo(w io.Writer, args []string) {
 	if len(args) > 0 {
-		if cmde, exist := cmdpool[args[0]]; exist {
-			cmde.ShowUsage()
+		cmde, exist := getCmd(args[0])
+		if !exist {
+			fmt.Fprintln(w, "unknown command:", args[0])
+			return
+		}
+		if ce, ok := cmde.(*commandExecuter); ok {
+			//help cmd sub... 显示子命令的帮助
+			cmd, path, _ := ce.cmd.resolve(args[1:])
+			cmd.writeHelp(w, path)
+			return
 		}
+		showUsageTo(w, cmde)
 	} else {
-		this.ShowUsage()
-		fmt.Println("The commands are:")
-		for k, _ := range cmdpool {
-			if k != "help" {
-				fmt.Println("\t", k)
+		this.ShowUsageTo(w)
+		fmt.Fprintln(w, "The commands are:")
+		for _, k := range commandNames() {
+			if k == "help" {
+				continue
+			}
+			if ce, ok := cmdpool[k].(*commandExecuter); ok && ce.cmd.Usage != "" {
+				fmt.Fprintf(w, "\t %-16s %s\n", k, ce.cmd.Usage)
+			} else {
+				fmt.Fprintln(w, "\t", k)
 			}
 		}
-		fmt.Println("Use \"help [command]\" for more information about a command.")
+		fmt.Fprintln(w, "Use \"help [command]\" for more information about a command.")
 	}
 }
 
 func (this helpExecuter) ShowUsage() {
-	fmt.Println("Help is a help command like window or linux shell's command")
-	fmt.Println("Usage:")
-	fmt.Println("\t", "help command")
+	this.ShowUsageTo(os.Stdout)
+}
+
+func (this helpExecuter) ShowUsageTo(w io.Writer) {
+	fmt.Fprintln(w, "Help is a help command like window or linux shell's command")
+	fmt.Fprintln(w, "Usage:")
+	fmt.Fprintln(w, "\t", "help command [subcommand...]")
 }
 
 func init() {
@@ -0,0 +1,57 @@
+package cmdline
+
+import (
+	"fmt"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/logger"
+)
+
+func init() {
+	RegisteCommand(&Command{
+		Name:  "loglevel",
+		Usage: "show or change the minimum log level",
+		Flags: []*Flag{{Name: "set", Type: FlagType_Enum, Enum: []string{"trace", "debug", "info", "warn", "error", "critical", "off"}, Usage: "new level"}},
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			level := ctx.String("set")
+			if level == "" && len(ctx.Args) > 0 {
+				level = ctx.Args[0]
+			}
+			if level != "" {
+				old := logger.GetLevel()
+				if err := logger.SetLevel(level); err != nil {
+					return nil, err
+				}
+				logger.Logger.Infof("log level changed from %v to %v", old, level)
+			}
+			t := NewTable("level")
+			t.AddRow(logger.GetLevel())
+			return t, nil
+		},
+	})
+	RegisteCommand(&Command{
+		Name:  "reload",
+		Usage: "reload configurations",
+		Subs: []*Command{{
+			Name:  "config",
+			Usage: "run the config reload hooks",
+			Run: func(ctx *CmdContext) (interface{}, error) {
+				if err := core.ExecuteHook(core.HOOK_CONFIG_RELOAD); err != nil {
+					return nil, err
+				}
+				return "config reloaded", nil
+			},
+		}, {
+			Name:  "logger",
+			Usage: "reload the seelog config file",
+			Flags: []*Flag{{Name: "file", Default: "logger.xml", Usage: "seelog config file"}},
+			Run: func(ctx *CmdContext) (interface{}, error) {
+				file := ctx.String("file")
+				if err := logger.Reload(file); err != nil {
+					return nil, err
+				}
+				return fmt.Sprintf("logger reloaded from %v, level %v", file, logger.GetLevel()), nil
+			},
+		}},
+	})
+}
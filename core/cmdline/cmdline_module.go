@@ -0,0 +1,67 @@
+package cmdline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/acoderup/goserver.v1/core/module"
+)
+
+// moduleStatusTable 文本输出时按表格显示，结构化输出为模块状态列表
+type moduleStatusTable []module.ModuleStatus
+
+func (t moduleStatusTable) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "| % -20s| % -10s | % -10s | %s\n", "name", "priority", "state", "depends")
+	for _, ms := range t {
+		fmt.Fprintf(sb, "| % -20s| % -10d | % -10s | %s\n", ms.Name, ms.Priority, ms.State, strings.Join(ms.Depends, ","))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func moduleOpCommand(name, usage string, op func(string) error) *Command {
+	return &Command{
+		Name:  name,
+		Usage: usage,
+		Flags: []*Flag{{Name: "name", Short: "n", Usage: "module name"}},
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			name := ctx.String("name")
+			if name == "" && len(ctx.Args) > 0 {
+				name = ctx.Args[0]
+			}
+			if name == "" {
+				return nil, fmt.Errorf("module name is required")
+			}
+			if err := op(name); err != nil {
+				ctx.Println(ctx.Cmd.Name, name, "failed:", err)
+				return nil, err
+			}
+			return fmt.Sprintf("%v %v ok", ctx.Cmd.Name, name), nil
+		},
+	}
+}
+
+func listModules(ctx *CmdContext) (interface{}, error) {
+	return moduleStatusTable(module.AppModule.GetModulesStatus()), nil
+}
+
+func init() {
+	RegisteCommand(&Command{
+		Name:  "module",
+		Usage: "list, stop, start, restart or remove modules",
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			if len(ctx.Args) > 0 {
+				ctx.Cmd.writeHelp(ctx.Out, ctx.Path)
+				return nil, fmt.Errorf("unknown subcommand %v", ctx.Args[0])
+			}
+			return listModules(ctx)
+		},
+		Subs: []*Command{
+			{Name: "list", Usage: "show all modules", Run: listModules},
+			moduleOpCommand("stop", "stop a module", func(name string) error { return module.AppModule.StopModule(name) }),
+			moduleOpCommand("start", "start a stopped module", func(name string) error { return module.AppModule.StartModule(name) }),
+			moduleOpCommand("restart", "restart a module", func(name string) error { return module.AppModule.RestartModule(name) }),
+			moduleOpCommand("remove", "stop and remove a module", func(name string) error { return module.AppModule.RemoveModule(name) }),
+		},
+	})
+}
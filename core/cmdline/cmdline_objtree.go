@@ -0,0 +1,36 @@
+package cmdline
+
+import (
+	"errors"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/acoderup/goserver.v1/core"
+)
+
+func init() {
+	RegisteCommand(&Command{
+		Name:  "objtree",
+		Usage: "show the object tree with command queue stats",
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			root := core.CoreObject()
+			if root == nil {
+				return nil, errors.New("core object not running")
+			}
+			stats := root.GetStats()
+			names := make([]string, 0, len(stats))
+			for name := range stats {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			t := NewTable("object", "pending", "send", "recv")
+			for _, name := range names {
+				s := stats[name]
+				depth := strings.Count(name, "/") - 1
+				t.AddRow(strings.Repeat("  ", depth)+path.Base(name), s.PendingCnt, s.SendCmdCnt, s.RecvCmdCnt)
+			}
+			return t, nil
+		},
+	})
+}
@@ -0,0 +1,44 @@
+package cmdline
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/acoderup/goserver.v1/core/utils"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+func init() {
+	RegisteCommand(&Command{
+		Name:  "panics",
+		Usage: "show recovered panics grouped by location",
+		Flags: []*Flag{{Name: "stack", Type: FlagType_Bool, Usage: "print the stack of each panic"}},
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			stats := utils.GetPanicStats()
+			keys := make([]string, 0, len(stats))
+			for k := range stats {
+				keys = append(keys, k)
+			}
+			sort.Slice(keys, func(i, j int) bool { return stats[keys[i]].LastTime.After(stats[keys[j]].LastTime) })
+			t := NewTable("location", "times", "first", "last", "error")
+			for _, k := range keys {
+				ps := stats[k]
+				t.AddRow(k, ps.Times, ps.FirstTime.Format(timeLayout), ps.LastTime.Format(timeLayout), firstLine(ps.ErrorMsg))
+			}
+			if ctx.Bool("stack") {
+				for _, k := range keys {
+					ctx.Printf("==== %v\n%v\n", k, stats[k].StackBuf)
+				}
+			}
+			return t, nil
+		},
+	})
+}
+
+func firstLine(s string) string {
+	if idx := strings.IndexByte(s, '\n'); idx >= 0 {
+		return s[:idx]
+	}
+	return s
+}
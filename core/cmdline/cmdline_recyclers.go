@@ -0,0 +1,19 @@
+package cmdline
+
+import (
+	"github.com/acoderup/goserver.v1/core/container/recycler"
+)
+
+func init() {
+	RegisteCommand(&Command{
+		Name:  "recyclers",
+		Usage: "show object recyclers and their allocation count",
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			t := NewTable("name", "alloc")
+			for _, s := range recycler.RecyclerMgr.Stats() {
+				t.AddRow(s.Name, s.Alloc)
+			}
+			return t, nil
+		},
+	})
+}
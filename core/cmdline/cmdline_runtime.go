@@ -0,0 +1,167 @@
+package cmdline
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/acoderup/goserver.v1/core/utils"
+)
+
+func init() {
+	RegisteCommand(&Command{
+		Name:  "gc",
+		Usage: "show gc and memory summary",
+		Flags: []*Flag{{Name: "run", Type: FlagType_Bool, Usage: "force a gc and return memory to the os first"}},
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			if ctx.Bool("run") {
+				start := time.Now()
+				debug.FreeOSMemory()
+				ctx.Println("gc done, take", utils.ToS(time.Since(start)))
+			}
+			mem := &runtime.MemStats{}
+			runtime.ReadMemStats(mem)
+			gs := &debug.GCStats{}
+			debug.ReadGCStats(gs)
+			var lastPause time.Duration
+			if len(gs.Pause) > 0 {
+				lastPause = gs.Pause[0]
+			}
+			t := NewTable("item", "value")
+			t.AddRow("NumGC", gs.NumGC)
+			t.AddRow("LastGC", gs.LastGC.Format(timeLayout))
+			t.AddRow("LastPause", utils.ToS(lastPause))
+			t.AddRow("PauseTotal", utils.ToS(gs.PauseTotal))
+			t.AddRow("HeapAlloc", utils.ToH(mem.HeapAlloc))
+			t.AddRow("HeapInuse", utils.ToH(mem.HeapInuse))
+			t.AddRow("HeapObjects", mem.HeapObjects)
+			t.AddRow("NextGC", utils.ToH(mem.NextGC))
+			t.AddRow("TotalAlloc", utils.ToH(mem.TotalAlloc))
+			t.AddRow("Sys", utils.ToH(mem.Sys))
+			return t, nil
+		},
+	})
+	RegisteCommand(&Command{
+		Name:  "goroutines",
+		Usage: "show goroutine, thread, heap and block profile counts",
+		Flags: []*Flag{{Name: "stack", Type: FlagType_Bool, Usage: "dump all goroutine stacks"}},
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			if ctx.Bool("stack") {
+				utils.ProcessInput("lookup goroutine", ctx.Out)
+			}
+			rs := utils.StatsRuntime()
+			t := NewTable("item", "count")
+			t.AddRow("goroutine", rs.CountGoroutine)
+			t.AddRow("thread", rs.CountThread)
+			t.AddRow("heap profile", rs.CountHeap)
+			t.AddRow("block profile", rs.CountBlock)
+			return t, nil
+		},
+	})
+	RegisteCommand(&Command{
+		Name:  "cpuprof",
+		Usage: "start or stop cpu profiling",
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			return cpuProf.status(), nil
+		},
+		Subs: []*Command{{
+			Name:  "start",
+			Usage: "start cpu profiling into a file",
+			Flags: []*Flag{
+				{Name: "file", Usage: "profile file, default cpu-<pid>-<time>.pprof"},
+				{Name: "duration", Type: FlagType_Duration, Usage: "stop automatically after the duration"},
+			},
+			Run: func(ctx *CmdContext) (interface{}, error) {
+				file := ctx.String("file")
+				if file == "" {
+					file = fmt.Sprintf("cpu-%v-%v.pprof", os.Getpid(), time.Now().Format("20060102150405"))
+				}
+				if err := cpuProf.start(file, ctx.Duration("duration")); err != nil {
+					return nil, err
+				}
+				return cpuProf.status(), nil
+			},
+		}, {
+			Name:  "stop",
+			Usage: "stop cpu profiling",
+			Run: func(ctx *CmdContext) (interface{}, error) {
+				file, err := cpuProf.stop()
+				if err != nil {
+					return nil, err
+				}
+				return "cpu profile saved to " + file, nil
+			},
+		}},
+	})
+}
+
+var cpuProf = &cpuProfiler{}
+
+// cpuProfiler 同一时间只能有一个cpu profile，出错时返回错误而不是退出进程
+type cpuProfiler struct {
+	lock      sync.Mutex
+	f         *os.File
+	startTime time.Time
+	timer     *time.Timer
+}
+
+func (this *cpuProfiler) start(file string, d time.Duration) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.f != nil {
+		return fmt.Errorf("cpu profile already running, file %v", this.f.Name())
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if err = pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		os.Remove(file)
+		return err
+	}
+	this.f, this.startTime = f, time.Now()
+	if d > 0 {
+		this.timer = time.AfterFunc(d, func() {
+			if file, err := this.stop(); err == nil {
+				logger.Logger.Infof("cpu profile saved to %v", file)
+			}
+		})
+	}
+	return nil
+}
+
+func (this *cpuProfiler) stop() (string, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.f == nil {
+		return "", errors.New("cpu profile not running")
+	}
+	pprof.StopCPUProfile()
+	if this.timer != nil {
+		this.timer.Stop()
+		this.timer = nil
+	}
+	name := this.f.Name()
+	err := this.f.Close()
+	this.f = nil
+	return name, err
+}
+
+func (this *cpuProfiler) status() *Table {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	t := NewTable("running", "file", "elapsed")
+	if this.f == nil {
+		t.AddRow(false, "", "")
+	} else {
+		t.AddRow(true, this.f.Name(), utils.ToS(time.Since(this.startTime)))
+	}
+	return t
+}
@@ -0,0 +1,68 @@
+package cmdline
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/module"
+	"github.com/acoderup/goserver.v1/core/profile"
+	"github.com/acoderup/goserver.v1/core/utils"
+)
+
+func ms(n int64) string {
+	return utils.ToS(time.Duration(n) * time.Millisecond)
+}
+
+func init() {
+	RegisteCommand(&Command{
+		Name:  "stats",
+		Usage: "show time statistics of commands, timers and modules",
+		Flags: []*Flag{
+			{Name: "sort", Type: FlagType_Enum, Enum: []string{"name", "times", "total", "max"}, Default: "total", Usage: "sort order"},
+			{Name: "filter", Usage: "only show names containing the filter"},
+		},
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			stats := profile.GetStats()
+			elements := make([]profile.TimeElement, 0, len(stats))
+			for _, te := range stats {
+				if f := ctx.String("filter"); f != "" && !strings.Contains(strings.ToLower(te.Name), strings.ToLower(f)) {
+					continue
+				}
+				elements = append(elements, te)
+			}
+			sort.Slice(elements, func(i, j int) bool {
+				a, b := elements[i], elements[j]
+				switch ctx.String("sort") {
+				case "name":
+					return a.Name < b.Name
+				case "times":
+					return a.Times > b.Times
+				case "max":
+					return a.MaxTick > b.MaxTick
+				}
+				return a.TotalTick > b.TotalTick
+			})
+			t := NewTable("name", "times", "total", "max", "min", "avg")
+			for _, te := range elements {
+				var avg int64
+				if te.Times > 0 {
+					avg = te.TotalTick / te.Times
+				}
+				t.AddRow(strings.ToLower(te.Name), te.Times, ms(te.TotalTick), ms(te.MaxTick), ms(te.MinTick), ms(avg))
+			}
+			return t, nil
+		},
+		Subs: []*Command{{
+			Name:  "modules",
+			Usage: "show module update budget stats",
+			Run: func(ctx *CmdContext) (interface{}, error) {
+				t := NewTable("module", "budget", "updates", "overruns", "last", "worst", "isolated", "frame", "dropped")
+				for _, s := range module.AppModule.GetUpdateStats() {
+					t.AddRow(s.Name, utils.ToS(s.Budget), s.Updates, s.Overruns, utils.ToS(s.Last), utils.ToS(s.Worst), s.Isolated, s.Frame, s.DroppedSteps)
+				}
+				return t, nil
+			},
+		}},
+	})
+}
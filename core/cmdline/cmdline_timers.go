@@ -0,0 +1,43 @@
+package cmdline
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/timer"
+	"github.com/acoderup/goserver.v1/core/utils"
+)
+
+func init() {
+	RegisteCommand(&Command{
+		Name:  "timers",
+		Usage: "show pending timers ordered by next fire time",
+		Flags: []*Flag{
+			{Name: "limit", Type: FlagType_Int, Default: "50", Usage: "max timers to show"},
+			{Name: "owner", Usage: "only show timers whose owner object path contains it"},
+		},
+		Run: func(ctx *CmdContext) (interface{}, error) {
+			infos, ok := timer.TimerModule.Snapshot(time.Second)
+			if !ok {
+				return nil, errors.New("timer module not responding")
+			}
+			nowTime := core.Now()
+			t := NewTable("handle", "owner", "action", "interval", "next", "times")
+			shown := 0
+			for _, info := range infos {
+				if owner := ctx.String("owner"); owner != "" && !strings.Contains(info.Owner, owner) {
+					continue
+				}
+				if shown >= int(ctx.Int("limit")) {
+					break
+				}
+				shown++
+				t.AddRow(info.Handle, info.Owner, info.Action, utils.ToS(info.Interval), utils.ToS(info.Next.Sub(nowTime)), info.Times)
+			}
+			ctx.Printf("total %v timers\n", len(infos))
+			return t, nil
+		},
+	})
+}
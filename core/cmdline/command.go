@@ -0,0 +1,437 @@
+package cmdline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 结构化的命令：声明带类型的参数和子命令，自动生成帮助，执行结果可以作为结构化数据返回
+//
+//	cmdline.RegisteCommand(&cmdline.Command{
+//		Name:  "kick",
+//		Usage: "kick a player",
+//		Flags: []*cmdline.Flag{
+//			{Name: "id", Type: cmdline.FlagType_Int, Required: true},
+//			{Name: "reason", Type: cmdline.FlagType_Enum, Enum: []string{"cheat", "gm"}, Default: "gm"},
+//		},
+//		Run: func(ctx *cmdline.CmdContext) (interface{}, error) { ... },
+//	})
+//
+// 参数写法: -name=value, -name value, --name value, name=value，bool参数可以只写 -name
+
+const (
+	FlagType_String FlagType = iota
+	FlagType_Int
+	FlagType_Duration
+	FlagType_Bool
+	FlagType_Enum
+	//逗号分隔的列表
+	FlagType_List
+)
+
+type FlagType int
+
+var flagTypeNames = []string{"string", "int", "duration", "bool", "enum", "list"}
+
+func (t FlagType) String() string {
+	if t >= 0 && int(t) < len(flagTypeNames) {
+		return flagTypeNames[t]
+	}
+	return "unknown"
+}
+
+var ErrHelp = errors.New("help requested")
+
+type Flag struct {
+	Name     string
+	Short    string
+	Type     FlagType
+	Usage    string
+	Default  string
+	Required bool
+	//FlagType_Enum可选的值
+	Enum []string
+	//解析后的额外校验，v的类型与Type对应: string, int64, time.Duration, bool, string, []string
+	Validate func(v interface{}) error
+}
+
+// parse 把字符串转换为参数类型对应的值
+func (f *Flag) parse(s string) (interface{}, error) {
+	var v interface{}
+	switch f.Type {
+	case FlagType_Int:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("flag -%v: invalid int %q", f.Name, s)
+		}
+		v = n
+	case FlagType_Duration:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("flag -%v: invalid duration %q", f.Name, s)
+		}
+		v = d
+	case FlagType_Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("flag -%v: invalid bool %q", f.Name, s)
+		}
+		v = b
+	case FlagType_Enum:
+		found := false
+		for _, e := range f.Enum {
+			if e == s {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("flag -%v: %q not in [%v]", f.Name, s, strings.Join(f.Enum, "|"))
+		}
+		v = s
+	case FlagType_List:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v = list
+	default:
+		v = s
+	}
+	if f.Validate != nil {
+		if err := f.Validate(v); err != nil {
+			return nil, fmt.Errorf("flag -%v: %v", f.Name, err)
+		}
+	}
+	return v, nil
+}
+
+type Command struct {
+	Name string
+	//一行说明，显示在help的命令列表中
+	Usage string
+	//详细说明
+	Desc  string
+	Flags []*Flag
+	Subs  []*Command
+	//有子命令时可以为空，此时显示帮助
+	Run func(ctx *CmdContext) (interface{}, error)
+}
+
+// CmdContext 命令执行的上下文，命令的文本输出写到Out
+type CmdContext struct {
+	Out  io.Writer
+	Cmd  *Command
+	Path []string
+	//参数之外的位置参数
+	Args   []string
+	values map[string]interface{}
+}
+
+func (c *CmdContext) Has(name string) bool {
+	_, exist := c.values[name]
+	return exist
+}
+
+func (c *CmdContext) String(name string) string {
+	s, _ := c.values[name].(string)
+	return s
+}
+
+func (c *CmdContext) Int(name string) int64 {
+	n, _ := c.values[name].(int64)
+	return n
+}
+
+func (c *CmdContext) Duration(name string) time.Duration {
+	d, _ := c.values[name].(time.Duration)
+	return d
+}
+
+func (c *CmdContext) Bool(name string) bool {
+	b, _ := c.values[name].(bool)
+	return b
+}
+
+func (c *CmdContext) List(name string) []string {
+	l, _ := c.values[name].([]string)
+	return l
+}
+
+func (c *CmdContext) Printf(format string, args ...interface{}) {
+	fmt.Fprintf(c.Out, format, args...)
+}
+
+func (c *CmdContext) Println(args ...interface{}) {
+	fmt.Fprintln(c.Out, args...)
+}
+
+// resolve 根据参数找到要执行的子命令
+func (this *Command) resolve(args []string) (*Command, []string, []string) {
+	cmd, path := this, []string{this.Name}
+	for len(args) > 0 {
+		sub := cmd.getSub(args[0])
+		if sub == nil {
+			break
+		}
+		cmd, path, args = sub, append(path, sub.Name), args[1:]
+	}
+	return cmd, path, args
+}
+
+func (this *Command) getSub(name string) *Command {
+	for _, sub := range this.Subs {
+		if strings.EqualFold(sub.Name, name) {
+			return sub
+		}
+	}
+	return nil
+}
+
+func (this *Command) getFlag(name string) *Flag {
+	for _, f := range this.Flags {
+		if f.Name == name || (f.Short != "" && f.Short == name) {
+			return f
+		}
+	}
+	return nil
+}
+
+// parseFlags 解析参数，未声明的-name参数报错，key=value形式只有声明过的key才当作参数
+func (this *Command) parseFlags(ctx *CmdContext, args []string) error {
+	ctx.values = make(map[string]interface{})
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var name, value string
+		hasValue := false
+		switch {
+		case arg == "-h" || arg == "--help" || arg == "-help":
+			return ErrHelp
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			name = strings.TrimLeft(arg, "-")
+			if idx := strings.Index(name, "="); idx >= 0 {
+				name, value, hasValue = name[:idx], name[idx+1:], true
+			}
+			if this.getFlag(name) == nil {
+				return fmt.Errorf("unknown flag -%v", name)
+			}
+		case strings.Contains(arg, "="):
+			idx := strings.Index(arg, "=")
+			if this.getFlag(arg[:idx]) == nil {
+				ctx.Args = append(ctx.Args, arg)
+				continue
+			}
+			name, value, hasValue = arg[:idx], arg[idx+1:], true
+		default:
+			ctx.Args = append(ctx.Args, arg)
+			continue
+		}
+		f := this.getFlag(name)
+		if !hasValue {
+			if f.Type == FlagType_Bool && (i+1 >= len(args) || !isBoolString(args[i+1])) {
+				value = "true"
+			} else if i+1 < len(args) {
+				i++
+				value = args[i]
+			} else {
+				return fmt.Errorf("flag -%v needs a value", f.Name)
+			}
+		}
+		v, err := f.parse(value)
+		if err != nil {
+			return err
+		}
+		ctx.values[f.Name] = v
+	}
+	for _, f := range this.Flags {
+		if _, exist := ctx.values[f.Name]; exist {
+			continue
+		}
+		if f.Required {
+			return fmt.Errorf("flag -%v is required", f.Name)
+		}
+		if f.Default != "" {
+			v, err := f.parse(f.Default)
+			if err != nil {
+				return err
+			}
+			ctx.values[f.Name] = v
+		}
+	}
+	return nil
+}
+
+func isBoolString(s string) bool {
+	_, err := strconv.ParseBool(s)
+	return err == nil
+}
+
+// run 解析参数并执行，参数错误时输出帮助，出错时输出"error: <err>"
+func (this *Command) run(w io.Writer, args []string) (interface{}, error) {
+	cmd, path, rest := this.resolve(args)
+	ctx := &CmdContext{Out: w, Cmd: cmd, Path: path}
+	if err := cmd.parseFlags(ctx, rest); err != nil {
+		if err != ErrHelp {
+			fmt.Fprintln(w, "error:", err)
+		}
+		cmd.writeHelp(w, path)
+		if err == ErrHelp {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if cmd.Run == nil {
+		cmd.writeHelp(w, path)
+		if len(ctx.Args) > 0 {
+			return nil, fmt.Errorf("unknown subcommand %v", ctx.Args[0])
+		}
+		return nil, nil
+	}
+	data, err := cmd.Run(ctx)
+	if err != nil {
+		fmt.Fprintln(w, "error:", err)
+	}
+	return data, err
+}
+
+// writeHelp 根据声明生成帮助
+func (this *Command) writeHelp(w io.Writer, path []string) {
+	name := strings.Join(path, " ")
+	usage := "usage: " + name
+	if len(this.Subs) > 0 {
+		usage += " <command>"
+	}
+	if len(this.Flags) > 0 {
+		usage += " [flags]"
+	}
+	fmt.Fprintln(w, usage)
+	if this.Usage != "" {
+		fmt.Fprintln(w, "\t", this.Usage)
+	}
+	if this.Desc != "" {
+		fmt.Fprintln(w, this.Desc)
+	}
+	if len(this.Subs) > 0 {
+		fmt.Fprintln(w, "Commands:")
+		for _, sub := range this.Subs {
+			fmt.Fprintf(w, "\t%-16s %s\n", sub.Name, sub.Usage)
+		}
+	}
+	if len(this.Flags) > 0 {
+		fmt.Fprintln(w, "Flags:")
+		for _, f := range this.Flags {
+			fmt.Fprintf(w, "\t%-24s %s\n", f.synopsis(), f.describe())
+		}
+	}
+}
+
+func (f *Flag) synopsis() string {
+	s := "-" + f.Name
+	if f.Short != "" {
+		s = "-" + f.Short + ", " + s
+	}
+	if f.Type != FlagType_Bool {
+		s += " " + f.Type.String()
+	}
+	return s
+}
+
+func (f *Flag) describe() string {
+	s := f.Usage
+	if f.Type == FlagType_Enum {
+		s += fmt.Sprintf(" (one of %v)", strings.Join(f.Enum, "|"))
+	}
+	if f.Required {
+		s += " (required)"
+	} else if f.Default != "" {
+		s += fmt.Sprintf(" (default %v)", f.Default)
+	}
+	return strings.TrimSpace(s)
+}
+
+// commandExecuter 把Command适配为cmdExecuter，与旧的命令共用注册表、help和远程控制台
+type commandExecuter struct {
+	cmd *Command
+}
+
+func (this *commandExecuter) Execute(args []string) {
+	this.ExecuteTo(os.Stdout, args)
+}
+
+func (this *commandExecuter) ExecuteTo(w io.Writer, args []string) {
+	data, err := this.cmd.run(w, args)
+	if err != nil {
+		return
+	}
+	writeData(w, data)
+}
+
+func (this *commandExecuter) ShowUsage() {
+	this.ShowUsageTo(os.Stdout)
+}
+
+func (this *commandExecuter) ShowUsageTo(w io.Writer) {
+	this.cmd.writeHelp(w, []string{this.cmd.Name})
+}
+
+// RegisteCommand 注册结构化的命令
+func RegisteCommand(cmd *Command) {
+	if cmd == nil || cmd.Name == "" {
+		panic("RegisteCommand command name is empty")
+	}
+	RegisteCmd(cmd.Name, &commandExecuter{cmd: cmd})
+}
+
+// CmdResult 命令的执行结果，Output为命令写出的文本，Data为结构化命令返回的数据
+type CmdResult struct {
+	Command string      `json:"command"`
+	Output  string      `json:"output,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// runCmd 执行命令，结构化的命令返回数据，其它命令只有文本输出
+func runCmd(w io.Writer, exec cmdExecuter, args []string) (interface{}, error) {
+	if ce, ok := exec.(*commandExecuter); ok {
+		return ce.cmd.run(w, args)
+	}
+	executeTo(w, exec, args)
+	return nil, nil
+}
+
+// writeData 把结构化数据输出为文本
+func writeData(w io.Writer, data interface{}) {
+	switch v := data.(type) {
+	case nil:
+	case string:
+		fmt.Fprintln(w, v)
+	case fmt.Stringer:
+		fmt.Fprintln(w, v.String())
+	default:
+		buf, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			fmt.Fprintln(w, v)
+			return
+		}
+		fmt.Fprintln(w, string(buf))
+	}
+}
+
+// commandNames 所有命令按名字排序
+func commandNames() []string {
+	names := make([]string, 0, len(cmdpool))
+	for k := range cmdpool {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -1,20 +1,38 @@
 package cmdline
 
 import (
+	"io"
+	"os"
+
 	"github.com/acoderup/goserver.v1/core/basic"
 )
 
 type cmdlineCommand struct {
 	exec cmdExecuter
 	args []string
+	w    io.Writer
+	done chan struct{}
+	data interface{}
+	err  error
 }
 
 func (cmd *cmdlineCommand) Done(o *basic.Object) error {
 	defer o.ProcessSeqnum()
-	cmd.exec.Execute(cmd.args)
+	if cmd.done != nil {
+		defer close(cmd.done)
+	}
+	cmd.data, cmd.err = runCmd(cmd.w, cmd.exec, cmd.args)
+	if cmd.done == nil && cmd.err == nil {
+		writeData(cmd.w, cmd.data)
+	}
 	return nil
 }
 
 func PostCmd(p *basic.Object, exec cmdExecuter, args []string) bool {
-	return p.SendCommand(&cmdlineCommand{exec: exec, args: args}, true)
+	return PostCmdTo(p, os.Stdout, exec, args)
+}
+
+// PostCmdTo 在p中执行命令，输出写到w
+func PostCmdTo(p *basic.Object, w io.Writer, exec cmdExecuter, args []string) bool {
+	return p.SendCommand(&cmdlineCommand{exec: exec, args: args, w: w}, true)
 }
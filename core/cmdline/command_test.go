@@ -0,0 +1,159 @@
+package cmdline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/basic"
+)
+
+type kickResult struct {
+	Id      int64
+	Reason  string
+	Ban     time.Duration
+	Notify  bool
+	Servers []string
+}
+
+func init() {
+	RegisteCommand(&Command{
+		Name:  "player",
+		Usage: "player management",
+		Subs: []*Command{{
+			Name:  "kick",
+			Usage: "kick a player",
+			Flags: []*Flag{
+				{Name: "id", Type: FlagType_Int, Required: true, Validate: func(v interface{}) error {
+					if v.(int64) <= 0 {
+						return errors.New("must be positive")
+					}
+					return nil
+				}},
+				{Name: "reason", Short: "r", Type: FlagType_Enum, Enum: []string{"cheat", "gm"}, Default: "gm"},
+				{Name: "ban", Type: FlagType_Duration},
+				{Name: "notify", Type: FlagType_Bool},
+				{Name: "servers", Type: FlagType_List},
+			},
+			Run: func(ctx *CmdContext) (interface{}, error) {
+				return &kickResult{Id: ctx.Int("id"), Reason: ctx.String("reason"), Ban: ctx.Duration("ban"), Notify: ctx.Bool("notify"), Servers: ctx.List("servers")}, nil
+			},
+		}, {
+			Name:  "fail",
+			Usage: "always fail",
+			Run: func(ctx *CmdContext) (interface{}, error) {
+				return nil, errors.New("player offline")
+			},
+		}},
+	})
+}
+
+func TestCommandFlags(t *testing.T) {
+	srv := NewAdminServer()
+	res := srv.Exec("player kick -id=7 -r cheat --ban 1h -notify servers=s1,s2")
+	if res.Error != "" {
+		t.Fatalf("exec error=%v output=%v", res.Error, res.Output)
+	}
+	kr, ok := res.Data.(*kickResult)
+	if !ok || kr.Id != 7 || kr.Reason != "cheat" || kr.Ban != time.Hour || !kr.Notify || strings.Join(kr.Servers, ",") != "s1,s2" {
+		t.Fatalf("unexpected result %+v", res.Data)
+	}
+	if kr := srv.Exec("player kick id=3").Data.(*kickResult); kr.Reason != "gm" || kr.Notify {
+		t.Fatalf("default not applied %+v", kr)
+	}
+
+	for line, want := range map[string]string{
+		"player kick":                  "required",
+		"player kick -id=0":            "positive",
+		"player kick -id=1 -r=spam":    "not in",
+		"player kick -id=x":            "invalid int",
+		"player kick -id=1 -ban=soon":  "invalid duration",
+		"player kick -id=1 -unknown=1": "unknown flag",
+	} {
+		res := srv.Exec(line)
+		if !strings.Contains(res.Error, want) || !strings.Contains(res.Output, "usage: player kick") {
+			t.Errorf("%v: error=%q output=%q", line, res.Error, res.Output)
+		}
+	}
+}
+
+func TestCommandHelp(t *testing.T) {
+	buf := &bytes.Buffer{}
+	helpExecuter{}.ExecuteTo(buf, []string{"player", "kick"})
+	for _, want := range []string{"usage: player kick [flags]", "-r, -reason enum", "(one of cheat|gm)", "(required)"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("help missing %q:\n%v", want, buf)
+		}
+	}
+	buf.Reset()
+	helpExecuter{}.ExecuteTo(buf, nil)
+	if !strings.Contains(buf.String(), "player management") {
+		t.Fatalf("command list missing usage:\n%v", buf)
+	}
+	if out := NewAdminServer().Exec("player").Output; !strings.Contains(out, "kick") {
+		t.Fatalf("command without Run should show subcommands:\n%v", out)
+	}
+}
+
+func TestCommandHTTP(t *testing.T) {
+	h := NewAdminServer().HTTPHandler("secret")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/?cmd=player+kick+-id=9", nil))
+	if rec.Code != 401 {
+		t.Fatalf("unauthorized request code=%v", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"cmd":"player kick -id=9"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	h.ServeHTTP(rec, req)
+	var res struct {
+		Command string
+		Data    kickResult
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || rec.Code != 200 || res.Command != "player" || res.Data.Id != 9 {
+		t.Fatalf("code=%v body=%v err=%v", rec.Code, rec.Body, err)
+	}
+
+	//没有token时只接受回环地址
+	h = NewAdminServer().HTTPHandler("")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/?cmd=player+kick+-id=9", nil))
+	if rec.Code != 403 {
+		t.Fatalf("non loopback request without token code=%v", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/?cmd=player+kick+-id=9", nil)
+	req.RemoteAddr = "127.0.0.1:50000"
+	h.ServeHTTP(rec, req)
+	if rec.Code != 200 {
+		t.Fatalf("loopback request without token code=%v body=%v", rec.Code, rec.Body)
+	}
+}
+
+func TestCommandRunError(t *testing.T) {
+	exec, _ := getCmd("player")
+	buf := &bytes.Buffer{}
+	exec.(*commandExecuter).ExecuteTo(buf, []string{"fail"})
+	if buf.String() != "error: player offline\n" {
+		t.Fatalf("ExecuteTo output=%q", buf)
+	}
+
+	obj := basic.NewObject(core.ObjId_CoreId, "cmd-error", basic.Options{MaxDone: 16, QueueBacklog: 16}, nil)
+	core.LaunchChild(obj)
+	buf.Reset()
+	PostCmdTo(obj, buf, exec, []string{"fail"})
+	//同一个object中的命令按顺序执行，Exec返回时前面的命令已经完成
+	srv := NewAdminServer()
+	srv.Executor = obj
+	if res := srv.Exec("player fail"); res.Error != "player offline" {
+		t.Fatalf("admin error=%q", res.Error)
+	}
+	if buf.String() != "error: player offline\n" {
+		t.Fatalf("PostCmdTo output=%q", buf)
+	}
+}
@@ -1,6 +1,8 @@
 package cmdline
 
 import (
+	"time"
+
 	"github.com/acoderup/goserver.v1/core"
 )
 
@@ -8,6 +10,16 @@ var Config = Configuration{}
 
 type Configuration struct {
 	SupportCmdLine bool
+	//远程控制台的unix socket路径，为空不监听
+	AdminUnix string
+	//远程控制台的tcp地址，只允许回环地址，必须设置AdminToken
+	AdminTCP string
+	//远程控制台的认证token，unix socket依靠文件权限保护，可以不设置
+	AdminToken string
+	//连接空闲超时(毫秒)
+	AdminIdleTimeout time.Duration
+	//单条命令的执行超时(毫秒)
+	AdminExecTimeout time.Duration
 }
 
 func (c *Configuration) Name() string {
@@ -15,6 +27,16 @@ func (c *Configuration) Name() string {
 }
 
 func (c *Configuration) Init() error {
+	if c.AdminIdleTimeout <= 0 {
+		c.AdminIdleTimeout = DefaultAdminIdleTimeout
+	} else {
+		c.AdminIdleTimeout = time.Millisecond * c.AdminIdleTimeout
+	}
+	if c.AdminExecTimeout <= 0 {
+		c.AdminExecTimeout = DefaultAdminExecTimeout
+	} else {
+		c.AdminExecTimeout = time.Millisecond * c.AdminExecTimeout
+	}
 	return nil
 }
 
@@ -24,4 +46,15 @@ func (c *Configuration) Close() error {
 
 func init() {
 	core.RegistePackage(&Config)
+	core.RegisteHook(core.HOOK_BEFORE_START, func() error {
+		if Config.AdminUnix == "" && Config.AdminTCP == "" {
+			return nil
+		}
+		return DefaultAdmin.Start(Config.AdminUnix, Config.AdminTCP, Config.AdminToken)
+	})
+	core.RegisteHook(core.HOOK_AFTER_STOP, func() error {
+		//exit命令在执行命令的Object中触发该钩子，不能等待连接退出
+		DefaultAdmin.Close()
+		return nil
+	})
 }
@@ -0,0 +1,70 @@
+package cmdline
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Table 表格形式的命令结果，文本输出时按列对齐，结构化输出为表头和行
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+func NewTable(header ...string) *Table {
+	return &Table{Header: header}
+}
+
+// AddRow 添加一行，值使用%v格式化
+func (t *Table) AddRow(cols ...interface{}) {
+	row := make([]string, len(cols))
+	for i, c := range cols {
+		row[i] = fmt.Sprint(c)
+	}
+	t.Rows = append(t.Rows, row)
+}
+
+func (t *Table) String() string {
+	widths := make([]int, len(t.Header))
+	measure := func(row []string) {
+		for i, c := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(c); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	measure(t.Header)
+	for _, row := range t.Rows {
+		measure(row)
+	}
+	sb := &strings.Builder{}
+	write := func(row []string) {
+		sb.WriteString("|")
+		for i, w := range widths {
+			c := ""
+			if i < len(row) {
+				c = row[i]
+			}
+			sb.WriteString(" ")
+			sb.WriteString(c)
+			sb.WriteString(strings.Repeat(" ", w-utf8.RuneCountInString(c)))
+			sb.WriteString(" |")
+		}
+		sb.WriteString("\n")
+	}
+	write(t.Header)
+	sb.WriteString("|")
+	for _, w := range widths {
+		sb.WriteString(strings.Repeat("-", w+2))
+		sb.WriteString("|")
+	}
+	sb.WriteString("\n")
+	for _, row := range t.Rows {
+		write(row)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
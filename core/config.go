@@ -9,6 +9,8 @@ var Config = Configuration{}
 type Configuration struct {
 	MaxProcs int
 	Debug    bool
+	//监视配置文件，变化时自动重新加载支持热加载的功能包
+	WatchConfig bool
 }
 
 func (c *Configuration) Name() string {
@@ -20,10 +22,14 @@ func (c *Configuration) Init() error {
 		c.MaxProcs = 1
 	}
 	runtime.GOMAXPROCS(c.MaxProcs)
+	if c.WatchConfig {
+		return WatchConfig()
+	}
 	return nil
 }
 
 func (c *Configuration) Close() error {
+	StopWatchConfig()
 	return nil
 }
 
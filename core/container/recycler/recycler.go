@@ -3,6 +3,7 @@ package recycler
 
 import (
 	"container/list"
+	"sync/atomic"
 	"time"
 )
 
@@ -19,7 +20,7 @@ type Recycler struct {
 	ocf     func() interface{}
 	que     *list.List
 	timeout *time.Timer
-	makecnt int
+	makecnt int64 //run中修改，Stats中读取，需要原子操作
 	name    string
 	running bool
 }
@@ -46,7 +47,7 @@ func (this *Recycler) run() {
 	for this.running {
 		if this.que.Len() == 0 {
 			this.que.PushFront(element{when: time.Now(), data: this.ocf()})
-			this.makecnt++
+			atomic.AddInt64(&this.makecnt, 1)
 		}
 
 		this.timeout.Reset(time.Minute)
@@ -65,7 +66,7 @@ func (this *Recycler) run() {
 				if time.Since(e.Value.(element).when) > time.Minute {
 					this.que.Remove(e)
 					e.Value = nil
-					this.makecnt--
+					atomic.AddInt64(&this.makecnt, -1)
 				}
 				e = n
 			}
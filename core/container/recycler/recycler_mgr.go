@@ -3,7 +3,9 @@ package recycler
 import (
 	"fmt"
 	"io"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 var RecyclerMgr = &recyclerMgr{
@@ -36,10 +38,28 @@ func (this *recyclerMgr) CloseAll() {
 	}
 }
 
+// RecyclerStats 回收器的统计
+type RecyclerStats struct {
+	Name  string
+	Alloc int
+}
+
+// Stats 所有回收器的统计，按名字排序
+func (this *recyclerMgr) Stats() []RecyclerStats {
+	this.lock.Lock()
+	stats := make([]RecyclerStats, 0, len(this.recyclers))
+	for _, r := range this.recyclers {
+		stats = append(stats, RecyclerStats{Name: r.name, Alloc: int(atomic.LoadInt64(&r.makecnt))})
+	}
+	this.lock.Unlock()
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
 func (this *recyclerMgr) Dump(w io.Writer) {
 	this.lock.Lock()
 	for _, r := range this.recyclers {
-		w.Write([]byte(fmt.Sprintf("(%s) alloc object (%d)", r.name, r.makecnt)))
+		w.Write([]byte(fmt.Sprintf("(%s) alloc object (%d)", r.name, atomic.LoadInt64(&r.makecnt))))
 	}
 	this.lock.Unlock()
 }
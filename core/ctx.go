@@ -12,6 +12,8 @@ import (
 const (
 	HOOK_BEFORE_START int = iota
 	HOOK_AFTER_STOP
+	//重新加载配置，由reload信号或者命令触发
+	HOOK_CONFIG_RELOAD
 	HOOK_MAX
 )
 
@@ -1,9 +1,23 @@
 package core
 
+import "sync/atomic"
+
 const (
 	ObjId_RootId int = iota
 	ObjId_CoreId
 	ObjId_ExecutorId
 	ObjId_TimerId
 	ObjId_ProfileId
+	ObjId_NetlibId
 )
+
+// ObjId_DynamicStart 运行时动态创建的对象从这里开始分配id，见NextObjId
+// 小于它的id保留给上面固定的对象和业务自己指定的对象
+const ObjId_DynamicStart = 1 << 20
+
+var objIdSeq int32 = ObjId_DynamicStart - 1
+
+// NextObjId 分配动态创建的对象id，进程内唯一
+func NextObjId() int {
+	return int(atomic.AddInt32(&objIdSeq, 1))
+}
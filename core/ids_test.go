@@ -0,0 +1,10 @@
+package core
+
+import "testing"
+
+func TestNextObjId(t *testing.T) {
+	a, b := NextObjId(), NextObjId()
+	if a < ObjId_DynamicStart || b <= a {
+		t.Fatalf("ids a=%v b=%v", a, b)
+	}
+}
@@ -46,64 +46,33 @@ func RegisterConfigEncryptor(h viperx.ConfigFileEncryptorHook) {
 }
 
 // LoadPackages 加载功能包
+// configFile为基础配置文件，还会合并功能包的默认配置、环境覆盖文件、环境变量和命令行参数，见ConfigSource
 func LoadPackages(configFile string) {
 	val := strings.Split(configFile, ".")
 	if len(val) != 2 {
 		panic("config file name error")
 	}
+	ConfigSource.Name, ConfigSource.Type, ConfigSource.Data = val[0], val[1], nil
+	loadPackages(configFile)
+}
 
-	vp := viperx.GetViper(val[0], val[1])
-
-	var err error
-	var notFoundConfig []string
-	var notFoundPackage []string
-	for k := range vp.AllSettings() {
-		if _, ok := packages[k]; !ok {
-			notFoundPackage = append(notFoundPackage, k)
-			continue
-		}
-
-		name := k
-		pkg := packages[k]
-		if err = vp.UnmarshalKey(k, pkg); err != nil {
-			logger.Logger.Errorf("Package %s: Error while unmarshalling from config file %s: %v", name, configFile, err)
-			continue
-		}
-
-		if err = pkg.Init(); err != nil {
-			logger.Logger.Errorf("Package %s: Error while initializing from config file %s: %v", name, configFile, err)
-			continue
-		}
-
-		packagesLoaded[pkg.Name()] = true
-		logger.Logger.Infof("package [%16s] load success", pkg.Name())
-	}
-
-	for k := range packages {
-		if !IsPackageLoaded(k) {
-			notFoundConfig = append(notFoundConfig, k)
-		}
-	}
-
-	if len(notFoundConfig) > 0 {
-		logger.Logger.Warnf("package load success, not found config: %v", notFoundConfig)
-	}
+// LoadPackagesAuto 使用内置的基础配置加载功能包
+func LoadPackagesAuto() {
+	ConfigSource.Name, ConfigSource.Type, ConfigSource.Data = "config", "json", viperx.BuiltinConfig()
+	loadPackages("built-in config")
+}
 
-	if len(notFoundPackage) > 0 {
-		logger.Logger.Warnf("package load success, not found package: %v", notFoundPackage)
+func loadPackages(configFile string) {
+	ConfigSource.Defaults = packageDefaults()
+	vp, err := ConfigSource.Load()
+	if err != nil {
+		panic(err.Error())
 	}
-}
+	reloadLock.Lock()
+	configLoaded = true
+	effectiveSettings = vp.AllSettings()
+	reloadLock.Unlock()
 
-// LoadPackages 加载功能包
-func LoadPackagesAuto() {
-	//val := strings.Split(configFile, ".")
-	//if len(val) != 2 {
-	//	panic("config file name error")
-	//}
-	configFile := "config.json"
-	vp := viperx.GetViperByString()
-
-	var err error
 	var notFoundConfig []string
 	var notFoundPackage []string
 	for k := range vp.AllSettings() {
@@ -125,6 +94,9 @@ func LoadPackagesAuto() {
 		}
 
 		packagesLoaded[pkg.Name()] = true
+		reloadLock.Lock()
+		appliedSettings[k] = vp.Get(k)
+		reloadLock.Unlock()
 		logger.Logger.Infof("package [%16s] load success", pkg.Name())
 	}
 
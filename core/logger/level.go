@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/cihub/seelog"
+)
+
+var (
+	configLock sync.Mutex
+	//当前使用的日志配置，调整日志级别时在它的基础上修改
+	currentConfig = data
+	seelogTagRe   = regexp.MustCompile(`<seelog[^>]*>`)
+	minLevelRe    = regexp.MustCompile(`\s(minlevel|levels)="([^"]*)"`)
+)
+
+// GetLevel 当前的最低日志级别
+func GetLevel() string {
+	configLock.Lock()
+	defer configLock.Unlock()
+	tag := seelogTagRe.FindString(currentConfig)
+	for _, m := range minLevelRe.FindAllStringSubmatch(tag, -1) {
+		if m[1] == "minlevel" {
+			return m[2]
+		}
+		return m[1] + ":" + m[2]
+	}
+	return seelog.TraceStr
+}
+
+// SetLevel 运行时调整最低日志级别，使用当前的日志配置重新创建日志
+func SetLevel(level string) error {
+	if _, ok := seelog.LogLevelFromString(level); !ok {
+		return fmt.Errorf("unknown log level %q", level)
+	}
+	configLock.Lock()
+	defer configLock.Unlock()
+	loc := seelogTagRe.FindStringIndex(currentConfig)
+	if loc == nil {
+		return fmt.Errorf("seelog element not found in logger config")
+	}
+	tag := minLevelRe.ReplaceAllString(currentConfig[loc[0]:loc[1]], "")
+	tag = tag[:len("<seelog")] + fmt.Sprintf(` minlevel="%s"`, level) + tag[len("<seelog"):]
+	cfg := currentConfig[:loc[0]] + tag + currentConfig[loc[1]:]
+	newLogger, err := seelog.LoggerFromConfigAsString(cfg)
+	if err != nil {
+		return err
+	}
+	Logger = newLogger
+	seelog.ReplaceLogger(Logger)
+	currentConfig = cfg
+	return nil
+}
+
+func setCurrentConfig(fileName string) {
+	buf, err := os.ReadFile(fileName)
+	if err != nil {
+		return
+	}
+	configLock.Lock()
+	currentConfig = string(buf)
+	configLock.Unlock()
+}
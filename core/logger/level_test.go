@@ -0,0 +1,19 @@
+package logger
+
+import (
+	"testing"
+)
+
+func TestSetLevel(t *testing.T) {
+	old := GetLevel()
+	defer SetLevel(old)
+	if err := SetLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+	if lv := GetLevel(); lv != "warn" {
+		t.Fatalf("level=%v", lv)
+	}
+	if err := SetLevel("verbose"); err == nil {
+		t.Fatal("unknown level accepted")
+	}
+}
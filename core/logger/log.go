@@ -29,6 +29,7 @@ func Reload(fileName string) error {
 	if newLogger != nil {
 		Logger = newLogger
 		seelog.ReplaceLogger(Logger)
+		setCurrentConfig(fileName)
 		fmt.Println("Reload success")
 	}
 	return nil
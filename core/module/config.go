@@ -11,6 +11,20 @@ var Config = Configuration{}
 
 type Configuration struct {
 	Options basic.Options
+	//异步初始化模块的默认超时时间(毫秒)
+	InitTimeout time.Duration
+	//关闭所有模块的超时时间(毫秒)，超时未退出的模块会被强制退出
+	ShutdownTimeout time.Duration
+	//模块每次Update允许的耗时(毫秒)，默认为一个心跳周期
+	UpdateBudget time.Duration
+	//连续超时的模块是否迁移到独立的协程中Update
+	IsolateSlowModule bool
+	//连续超时多少次后迁移
+	IsolateThreshold int
+	//固定步长模块的默认步长(毫秒)，默认为一个心跳周期
+	FixedStep time.Duration
+	//固定步长模块一次心跳最多补帧的数量
+	MaxCatchUpSteps int
 }
 
 func (c *Configuration) Name() string {
@@ -29,10 +43,43 @@ func (c *Configuration) Init() error {
 	} else {
 		c.Options.Interval = time.Millisecond * c.Options.Interval
 	}
+	if c.InitTimeout <= 0 {
+		c.InitTimeout = time.Minute
+	} else {
+		c.InitTimeout = time.Millisecond * c.InitTimeout
+	}
+	if c.ShutdownTimeout <= 0 {
+		c.ShutdownTimeout = time.Minute
+	} else {
+		c.ShutdownTimeout = time.Millisecond * c.ShutdownTimeout
+	}
+	if c.UpdateBudget <= 0 {
+		c.UpdateBudget = c.Options.Interval
+	} else {
+		c.UpdateBudget = time.Millisecond * c.UpdateBudget
+	}
+	if c.IsolateThreshold <= 0 {
+		c.IsolateThreshold = 10
+	}
+	if c.FixedStep <= 0 {
+		c.FixedStep = c.Options.Interval
+	} else {
+		c.FixedStep = time.Millisecond * c.FixedStep
+	}
+	if c.MaxCatchUpSteps <= 0 {
+		c.MaxCatchUpSteps = 5
+	}
 
 	return nil
 }
 
+// Defaults 默认配置，配置文件中没有module时也会加载
+func (c *Configuration) Defaults() map[string]interface{} {
+	return map[string]interface{}{
+		"Options": map[string]interface{}{"QueueBacklog": 1024, "MaxDone": 1024, "Interval": 10},
+	}
+}
+
 func (c *Configuration) Close() error {
 	return nil
 }
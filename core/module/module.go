@@ -1,8 +1,11 @@
 package module
 
+import "time"
+
 const (
 	ModuleName_Net      string = "net-module"
 	ModuleName_Transact        = "dtc-module"
+	ModuleName_Cluster         = "cluster-module"
 )
 
 type Module interface {
@@ -11,3 +14,36 @@ type Module interface {
 	Update()
 	Shutdown()
 }
+
+// ModuleDepender 可选接口，声明模块依赖的其它模块
+// 被依赖的模块先初始化、后关闭，priority只在没有依赖关系的模块之间决定顺序
+type ModuleDepender interface {
+	DependModules() []string
+}
+
+// AsyncInitModule 可选接口，异步初始化的模块
+// Init 中发起初始化后立即返回，初始化完成后调用 InitDone 通知结果
+// 模块就绪前不会调用它的Update，依赖它的模块也不会初始化
+// InitTimeout 返回0时使用配置中的默认超时时间
+type AsyncInitModule interface {
+	InitTimeout() time.Duration
+}
+
+// ShutdownTimeouter 可选接口，模块自己的关闭超时时间
+// 从调用模块的Shutdown开始计时，超时后模块被强制退出；返回0时只受全局超时限制
+type ShutdownTimeouter interface {
+	ShutdownTimeout() time.Duration
+}
+
+// UpdateBudgeter 可选接口，模块每次Update允许的耗时，返回0时使用配置中的默认值
+type UpdateBudgeter interface {
+	UpdateBudget() time.Duration
+}
+
+// FixedUpdater 可选接口，固定步长更新的模块
+// 实现该接口的模块不再调用Update，而是按固定步长dt调用FixedUpdate，心跳延迟时会补帧，
+// 补帧数量受MaxCatchUpSteps限制，frame为从1开始递增的帧号
+// 步长为注册模块时的tickInterval，为0时使用配置中的FixedStep
+type FixedUpdater interface {
+	FixedUpdate(dt time.Duration, frame uint64)
+}
@@ -0,0 +1,177 @@
+package module
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/acoderup/goserver.v1/core/utils"
+)
+
+// ModuleUpdateStats 模块Update耗时统计
+type ModuleUpdateStats struct {
+	Name                string
+	Budget              time.Duration
+	Updates             int64
+	Overruns            int64
+	ConsecutiveOverruns int64
+	Last                time.Duration
+	Worst               time.Duration
+	Isolated            bool
+	Frame               uint64
+	DroppedSteps        uint64
+}
+
+// moduleBudget 模块Update的耗时预算，模块被迁移到独立协程后统计会在其它协程中更新
+type moduleBudget struct {
+	lock        sync.Mutex
+	stats       ModuleUpdateStats
+	lastWarn    time.Time
+	obj         *basic.Object
+	isolated    int32
+	sinkRunning int32
+	//独立协程Update期间持有，重置模块前需要等待正在执行的Update结束
+	updLock sync.Mutex
+}
+
+func (this *moduleBudget) record(m Module, d time.Duration) {
+	budget := Config.UpdateBudget
+	if ub, ok := m.(UpdateBudgeter); ok && ub.UpdateBudget() > 0 {
+		budget = ub.UpdateBudget()
+	}
+
+	this.lock.Lock()
+	this.stats.Budget = budget
+	this.stats.Updates++
+	this.stats.Last = d
+	if d > this.stats.Worst {
+		this.stats.Worst = d
+	}
+	if budget <= 0 || d <= budget {
+		this.stats.ConsecutiveOverruns = 0
+		this.lock.Unlock()
+		return
+	}
+	this.stats.Overruns++
+	this.stats.ConsecutiveOverruns++
+	overruns := this.stats.Overruns
+	consecutive := this.stats.ConsecutiveOverruns
+	warn := time.Since(this.lastWarn) >= time.Second
+	if warn {
+		this.lastWarn = time.Now()
+	}
+	this.lock.Unlock()
+
+	if warn {
+		logger.Logger.Warnf("module [%16s] update overrun, take:%s budget:%s overruns:%v consecutive:%v",
+			m.ModuleName(), utils.ToS(d), utils.ToS(budget), overruns, consecutive)
+	}
+}
+
+func (this *moduleBudget) snapshot(name string) ModuleUpdateStats {
+	this.lock.Lock()
+	stats := this.stats
+	this.lock.Unlock()
+	stats.Name = name
+	stats.Isolated = atomic.LoadInt32(&this.isolated) != 0
+	return stats
+}
+
+func (this *moduleBudget) consecutiveOverruns() int64 {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.stats.ConsecutiveOverruns
+}
+
+func (this *ModuleEntity) isIsolated() bool {
+	return atomic.LoadInt32(&this.budget.isolated) != 0
+}
+
+// checkIsolate 模块持续超时，迁移到独立的协程中Update，保证core的心跳间隔稳定
+func (this *ModuleMgr) checkIsolate(me *ModuleEntity) {
+	if !Config.IsolateSlowModule || this.Object == nil || me.shutdowned {
+		return
+	}
+	if me.budget.consecutiveOverruns() < int64(Config.IsolateThreshold) {
+		return
+	}
+	interval := me.tickInterval
+	if interval <= 0 {
+		interval = Config.Options.Interval
+	}
+	name := me.module.ModuleName()
+	logger.Logger.Warnf("module [%16s] overrun %v times in a row, move to dedicated goroutine, interval=%v", name, Config.IsolateThreshold, interval)
+	opt := Config.Options
+	opt.Interval = interval
+	obj := basic.NewObject(core.NextObjId(), fmt.Sprintf("module_%v", name), opt, &isolatedModuleSinker{me: me})
+	obj.UserData = me.module
+	me.budget.obj = obj
+	atomic.StoreInt32(&me.budget.sinkRunning, 1)
+	atomic.StoreInt32(&me.budget.isolated, 1)
+	this.Object.LaunchChild(obj)
+}
+
+// unisolate 模块退出后销毁独立协程，重新启动的模块回到core中Update
+func (this *ModuleMgr) unisolate(me *ModuleEntity) {
+	if !me.isIsolated() {
+		return
+	}
+	atomic.StoreInt32(&me.budget.sinkRunning, 0)
+	atomic.StoreInt32(&me.budget.isolated, 0)
+	obj := me.budget.obj
+	me.budget.obj = nil
+	if obj != nil {
+		obj.Terminate(obj)
+	}
+}
+
+// GetUpdateStats 获取所有模块的Update统计，按超时次数从多到少排序
+func (this *ModuleMgr) GetUpdateStats() []ModuleUpdateStats {
+	var stats []ModuleUpdateStats
+	for e := this.modules.Front(); e != nil; e = e.Next() {
+		if me, ok := e.Value.(*ModuleEntity); ok {
+			s := me.budget.snapshot(me.module.ModuleName())
+			s.Frame, s.DroppedSteps = me.fixed.counters()
+			stats = append(stats, s)
+		}
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Overruns != stats[j].Overruns {
+			return stats[i].Overruns > stats[j].Overruns
+		}
+		return stats[i].Worst > stats[j].Worst
+	})
+	return stats
+}
+
+// GetWorstOffenders 获取超时最严重的n个模块
+func (this *ModuleMgr) GetWorstOffenders(n int) []ModuleUpdateStats {
+	var offenders []ModuleUpdateStats
+	for _, s := range this.GetUpdateStats() {
+		if s.Overruns == 0 || len(offenders) >= n {
+			break
+		}
+		offenders = append(offenders, s)
+	}
+	return offenders
+}
+
+type isolatedModuleSinker struct {
+	me *ModuleEntity
+}
+
+func (this *isolatedModuleSinker) OnStart() {}
+func (this *isolatedModuleSinker) OnStop()  {}
+func (this *isolatedModuleSinker) OnTick() {
+	this.me.budget.updLock.Lock()
+	defer this.me.budget.updLock.Unlock()
+	if atomic.LoadInt32(&this.me.budget.sinkRunning) == 0 {
+		return
+	}
+	this.me.safeUpt(core.Now())
+}
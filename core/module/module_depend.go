@@ -0,0 +1,115 @@
+package module
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+)
+
+// sortModules 按依赖关系对模块进行拓扑排序，没有依赖关系的模块之间按priority排序
+func (this *ModuleMgr) sortModules() error {
+	var pending []*ModuleEntity
+	for e := this.modules.Front(); e != nil; e = e.Next() {
+		if me, ok := e.Value.(*ModuleEntity); ok {
+			pending = append(pending, me)
+		}
+	}
+
+	for _, me := range pending {
+		for _, dep := range me.deps {
+			if _, exist := this.modulesByName[dep]; !exist {
+				return fmt.Errorf("module [%v] depend on module [%v] which is not registed", me.module.ModuleName(), dep)
+			}
+		}
+	}
+
+	sorted := list.New()
+	placed := make(map[string]bool)
+	for len(pending) > 0 {
+		// pending本身已经按priority有序，取第一个依赖全部满足的模块
+		idx := -1
+		for i, me := range pending {
+			if me.dependsPlaced(placed) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return fmt.Errorf("module dependency cycle detected: %v", this.findDependCycle(pending))
+		}
+		me := pending[idx]
+		pending = append(pending[:idx], pending[idx+1:]...)
+		placed[me.module.ModuleName()] = true
+		sorted.PushBack(me)
+	}
+	this.modules = sorted
+	return nil
+}
+
+// findDependCycle 在未能排序的模块中找出一个依赖环，用于错误提示
+func (this *ModuleMgr) findDependCycle(pending []*ModuleEntity) string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var stack []string
+	var cycle []string
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		state[name] = visiting
+		stack = append(stack, name)
+		if me := this.getModuleEntityByName(name); me != nil {
+			for _, dep := range me.deps {
+				switch state[dep] {
+				case visiting:
+					for i, n := range stack {
+						if n == dep {
+							cycle = append(append(cycle, stack[i:]...), dep)
+							return true
+						}
+					}
+				case unvisited:
+					if visit(dep) {
+						return true
+					}
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[name] = visited
+		return false
+	}
+	for _, me := range pending {
+		if state[me.module.ModuleName()] == unvisited && visit(me.module.ModuleName()) {
+			return strings.Join(cycle, " -> ")
+		}
+	}
+	return "unknown"
+}
+
+// getDependents 获取依赖指定模块的所有模块
+func (this *ModuleMgr) getDependents(name string) []*ModuleEntity {
+	var dependents []*ModuleEntity
+	for e := this.modules.Front(); e != nil; e = e.Next() {
+		if me, ok := e.Value.(*ModuleEntity); ok {
+			for _, dep := range me.deps {
+				if dep == name {
+					dependents = append(dependents, me)
+					break
+				}
+			}
+		}
+	}
+	return dependents
+}
+
+func (this *ModuleEntity) dependsPlaced(placed map[string]bool) bool {
+	for _, dep := range this.deps {
+		if !placed[dep] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package module
+
+import (
+	"strings"
+	"testing"
+)
+
+type dependModule struct {
+	name string
+	deps []string
+}
+
+func (m *dependModule) ModuleName() string      { return m.name }
+func (m *dependModule) Init()                   {}
+func (m *dependModule) Update()                 {}
+func (m *dependModule) Shutdown()               {}
+func (m *dependModule) DependModules() []string { return m.deps }
+
+func moduleOrder(mm *ModuleMgr) []string {
+	var names []string
+	for e := mm.modules.Front(); e != nil; e = e.Next() {
+		names = append(names, e.Value.(*ModuleEntity).module.ModuleName())
+	}
+	return names
+}
+
+func TestSortModules(t *testing.T) {
+	mm := newModuleMgr()
+	mm.RegisteModule(&dependModule{name: "game", deps: []string{"db", "net"}}, 0, 0)
+	mm.RegisteModule(&dependModule{name: "net"}, 0, 5)
+	mm.RegisteModule(&dependModule{name: "db"}, 0, 3)
+	mm.RegisteModule(&dependModule{name: "log"}, 0, 1)
+	if err := mm.sortModules(); err != nil {
+		t.Fatal(err)
+	}
+	order := strings.Join(moduleOrder(mm), ",")
+	if order != "log,db,net,game" {
+		t.Fatalf("unexpected init order: %v", order)
+	}
+	if d := mm.getDependents("db"); len(d) != 1 || d[0].module.ModuleName() != "game" {
+		t.Fatalf("unexpected dependents of db: %v", d)
+	}
+}
+
+func TestSortModulesCycle(t *testing.T) {
+	mm := newModuleMgr()
+	mm.RegisteModule(&dependModule{name: "a", deps: []string{"b"}}, 0, 0)
+	mm.RegisteModule(&dependModule{name: "b", deps: []string{"c"}}, 0, 0)
+	mm.RegisteModule(&dependModule{name: "c", deps: []string{"a"}}, 0, 0)
+	err := mm.sortModules()
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Fatalf("cycle not reported: %v", err)
+	}
+}
+
+func TestSortModulesMissing(t *testing.T) {
+	mm := newModuleMgr()
+	mm.RegisteModule(&dependModule{name: "a", deps: []string{"x"}}, 0, 0)
+	if err := mm.sortModules(); err == nil {
+		t.Fatal("missing dependency not reported")
+	}
+}
@@ -0,0 +1,106 @@
+package module
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/acoderup/goserver.v1/core/profile"
+)
+
+// clockJumps 框架时钟跳变的次数，跳变后固定步长模块重新对齐时间，不把跳变的时间当作落后去补帧
+var clockJumps int64
+
+// fixedStep 固定步长模块的累计时间和帧计数
+type fixedStep struct {
+	last        time.Time
+	jumps       int64
+	accumulator time.Duration
+	frame       uint64
+	dropped     uint64
+	lastWarn    time.Time
+}
+
+func (this *fixedStep) reset() {
+	this.last = time.Time{}
+	this.accumulator = 0
+	atomic.StoreUint64(&this.frame, 0)
+	atomic.StoreUint64(&this.dropped, 0)
+}
+
+func (this *fixedStep) counters() (uint64, uint64) {
+	return atomic.LoadUint64(&this.frame), atomic.LoadUint64(&this.dropped)
+}
+
+// advance 累加流逝的时间，返回本次需要执行的步数，超过maxSteps的部分被丢弃
+func (this *fixedStep) advance(nowTime time.Time, dt time.Duration, maxSteps int) (steps int, dropped int) {
+	if this.last.IsZero() {
+		//第一次更新直接执行一帧
+		this.last = nowTime
+		this.jumps = atomic.LoadInt64(&clockJumps)
+		return 1, 0
+	}
+	if jumps := atomic.LoadInt64(&clockJumps); jumps != this.jumps {
+		//时钟跳变，这一帧不累计时间
+		this.jumps = jumps
+		this.last = nowTime
+		return 0, 0
+	}
+	elapsed := nowTime.Sub(this.last)
+	this.last = nowTime
+	if elapsed > 0 {
+		this.accumulator += elapsed
+	}
+	n := int64(this.accumulator / dt)
+	if n > int64(maxSteps) {
+		dropped = int(n - int64(maxSteps))
+		n = int64(maxSteps)
+		this.accumulator -= time.Duration(dropped) * dt
+		atomic.AddUint64(&this.dropped, uint64(dropped))
+	}
+	return int(n), dropped
+}
+
+func (this *ModuleEntity) fixedUpt(fu FixedUpdater, nowTime time.Time) {
+	dt := this.tickInterval
+	if dt <= 0 {
+		dt = Config.FixedStep
+	}
+	if dt <= 0 {
+		return
+	}
+	maxSteps := Config.MaxCatchUpSteps
+	if maxSteps <= 0 {
+		maxSteps = 1
+	}
+	steps, dropped := this.fixed.advance(nowTime, dt, maxSteps)
+	if dropped > 0 && time.Since(this.fixed.lastWarn) >= time.Second {
+		this.fixed.lastWarn = time.Now()
+		logger.Logger.Warnf("module [%16s] fixed update fall behind, dropped %v steps, step=%v", this.module.ModuleName(), dropped, dt)
+	}
+	if steps == 0 {
+		return
+	}
+	this.lastTick = nowTime
+	watch := profile.TimeStatisticMgr.WatchStart(fmt.Sprintf("/module/%v/update", this.module.ModuleName()), profile.TIME_ELEMENT_MODULE)
+	if watch != nil {
+		defer watch.Stop()
+	}
+	tStart := time.Now()
+	defer func() { this.budget.record(this.module, time.Since(tStart)) }()
+	for i := 0; i < steps; i++ {
+		this.fixed.accumulator -= dt
+		if this.fixed.accumulator < 0 {
+			this.fixed.accumulator = 0
+		}
+		fu.FixedUpdate(dt, atomic.AddUint64(&this.fixed.frame, 1))
+	}
+}
+
+func init() {
+	core.AppClock.RegisteChangedHook(func(old, now time.Time) {
+		atomic.AddInt64(&clockJumps, 1)
+	})
+}
@@ -0,0 +1,318 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/logger"
+)
+
+// 运行时增加、停止、重启模块
+// ModuleMgr的方法只能在core object中调用，其它协程请使用包级别的同名函数
+
+type ModuleStatus struct {
+	Name     string
+	Priority int
+	State    string
+	Depends  []string
+}
+
+var ErrModuleMgrShutdown = errors.New("ModuleMgr is shutting down")
+
+// run 运行阶段的心跳，处理运行时模块的停止确认、初始化，然后更新所有模块
+func (this *ModuleMgr) run() {
+	//期限使用真实时间，框架时钟只用于模块的Update
+	nowTime := time.Now()
+	for drained := false; !drained; {
+		select {
+		case param := <-this.waitShutAct:
+			if name, ok := param.(string); ok {
+				logger.Logger.Infof("module [%16s] shutdowned", name)
+				this.onModuleQuit(name)
+			}
+		default:
+			drained = true
+		}
+	}
+	this.checkStopDeadline(nowTime)
+	this.checkPendingReset()
+	if _, me, err := this.initModules(nowTime); err != nil {
+		logger.Logger.Error("ModuleMgr runtime init failed: ", err)
+		me.initState = ModuleInitFailed
+		me.initErr = err
+		me.quited = true
+		me.shutdowned = true
+	}
+	this.update()
+}
+
+// AddModule 运行时增加模块，模块先初始化，就绪后才开始Update
+func (this *ModuleMgr) AddModule(m Module, tickInterval time.Duration, priority int) error {
+	if this.waitShut || this.state == ModuleStateShutdown {
+		return ErrModuleMgrShutdown
+	}
+	name := m.ModuleName()
+	if this.getModuleEntityByName(name) != nil {
+		return fmt.Errorf("module [%v] already registed", name)
+	}
+	if this.state == ModuleStateInvalid {
+		this.RegisteModule(m, tickInterval, priority)
+		return nil
+	}
+	me := newModuleEntity(m, tickInterval, priority)
+	for _, dep := range me.deps {
+		if this.getModuleEntityByName(dep) == nil {
+			return fmt.Errorf("module [%v] depend on module [%v] which is not registed", name, dep)
+		}
+	}
+	logger.Logger.Infof("module [%16s] add at runtime;interval=%v,priority=%v", name, tickInterval, priority)
+	//依赖的模块都已经在链表中，追加到末尾即可保证拓扑顺序
+	this.modulesByName[name] = me
+	this.modules.PushBack(me)
+	return nil
+}
+
+// StopModule 运行时停止模块，依赖它的模块必须先停止
+func (this *ModuleMgr) StopModule(name string) error {
+	me, err := this.checkRuntimeOp(name)
+	if err != nil {
+		return err
+	}
+	if me.quited {
+		return fmt.Errorf("module [%v] already stopped", name)
+	}
+	if me.shutdowned {
+		return fmt.Errorf("module [%v] is stopping", name)
+	}
+	var running []string
+	for _, dependent := range this.getDependents(name) {
+		if !dependent.quited {
+			running = append(running, dependent.module.ModuleName())
+		}
+	}
+	if len(running) > 0 {
+		return fmt.Errorf("module [%v] is depended by running modules %v", name, running)
+	}
+	if me.initState == ModuleInitPending {
+		me.quited = true
+		me.shutdowned = true
+		this.onModuleStopped(me)
+		return nil
+	}
+	logger.Logger.Infof("module [%16s] stop...", name)
+	me.shutdowned = true
+	this.waitShutCnt++
+	timeout := Config.ShutdownTimeout
+	if st, ok := me.module.(ShutdownTimeouter); ok && st.ShutdownTimeout() > 0 {
+		timeout = st.ShutdownTimeout()
+	}
+	if timeout > 0 {
+		me.shutDeadline = time.Now().Add(timeout)
+	}
+	me.safeShutdown(this.waitShutAct)
+	return nil
+}
+
+// StartModule 重新启动已经停止或者初始化失败的模块
+func (this *ModuleMgr) StartModule(name string) error {
+	me, err := this.checkRuntimeOp(name)
+	if err != nil {
+		return err
+	}
+	if !me.quited {
+		return fmt.Errorf("module [%v] is not stopped", name)
+	}
+	this.resetModule(me)
+	return nil
+}
+
+// RestartModule 停止模块，确认退出后重新初始化
+func (this *ModuleMgr) RestartModule(name string) error {
+	me, err := this.checkRuntimeOp(name)
+	if err != nil {
+		return err
+	}
+	if me.quited {
+		return this.StartModule(name)
+	}
+	if err = this.StopModule(name); err != nil {
+		return err
+	}
+	if !me.quited {
+		me.restart = true
+	}
+	return nil
+}
+
+// RemoveModule 停止模块并从ModuleMgr中移除
+func (this *ModuleMgr) RemoveModule(name string) error {
+	me, err := this.checkRuntimeOp(name)
+	if err != nil {
+		return err
+	}
+	if !me.quited {
+		if err = this.StopModule(name); err != nil {
+			return err
+		}
+	}
+	if me.quited {
+		this.removeModule(me)
+	} else {
+		me.remove = true
+	}
+	return nil
+}
+
+// GetModulesStatus 获取所有模块的状态
+func (this *ModuleMgr) GetModulesStatus() []ModuleStatus {
+	var status []ModuleStatus
+	for e := this.modules.Front(); e != nil; e = e.Next() {
+		if me, ok := e.Value.(*ModuleEntity); ok {
+			status = append(status, ModuleStatus{
+				Name:     me.module.ModuleName(),
+				Priority: me.priority,
+				State:    me.stateString(),
+				Depends:  me.deps,
+			})
+		}
+	}
+	return status
+}
+
+func (this *ModuleMgr) checkRuntimeOp(name string) (*ModuleEntity, error) {
+	if this.waitShut || this.state == ModuleStateShutdown {
+		return nil, ErrModuleMgrShutdown
+	}
+	me := this.getModuleEntityByName(name)
+	if me == nil {
+		return nil, fmt.Errorf("module [%v] not found", name)
+	}
+	return me, nil
+}
+
+// checkStopDeadline 运行时停止的模块超时未退出，强制退出，nowTime为真实时间
+func (this *ModuleMgr) checkStopDeadline(nowTime time.Time) {
+	for e := this.modules.Front(); e != nil; e = e.Next() {
+		if me, ok := e.Value.(*ModuleEntity); ok && me.shutdowned && !me.quited {
+			if !me.shutDeadline.IsZero() && nowTime.After(me.shutDeadline) {
+				logger.Logger.Errorf("module [%v] stop timeout, force quit, goroutine dump:\n%s", me.module.ModuleName(), dumpGoroutines())
+				this.waitShutCnt--
+				me.quited = true
+				me.forceQuited = true
+				this.unisolate(me)
+				this.onModuleStopped(me)
+			}
+		}
+	}
+}
+
+func (this *ModuleMgr) onModuleStopped(me *ModuleEntity) {
+	logger.Logger.Infof("module [%16s] stopped", me.module.ModuleName())
+	switch {
+	case me.remove:
+		this.removeModule(me)
+	case me.restart:
+		this.resetModule(me)
+	}
+}
+
+func (this *ModuleMgr) resetModule(me *ModuleEntity) {
+	//模块曾经在独立协程中Update，等待正在执行的Update结束后再重置，期间模块保持停止状态
+	if !me.budget.updLock.TryLock() {
+		if !me.resetPending {
+			logger.Logger.Warnf("module [%16s] restart delayed, isolated update still running", me.module.ModuleName())
+		}
+		me.resetPending = true
+		return
+	}
+	defer me.budget.updLock.Unlock()
+	logger.Logger.Infof("module [%16s] restart", me.module.ModuleName())
+	me.resetPending = false
+	me.quited = false
+	me.shutdowned = false
+	me.forceQuited = false
+	me.restart = false
+	me.initState = ModuleInitPending
+	me.initErr = nil
+	me.initDeadline = time.Time{}
+	me.shutDeadline = time.Time{}
+	me.lastTick = core.Now()
+	me.fixed.reset()
+}
+
+// checkPendingReset 重试因独立协程Update未结束而延迟的重启
+func (this *ModuleMgr) checkPendingReset() {
+	for e := this.modules.Front(); e != nil; e = e.Next() {
+		if me, ok := e.Value.(*ModuleEntity); ok && me.resetPending && me.quited {
+			this.resetModule(me)
+		}
+	}
+}
+
+func (this *ModuleMgr) removeModule(me *ModuleEntity) {
+	name := me.module.ModuleName()
+	logger.Logger.Infof("module [%16s] removed", name)
+	for e := this.modules.Front(); e != nil; e = e.Next() {
+		if e.Value == me {
+			this.modules.Remove(e)
+			break
+		}
+	}
+	delete(this.modulesByName, name)
+}
+
+func (this *ModuleEntity) stateString() string {
+	switch {
+	case this.quited && this.initState == ModuleInitFailed:
+		return "failed"
+	case this.quited:
+		return "stopped"
+	case this.shutdowned:
+		return "stopping"
+	case this.initState == ModuleInitPending:
+		return "pending"
+	case this.initState == ModuleInitIniting:
+		return "initing"
+	}
+	return "running"
+}
+
+// postToCore 在core object中执行模块操作
+func postToCore(op string, f func() error) {
+	if AppModule.Object == nil {
+		if err := f(); err != nil {
+			logger.Logger.Errorf("ModuleMgr %v failed: %v", op, err)
+		}
+		return
+	}
+	AppModule.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		if err := f(); err != nil {
+			logger.Logger.Errorf("ModuleMgr %v failed: %v", op, err)
+		}
+		return nil
+	}), true)
+}
+
+func AddModule(m Module, tickInterval time.Duration, priority int) {
+	postToCore("AddModule", func() error { return AppModule.AddModule(m, tickInterval, priority) })
+}
+
+func StopModule(name string) {
+	postToCore("StopModule", func() error { return AppModule.StopModule(name) })
+}
+
+func StartModule(name string) {
+	postToCore("StartModule", func() error { return AppModule.StartModule(name) })
+}
+
+func RestartModule(name string) {
+	postToCore("RestartModule", func() error { return AppModule.RestartModule(name) })
+}
+
+func RemoveModule(name string) {
+	postToCore("RemoveModule", func() error { return AppModule.RemoveModule(name) })
+}
@@ -0,0 +1,93 @@
+package module
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/logger"
+)
+
+const (
+	///process exit code
+	ExitCode_Normal int = iota
+	ExitCode_InitFailed
+	ExitCode_ShutdownTimeout
+	ExitCode_Forced
+)
+
+// checkShutdownDeadline 检查关闭超时，超时的模块被强制退出
+// 期限使用真实时间，不受框架时钟偏移和缩放影响
+func (this *ModuleMgr) checkShutdownDeadline() {
+	nowTime := time.Now()
+	globalExpired := !this.shutDeadline.IsZero() && nowTime.After(this.shutDeadline)
+	var expired []*ModuleEntity
+	for e := this.modules.Front(); e != nil; e = e.Next() {
+		if me, ok := e.Value.(*ModuleEntity); ok && !me.quited {
+			if globalExpired || (me.shutdowned && !me.shutDeadline.IsZero() && nowTime.After(me.shutDeadline)) {
+				expired = append(expired, me)
+			}
+		}
+	}
+	if len(expired) == 0 {
+		return
+	}
+	if globalExpired {
+		logger.Logger.Errorf("ModuleMgr shutdown timeout(%v), force quit %v modules", Config.ShutdownTimeout, len(expired))
+	}
+	this.forceQuit(expired)
+}
+
+// forceQuit 强制退出模块，记录模块名称和当前所有协程的堆栈
+func (this *ModuleMgr) forceQuit(mes []*ModuleEntity) {
+	var names []string
+	for _, me := range mes {
+		names = append(names, me.module.ModuleName())
+	}
+	logger.Logger.Errorf("ModuleMgr force quit modules %v, goroutine dump:\n%s", names, dumpGoroutines())
+	for _, me := range mes {
+		if !me.shutdowned {
+			//还在等待依赖它的模块退出，至少通知一次关闭
+			me.shutdowned = true
+			me.safeShutdown(this.waitShutAct)
+		} else {
+			this.waitShutCnt--
+		}
+		me.quited = true
+		me.forceQuited = true
+		this.unisolate(me)
+	}
+	this.exitCode = ExitCode_ShutdownTimeout
+	this.shutdownModules()
+}
+
+// ExitCode 进程退出码，模块初始化失败或者关闭时有模块被强制退出时不为0
+func (this *ModuleMgr) ExitCode() int {
+	if this.exitCode == ExitCode_Normal && this.initErr != nil {
+		return ExitCode_InitFailed
+	}
+	return this.exitCode
+}
+
+// GetForceQuitedModules 获取关闭时被强制退出的模块
+func (this *ModuleMgr) GetForceQuitedModules() []string {
+	var names []string
+	for e := this.modules.Front(); e != nil; e = e.Next() {
+		if me, ok := e.Value.(*ModuleEntity); ok && me.forceQuited {
+			names = append(names, me.module.ModuleName())
+		}
+	}
+	return names
+}
+
+func dumpGoroutines() string {
+	var buf bytes.Buffer
+	if p := pprof.Lookup("goroutine"); p != nil {
+		p.WriteTo(&buf, 2)
+	}
+	return buf.String()
+}
+
+func ExitCode() int {
+	return AppModule.ExitCode()
+}
@@ -2,6 +2,7 @@ package module
 
 import (
 	"container/list"
+	"os"
 	"time"
 
 	"fmt"
@@ -24,6 +25,14 @@ const (
 	ModuleMaxCount = 1024
 )
 
+const (
+	///module init state
+	ModuleInitPending int = iota
+	ModuleInitIniting
+	ModuleInitReady
+	ModuleInitFailed
+)
+
 var (
 	AppModule = newModuleMgr()
 )
@@ -33,7 +42,19 @@ type ModuleEntity struct {
 	tickInterval time.Duration
 	priority     int
 	module       Module
+	deps         []string
 	quited       bool
+	shutdowned   bool
+	initState    int
+	initDeadline time.Time
+	initErr      error
+	shutDeadline time.Time
+	forceQuited  bool
+	restart      bool
+	remove       bool
+	resetPending bool
+	budget       moduleBudget
+	fixed        fixedStep
 }
 
 type PreloadModuleEntity struct {
@@ -53,6 +74,11 @@ type ModuleMgr struct {
 	currTimeSec   int64
 	currTimeNano  int64
 	currTime      time.Time
+	initStarted   bool
+	initErr       error
+	lastWaitLog   time.Time
+	shutDeadline  time.Time
+	exitCode      int
 }
 
 func newModuleMgr() *ModuleMgr {
@@ -79,14 +105,23 @@ func (this *ModuleMgr) GetCurrTimeNano() int64 {
 	return this.currTimeNano
 }
 
-func (this *ModuleMgr) RegisteModule(m Module, tickInterval time.Duration, priority int) {
-	logger.Logger.Infof("module [%16s] registe;interval=%v,priority=%v", m.ModuleName(), tickInterval, priority)
+func newModuleEntity(m Module, tickInterval time.Duration, priority int) *ModuleEntity {
 	mentiry := &ModuleEntity{
-		lastTick:     time.Now(),
+		lastTick:     core.Now(),
 		tickInterval: tickInterval,
 		priority:     priority,
 		module:       m,
 	}
+	if md, ok := m.(ModuleDepender); ok {
+		mentiry.deps = md.DependModules()
+		logger.Logger.Infof("module [%16s] depend on %v", m.ModuleName(), mentiry.deps)
+	}
+	return mentiry
+}
+
+func (this *ModuleMgr) RegisteModule(m Module, tickInterval time.Duration, priority int) {
+	logger.Logger.Infof("module [%16s] registe;interval=%v,priority=%v", m.ModuleName(), tickInterval, priority)
+	mentiry := newModuleEntity(m, tickInterval, priority)
 
 	this.modulesByName[m.ModuleName()] = mentiry
 
@@ -150,6 +185,12 @@ func (this *ModuleMgr) Start() *utils.Waitor {
 	}
 	logger.Logger.Info("Startup PreloadModules [ok]")
 
+	if err := this.sortModules(); err != nil {
+		logger.Logger.Critical("ModuleMgr sort modules failed: ", err)
+		logger.Logger.Flush()
+		panic(err)
+	}
+
 	this.Object = basic.NewObject(core.ObjId_CoreId,
 		"core",
 		Config.Options,
@@ -170,26 +211,136 @@ func (this *ModuleMgr) Close() {
 }
 
 func (this *ModuleMgr) init() {
-	logger.Logger.Info("Start Initialize Modules")
-	defer logger.Logger.Info("Start Initialize Modules [ok]")
+	if !this.initStarted {
+		this.initStarted = true
+		logger.Logger.Info("Start Initialize Modules")
+	}
+	//初始化期限使用真实时间，不受框架时钟偏移和缩放影响
+	allReady, _, err := this.initModules(time.Now())
+	if err != nil {
+		this.abortInit(err)
+		return
+	}
+	if allReady {
+		logger.Logger.Info("Start Initialize Modules [ok]")
+		this.state = ModuleStateRun
+		return
+	}
+	//已经就绪的模块可以先开始Update
+	this.update()
+}
+
+// initModules 推进模块的初始化，返回是否全部就绪，以及初始化失败或超时的模块
+func (this *ModuleMgr) initModules(nowTime time.Time) (bool, *ModuleEntity, error) {
+	allReady := true
+	for e := this.modules.Front(); e != nil; e = e.Next() {
+		me, ok := e.Value.(*ModuleEntity)
+		if !ok || me.quited {
+			continue
+		}
+		if me.initState == ModuleInitPending {
+			if !this.isDependsReady(me) {
+				allReady = false
+				continue
+			}
+			this.startInit(me, nowTime)
+		}
+		switch me.initState {
+		case ModuleInitIniting:
+			allReady = false
+			if !me.initDeadline.IsZero() && nowTime.After(me.initDeadline) {
+				return false, me, fmt.Errorf("module [%v] init timeout, not ready after %v", me.module.ModuleName(), this.getInitTimeout(me))
+			}
+			if nowTime.Sub(this.lastWaitLog) >= time.Second {
+				this.lastWaitLog = nowTime
+				logger.Logger.Infof("module [%16s] wait init ready...", me.module.ModuleName())
+			}
+		case ModuleInitFailed:
+			return false, me, fmt.Errorf("module [%v] init failed: %v", me.module.ModuleName(), me.initErr)
+		}
+	}
+	return allReady, nil, nil
+}
+
+func (this *ModuleMgr) isDependsReady(me *ModuleEntity) bool {
+	for _, dep := range me.deps {
+		if dme := this.getModuleEntityByName(dep); dme == nil || dme.initState != ModuleInitReady {
+			return false
+		}
+	}
+	return true
+}
+
+func (this *ModuleMgr) startInit(me *ModuleEntity, nowTime time.Time) {
+	logger.Logger.Infof("module [%16s] init...", me.module.ModuleName())
+	if _, ok := me.module.(AsyncInitModule); ok {
+		me.initState = ModuleInitIniting
+		if timeout := this.getInitTimeout(me); timeout > 0 {
+			me.initDeadline = nowTime.Add(timeout)
+		}
+		me.safeInit()
+		return
+	}
+	me.safeInit()
+	me.initState = ModuleInitReady
+	logger.Logger.Infof("module [%16s] init[ok]", me.module.ModuleName())
+}
+
+func (this *ModuleMgr) getInitTimeout(me *ModuleEntity) time.Duration {
+	if am, ok := me.module.(AsyncInitModule); ok {
+		if timeout := am.InitTimeout(); timeout > 0 {
+			return timeout
+		}
+	}
+	return Config.InitTimeout
+}
+
+func (this *ModuleMgr) onInitDone(name string, err error) {
+	me := this.getModuleEntityByName(name)
+	if me == nil || me.initState != ModuleInitIniting {
+		logger.Logger.Warnf("module [%16s] InitDone ignored, module not in initing", name)
+		return
+	}
+	if err != nil {
+		me.initState = ModuleInitFailed
+		me.initErr = err
+		logger.Logger.Errorf("module [%16s] init failed: %v", name, err)
+		return
+	}
+	me.initState = ModuleInitReady
+	logger.Logger.Infof("module [%16s] init[ok]", name)
+}
+
+// abortInit 初始化失败，关闭已经初始化过的模块后退出
+func (this *ModuleMgr) abortInit(err error) {
+	this.initErr = err
+	logger.Logger.Critical("ModuleMgr abort startup: ", err)
 	for e := this.modules.Front(); e != nil; e = e.Next() {
-		if me, ok := e.Value.(*ModuleEntity); ok && !me.quited {
-			logger.Logger.Infof("module [%16s] init...", me.module.ModuleName())
-			me.safeInit()
-			logger.Logger.Infof("module [%16s] init[ok]", me.module.ModuleName())
+		if me, ok := e.Value.(*ModuleEntity); ok {
+			//未初始化或者已经报告失败的模块不需要再关闭
+			if me.initState == ModuleInitPending || me.initState == ModuleInitFailed {
+				me.quited = true
+				me.shutdowned = true
+			}
 		}
 	}
-	this.state = ModuleStateRun
+	this.state = ModuleStateShutdown
+}
+
+// InitError 模块初始化失败或超时的原因，启动正常时返回nil
+func (this *ModuleMgr) InitError() error {
+	return this.initErr
 }
 
 func (this *ModuleMgr) update() {
-	nowTime := time.Now()
+	nowTime := core.Now()
 	this.currTime = nowTime
 	this.currTimeSec = nowTime.Unix()
 	this.currTimeNano = nowTime.UnixNano()
 	for e := this.modules.Front(); e != nil; e = e.Next() {
-		if me, ok := e.Value.(*ModuleEntity); ok && !me.quited {
+		if me, ok := e.Value.(*ModuleEntity); ok && !me.quited && me.initState == ModuleInitReady && !me.isIsolated() {
 			me.safeUpt(nowTime)
+			this.checkIsolate(me)
 		}
 	}
 }
@@ -201,39 +352,69 @@ func (this *ModuleMgr) shutdown() {
 	logger.Logger.Info("ModuleMgr shutdown()")
 	this.waitShut = true
 	this.state = ModuleStateWaitShutdown
-	for e := this.modules.Front(); e != nil; e = e.Next() {
-		if me, ok := e.Value.(*ModuleEntity); ok {
+	if Config.ShutdownTimeout > 0 {
+		this.shutDeadline = time.Now().Add(Config.ShutdownTimeout)
+	}
+	this.shutdownModules()
+}
+
+// shutdownModules 按依赖关系的逆序关闭模块，模块只有在依赖它的模块全部退出后才会被关闭
+func (this *ModuleMgr) shutdownModules() {
+	for e := this.modules.Back(); e != nil; e = e.Prev() {
+		if me, ok := e.Value.(*ModuleEntity); ok && !me.shutdowned {
+			if !this.isDependentsQuited(me.module.ModuleName()) {
+				continue
+			}
 			logger.Logger.Infof("module [%16s] shutdown...", me.module.ModuleName())
+			me.shutdowned = true
+			this.waitShutCnt++
+			if st, ok := me.module.(ShutdownTimeouter); ok && st.ShutdownTimeout() > 0 {
+				me.shutDeadline = time.Now().Add(st.ShutdownTimeout())
+			}
 			me.safeShutdown(this.waitShutAct)
 			logger.Logger.Infof("module [%16s] shutdown[ok]", me.module.ModuleName())
-			this.waitShutCnt++
 		}
 	}
 }
 
+func (this *ModuleMgr) isDependentsQuited(name string) bool {
+	for _, dependent := range this.getDependents(name) {
+		if !dependent.quited {
+			return false
+		}
+	}
+	return true
+}
+
+func (this *ModuleMgr) isAllShutdowned() bool {
+	for e := this.modules.Front(); e != nil; e = e.Next() {
+		if me, ok := e.Value.(*ModuleEntity); ok && !me.shutdowned {
+			return false
+		}
+	}
+	return true
+}
+
 func (this *ModuleMgr) checkShutdown() bool {
 	select {
 	case param := <-this.waitShutAct:
 		logger.Logger.Infof("module [%16s] shutdowned", param)
 		if name, ok := param.(string); ok {
-			me := this.getModuleEntityByName(name)
-			if me != nil && !me.quited {
-				me.quited = true
-				this.waitShutCnt--
-			}
+			this.onModuleQuit(name)
 		}
 	case _ = <-time.After(time.Second):
 		logger.Logger.Trace("ModuleMgr.checkShutdown wait...")
 		for e := this.modules.Front(); e != nil; e = e.Next() {
 			if me, ok := e.Value.(*ModuleEntity); ok {
-				if me.quited == false {
+				if me.shutdowned && me.quited == false {
 					logger.Logger.Infof("Module [%v] wait shutdown...", me.module.ModuleName())
 				}
 			}
 		}
 		//default:
 	}
-	if this.waitShutCnt == 0 {
+	this.checkShutdownDeadline()
+	if this.waitShutCnt == 0 && this.isAllShutdowned() {
 		this.state = ModuleStateFini
 		return true
 	}
@@ -241,13 +422,31 @@ func (this *ModuleMgr) checkShutdown() bool {
 	return false
 }
 
+func (this *ModuleMgr) onModuleQuit(name string) {
+	me := this.getModuleEntityByName(name)
+	if me != nil && !me.quited {
+		me.quited = true
+		this.unisolate(me)
+		if me.shutdowned {
+			this.waitShutCnt--
+		} else {
+			me.shutdowned = true
+		}
+		if this.waitShut {
+			this.shutdownModules()
+		} else {
+			this.onModuleStopped(me)
+		}
+	}
+}
+
 func (this *ModuleMgr) tick() {
 
 	switch this.state {
 	case ModuleStateInit:
 		this.init()
 	case ModuleStateRun:
-		this.update()
+		this.run()
 	case ModuleStateShutdown:
 		this.shutdown()
 	case ModuleStateWaitShutdown:
@@ -261,6 +460,15 @@ func (this *ModuleMgr) fini() {
 	core.Terminate(this.Object)
 	this.state = ModuleStateInvalid
 	logger.Logger.Info("=============ModuleMgr fini=============")
+	if this.initErr != nil && this.exitCode == ExitCode_Normal {
+		this.exitCode = ExitCode_InitFailed
+	}
+	if this.exitCode != ExitCode_Normal {
+		logger.Logger.Errorf("ModuleMgr unclean shutdown, exit code=%v", this.exitCode)
+		logger.Logger.Flush()
+		//被强制退出的模块可能仍然阻塞着Waitor，直接退出进程
+		os.Exit(this.exitCode)
+	}
 	logger.Logger.Flush()
 }
 
@@ -286,17 +494,36 @@ func (this *ModuleEntity) safeInit() {
 func (this *ModuleEntity) safeUpt(nowTime time.Time) {
 	defer utils.DumpStackIfPanic("ModuleEntity.safeTick")
 
+	if fu, ok := this.module.(FixedUpdater); ok {
+		this.fixedUpt(fu, nowTime)
+		return
+	}
 	if this.tickInterval == 0 || nowTime.Sub(this.lastTick) >= this.tickInterval {
 		this.lastTick = nowTime
 		watch := profile.TimeStatisticMgr.WatchStart(fmt.Sprintf("/module/%v/update", this.module.ModuleName()), profile.TIME_ELEMENT_MODULE)
 		if watch != nil {
 			defer watch.Stop()
 		}
+		tStart := time.Now()
+		defer func() { this.budget.record(this.module, time.Since(tStart)) }()
 		this.module.Update()
 	}
 }
 
 func (this *ModuleEntity) safeShutdown(shutWaitAck chan<- interface{}) {
+	if this.isIsolated() {
+		//模块在独立协程中Update，Shutdown也投递到该协程中执行
+		this.budget.obj.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+			defer o.ProcessSeqnum()
+			this.doShutdown()
+			return nil
+		}), true)
+		return
+	}
+	this.doShutdown()
+}
+
+func (this *ModuleEntity) doShutdown() {
 	defer utils.DumpStackIfPanic("ModuleEntity.safeShutdown")
 	this.module.Shutdown()
 }
@@ -319,6 +546,20 @@ func UnregisteModule(m Module) {
 	AppModule.waitShutAct <- m.ModuleName()
 }
 
+// InitDone 异步初始化模块通知初始化结果，err为nil表示已就绪，可以在任意协程中调用
+func InitDone(m Module, err error) {
+	name := m.ModuleName()
+	if AppModule.Object == nil {
+		logger.Logger.Warnf("module [%16s] InitDone called before ModuleMgr start", name)
+		return
+	}
+	AppModule.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		AppModule.onInitDone(name, err)
+		return nil
+	}), true)
+}
+
 func Start() *utils.Waitor {
 	err := core.ExecuteHook(core.HOOK_BEFORE_START)
 	if err != nil {
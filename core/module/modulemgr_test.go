@@ -0,0 +1,310 @@
+package module
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+)
+
+var errTest = errors.New("test error")
+
+type asyncModule struct {
+	dependModule
+	timeout time.Duration
+	inited  int
+	updated int
+}
+
+func (m *asyncModule) Init()                      { m.inited++ }
+func (m *asyncModule) Update()                    { m.updated++ }
+func (m *asyncModule) InitTimeout() time.Duration { return m.timeout }
+
+func TestAsyncInit(t *testing.T) {
+	mm := newModuleMgr()
+	store := &asyncModule{dependModule: dependModule{name: "store"}, timeout: time.Minute}
+	game := &asyncModule{dependModule: dependModule{name: "game", deps: []string{"store"}}, timeout: time.Minute}
+	mm.RegisteModule(game, 0, 0)
+	mm.RegisteModule(store, 0, 1)
+	if err := mm.sortModules(); err != nil {
+		t.Fatal(err)
+	}
+	mm.state = ModuleStateInit
+	mm.tick()
+	if store.inited != 1 || game.inited != 0 {
+		t.Fatalf("dependent inited before dependency ready, store=%v game=%v", store.inited, game.inited)
+	}
+	mm.onInitDone("store", nil)
+	mm.tick()
+	if game.inited != 1 || store.updated == 0 || game.updated != 0 {
+		t.Fatalf("unexpected state, game.inited=%v store.updated=%v game.updated=%v", game.inited, store.updated, game.updated)
+	}
+	mm.onInitDone("game", nil)
+	mm.tick()
+	if mm.state != ModuleStateRun {
+		t.Fatalf("modules ready but state=%v", mm.state)
+	}
+}
+
+func TestAsyncInitTimeout(t *testing.T) {
+	mm := newModuleMgr()
+	store := &asyncModule{dependModule: dependModule{name: "store"}, timeout: time.Millisecond}
+	mm.RegisteModule(store, 0, 0)
+	mm.state = ModuleStateInit
+	mm.tick()
+	time.Sleep(5 * time.Millisecond)
+	mm.tick()
+	if mm.state != ModuleStateShutdown || mm.InitError() == nil {
+		t.Fatalf("init timeout not detected, state=%v err=%v", mm.state, mm.InitError())
+	}
+}
+
+func TestAsyncInitClockJump(t *testing.T) {
+	mm := newModuleMgr()
+	store := &asyncModule{dependModule: dependModule{name: "store"}, timeout: time.Minute}
+	mm.RegisteModule(store, 0, 0)
+	mm.state = ModuleStateInit
+	mm.tick()
+	core.AppClock.AddOffset(time.Hour)
+	defer core.AppClock.Reset()
+	mm.tick()
+	if mm.state != ModuleStateInit || mm.InitError() != nil {
+		t.Fatalf("clock jump aborted init, state=%v err=%v", mm.state, mm.InitError())
+	}
+}
+
+func TestAsyncInitFailed(t *testing.T) {
+	mm := newModuleMgr()
+	store := &asyncModule{dependModule: dependModule{name: "store"}, timeout: time.Minute}
+	game := &dependModule{name: "game", deps: []string{"store"}}
+	mm.RegisteModule(store, 0, 0)
+	mm.RegisteModule(game, 0, 1)
+	mm.state = ModuleStateInit
+	mm.tick()
+	mm.onInitDone("store", errTest)
+	mm.tick()
+	if mm.state != ModuleStateShutdown || mm.InitError() == nil {
+		t.Fatalf("init failure not detected, state=%v", mm.state)
+	}
+	if me := mm.getModuleEntityByName("game"); !me.shutdowned {
+		t.Fatal("module never inited should be skipped on shutdown")
+	}
+}
+
+type stuckModule struct {
+	dependModule
+	timeout time.Duration
+}
+
+func (m *stuckModule) ShutdownTimeout() time.Duration { return m.timeout }
+
+func TestShutdownDeadline(t *testing.T) {
+	mm := newModuleMgr()
+	stuck := &stuckModule{dependModule: dependModule{name: "stuck"}, timeout: time.Millisecond}
+	game := &dependModule{name: "game", deps: []string{"stuck"}}
+	mm.RegisteModule(stuck, 0, 0)
+	mm.RegisteModule(game, 0, 1)
+	if err := mm.sortModules(); err != nil {
+		t.Fatal(err)
+	}
+	mm.state = ModuleStateShutdown
+	mm.tick()
+	if me := mm.getModuleEntityByName("stuck"); me.shutdowned {
+		t.Fatal("dependency shutdown before its dependents quit")
+	}
+	mm.onModuleQuit("game")
+	time.Sleep(5 * time.Millisecond)
+	mm.checkShutdownDeadline()
+	if names := mm.GetForceQuitedModules(); len(names) != 1 || names[0] != "stuck" {
+		t.Fatalf("unexpected force quited modules %v", names)
+	}
+	if mm.waitShutCnt != 0 || !mm.isAllShutdowned() || mm.ExitCode() != ExitCode_ShutdownTimeout {
+		t.Fatalf("unexpected state waitShutCnt=%v exitCode=%v", mm.waitShutCnt, mm.ExitCode())
+	}
+}
+
+func TestShutdownDeadlineClockJump(t *testing.T) {
+	mm := newModuleMgr()
+	stuck := &stuckModule{dependModule: dependModule{name: "stuck"}, timeout: time.Minute}
+	mm.RegisteModule(stuck, 0, 0)
+	mm.state = ModuleStateShutdown
+	mm.tick()
+	core.AppClock.AddOffset(time.Hour)
+	defer core.AppClock.Reset()
+	mm.checkShutdownDeadline()
+	if names := mm.GetForceQuitedModules(); len(names) != 0 || mm.ExitCode() != ExitCode_Normal {
+		t.Fatalf("clock jump force quited modules %v", names)
+	}
+}
+
+func TestStopModuleClockJump(t *testing.T) {
+	mm := newModuleMgr()
+	stuck := &stuckModule{dependModule: dependModule{name: "stuck"}, timeout: time.Minute}
+	mm.RegisteModule(stuck, 0, 0)
+	mm.state = ModuleStateInit
+	mm.tick()
+	core.AppClock.AddOffset(-time.Hour)
+	defer core.AppClock.Reset()
+	if err := mm.StopModule("stuck"); err != nil {
+		t.Fatal(err)
+	}
+	mm.tick()
+	if names := mm.GetForceQuitedModules(); len(names) != 0 {
+		t.Fatalf("clock jump force quited modules %v", names)
+	}
+}
+
+type countModule struct {
+	dependModule
+	inited   int
+	shutdown int
+}
+
+func (m *countModule) Init()     { m.inited++ }
+func (m *countModule) Shutdown() { m.shutdown++ }
+
+func TestRuntimeRestart(t *testing.T) {
+	mm := newModuleMgr()
+	db := &countModule{dependModule: dependModule{name: "db"}}
+	game := &countModule{dependModule: dependModule{name: "game", deps: []string{"db"}}}
+	mm.RegisteModule(db, 0, 0)
+	mm.RegisteModule(game, 0, 1)
+	mm.state = ModuleStateInit
+	mm.tick()
+	if mm.state != ModuleStateRun {
+		t.Fatalf("init not finished, state=%v", mm.state)
+	}
+	if err := mm.RestartModule("db"); err == nil {
+		t.Fatal("restart module with running dependents should fail")
+	}
+	if err := mm.RestartModule("game"); err != nil {
+		t.Fatal(err)
+	}
+	if game.shutdown != 1 {
+		t.Fatal("module not shutdown on restart")
+	}
+	mm.waitShutAct <- "game"
+	mm.tick()
+	if game.inited != 2 || mm.getModuleEntityByName("game").stateString() != "running" {
+		t.Fatalf("module not restarted, inited=%v", game.inited)
+	}
+	if err := mm.AddModule(&countModule{dependModule: dependModule{name: "chat", deps: []string{"game"}}}, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	mm.tick()
+	if me := mm.getModuleEntityByName("chat"); me == nil || me.stateString() != "running" {
+		t.Fatal("module added at runtime not running")
+	}
+	if err := mm.RemoveModule("chat"); err != nil {
+		t.Fatal(err)
+	}
+	mm.waitShutAct <- "chat"
+	mm.tick()
+	if mm.getModuleEntityByName("chat") != nil {
+		t.Fatal("module not removed")
+	}
+}
+
+type slowModule struct {
+	dependModule
+	cost time.Duration
+}
+
+func (m *slowModule) Update()                     { time.Sleep(m.cost) }
+func (m *slowModule) UpdateBudget() time.Duration { return time.Millisecond }
+
+func TestUpdateBudget(t *testing.T) {
+	mm := newModuleMgr()
+	mm.RegisteModule(&slowModule{dependModule: dependModule{name: "slow"}, cost: 3 * time.Millisecond}, 0, 0)
+	mm.RegisteModule(&slowModule{dependModule: dependModule{name: "fast"}}, 0, 1)
+	mm.state = ModuleStateInit
+	mm.tick()
+	for i := 0; i < 3; i++ {
+		mm.tick()
+	}
+	offenders := mm.GetWorstOffenders(5)
+	if len(offenders) != 1 || offenders[0].Name != "slow" {
+		t.Fatalf("unexpected offenders %v", offenders)
+	}
+	if offenders[0].Overruns != 3 || offenders[0].ConsecutiveOverruns != 3 || offenders[0].Worst < 3*time.Millisecond {
+		t.Fatalf("unexpected stats %+v", offenders[0])
+	}
+}
+
+type fixedModule struct {
+	dependModule
+	frames []uint64
+}
+
+func (m *fixedModule) FixedUpdate(dt time.Duration, frame uint64) {
+	m.frames = append(m.frames, frame)
+}
+
+func TestFixedStepAdvance(t *testing.T) {
+	dt := 10 * time.Millisecond
+	fm := &fixedModule{dependModule: dependModule{name: "fixed"}}
+	me := newModuleEntity(fm, dt, 0)
+	maxSteps := Config.MaxCatchUpSteps
+	Config.MaxCatchUpSteps = 5
+	defer func() { Config.MaxCatchUpSteps = maxSteps }()
+
+	tNow := time.Now()
+	step := func(d time.Duration, want int) {
+		t.Helper()
+		tNow = tNow.Add(d)
+		before := len(fm.frames)
+		me.fixedUpt(fm, tNow)
+		if got := len(fm.frames) - before; got != want {
+			t.Fatalf("after %v steps=%v, want %v", d, got, want)
+		}
+	}
+	step(0, 1)
+	step(25*time.Millisecond, 2)
+	step(5*time.Millisecond, 1)
+	step(3*time.Millisecond, 0)
+	step(100*time.Millisecond, 5)
+	if frame, dropped := me.fixed.counters(); frame != 9 || dropped != 5 {
+		t.Fatalf("frame=%v dropped=%v, want 9 and 5", frame, dropped)
+	}
+	if fm.frames[len(fm.frames)-1] != 9 {
+		t.Fatalf("frames=%v", fm.frames)
+	}
+
+	//时钟跳变不补帧
+	core.AppClock.AddOffset(time.Hour)
+	defer core.AppClock.Reset()
+	step(time.Hour, 0)
+	step(10*time.Millisecond, 1)
+	if _, dropped := me.fixed.counters(); dropped != 5 {
+		t.Fatalf("clock jump dropped=%v", dropped)
+	}
+}
+
+func TestRestartWaitIsolatedUpdate(t *testing.T) {
+	mm := newModuleMgr()
+	game := &countModule{dependModule: dependModule{name: "game"}}
+	mm.RegisteModule(game, 0, 0)
+	mm.state = ModuleStateInit
+	mm.tick()
+	if err := mm.StopModule("game"); err != nil {
+		t.Fatal(err)
+	}
+	mm.waitShutAct <- "game"
+	mm.tick()
+	me := mm.getModuleEntityByName("game")
+	//模拟独立协程中还未结束的Update
+	me.budget.updLock.Lock()
+	if err := mm.StartModule("game"); err != nil {
+		t.Fatal(err)
+	}
+	mm.tick()
+	if !me.resetPending || me.stateString() != "stopped" || game.inited != 1 {
+		t.Fatalf("module reset during isolated update, state=%v inited=%v", me.stateString(), game.inited)
+	}
+	me.budget.updLock.Unlock()
+	mm.tick()
+	if me.resetPending || me.stateString() != "running" || game.inited != 2 {
+		t.Fatalf("module not restarted after isolated update, state=%v inited=%v", me.stateString(), game.inited)
+	}
+}
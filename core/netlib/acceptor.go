@@ -0,0 +1,178 @@
+package netlib
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/container"
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/acoderup/goserver.v1/core/utils"
+)
+
+// Acceptor 监听端口，每个接入的连接创建一个会话，会话是Acceptor Object的子Object
+// 支持tcp和WebSocket，两种协议产生的会话完全一样
+type Acceptor struct {
+	*basic.Object
+	sc       *SessionConfig
+	listener net.Listener
+	server   *http.Server
+	idGen    utils.IdGen
+	sessions *container.SynchronizedMap
+	connCnt  int32
+	stoped   int32
+}
+
+func newAcceptor(sc *SessionConfig) *Acceptor {
+	return &Acceptor{
+		sc:       sc,
+		sessions: container.NewSynchronizedMap(),
+	}
+}
+
+func (a *Acceptor) start(parent *basic.Object) error {
+	l, err := net.Listen("tcp", a.sc.Addr())
+	if err != nil {
+		return err
+	}
+	a.listener = l
+	a.Object = basic.NewObject(a.sc.Id, fmt.Sprintf("acceptor_%v", a.sc.Name), a.sc.Options, a)
+	a.UserData = a
+	parent.LaunchChild(a.Object)
+	logger.Logger.Infof("Acceptor [%v] listen %v on %v", a.sc.Name, a.sc.Protocol, l.Addr())
+	if a.sc.Protocol == Protocol_WS {
+		a.startWS()
+	} else {
+		go a.acceptRoutine()
+	}
+	return nil
+}
+
+func (a *Acceptor) startWS() {
+	upgrader := newUpgrader(a.sc)
+	mux := http.NewServeMux()
+	mux.HandleFunc(a.sc.Path, func(w http.ResponseWriter, r *http.Request) {
+		if a.isFull() {
+			logger.Logger.Warnf("Acceptor [%v] reach max connection(%v), refuse %v", a.sc.Name, a.sc.MaxConn, r.RemoteAddr)
+			http.Error(w, "too many connections", http.StatusServiceUnavailable)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			logger.Logger.Tracef("Acceptor [%v] upgrade %v error: %v", a.sc.Name, r.RemoteAddr, err)
+			return
+		}
+		a.onConnected(newWSConn(conn, a.sc))
+	})
+	a.server = &http.Server{Handler: mux}
+	go func() {
+		if err := a.server.Serve(a.listener); err != nil && err != http.ErrServerClosed && atomic.LoadInt32(&a.stoped) == 0 {
+			logger.Logger.Errorf("Acceptor [%v] serve error: %v", a.sc.Name, err)
+		}
+	}()
+}
+
+func (a *Acceptor) acceptRoutine() {
+	for {
+		conn, err := a.listener.Accept()
+		if err != nil {
+			if atomic.LoadInt32(&a.stoped) != 0 {
+				return
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				logger.Logger.Warnf("Acceptor [%v] accept temporary error: %v", a.sc.Name, err)
+				continue
+			}
+			logger.Logger.Errorf("Acceptor [%v] accept error: %v", a.sc.Name, err)
+			return
+		}
+		if a.isFull() {
+			logger.Logger.Warnf("Acceptor [%v] reach max connection(%v), refuse %v", a.sc.Name, a.sc.MaxConn, conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
+		a.onConnected(conn)
+	}
+}
+
+func (a *Acceptor) isFull() bool {
+	return a.sc.MaxConn > 0 && int(atomic.LoadInt32(&a.connCnt)) >= a.sc.MaxConn
+}
+
+func (a *Acceptor) onConnected(conn net.Conn) {
+	atomic.AddInt32(&a.connCnt, 1)
+	s := newSession(a.idGen.NextId(), conn, a.sc, a)
+	a.sessions.Set(s.Id, s)
+	s.start(a.Object)
+}
+
+func (a *Acceptor) onSessionClosed(s *Session) {
+	a.sessions.Delete(s.Id)
+	atomic.AddInt32(&a.connCnt, -1)
+}
+
+// Addr 实际监听的地址，端口配置为0时由系统分配
+func (a *Acceptor) Addr() net.Addr {
+	return a.listener.Addr()
+}
+
+func (a *Acceptor) GetSession(id int) *Session {
+	if s, ok := a.sessions.Get(id).(*Session); ok {
+		return s
+	}
+	return nil
+}
+
+func (a *Acceptor) SessionCount() int {
+	return int(atomic.LoadInt32(&a.connCnt))
+}
+
+// Broadcast 向所有会话发送数据包，数据包只编码一次
+func (a *Acceptor) Broadcast(pack interface{}) {
+	var data []byte
+	a.sessions.Foreach(func(k, v interface{}) {
+		if s, ok := v.(*Session); ok {
+			if data == nil {
+				var err error
+				if data, err = s.codec.Encode(s, pack); err != nil {
+					logger.Logger.Warnf("Acceptor [%v] broadcast encode error: %v", a.sc.Name, err)
+					return
+				}
+			}
+			s.SendRaw(data)
+		}
+	})
+}
+
+// Stop 停止监听并关闭所有会话
+func (a *Acceptor) Stop() {
+	if !atomic.CompareAndSwapInt32(&a.stoped, 0, 1) {
+		return
+	}
+	a.closeListener()
+	a.Object.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		o.Terminate(o)
+		return nil
+	}), true)
+}
+
+func (a *Acceptor) OnStart() {}
+func (a *Acceptor) OnTick()  {}
+func (a *Acceptor) OnStop() {
+	if atomic.CompareAndSwapInt32(&a.stoped, 0, 1) {
+		a.closeListener()
+	}
+	NetModule.unregisteService(a.sc.Id, a)
+}
+
+func (a *Acceptor) closeListener() {
+	if a.server != nil {
+		//升级后的连接已经被http.Server剥离，由会话自己关闭
+		a.server.Close()
+	} else {
+		a.listener.Close()
+	}
+}
@@ -0,0 +1,122 @@
+package netlib
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	DefaultCodecName = "raw"
+	TextCodecName    = "text"
+)
+
+var (
+	codecLock sync.RWMutex
+	codecs    = make(map[string]PacketCodec)
+
+	ErrPacketTooLarge = errors.New("packet too large")
+	ErrPacketType     = errors.New("packet type not supported by codec")
+)
+
+// PacketCodec 数据包编解码器
+// Encode 在调用Send的协程中执行，Decode 在会话的读协程中执行
+type PacketCodec interface {
+	Encode(s *Session, pack interface{}) ([]byte, error)
+	Decode(s *Session, r io.Reader) (interface{}, error)
+}
+
+func RegisteCodec(name string, c PacketCodec) {
+	codecLock.Lock()
+	defer codecLock.Unlock()
+	if _, exist := codecs[name]; exist {
+		panic(fmt.Sprintf("repeate registe codec:%v", name))
+	}
+	codecs[name] = c
+}
+
+func GetCodec(name string) PacketCodec {
+	codecLock.RLock()
+	defer codecLock.RUnlock()
+	if c, exist := codecs[name]; exist {
+		return c
+	}
+	return nil
+}
+
+// rawCodec 4字节大端长度 + 数据，数据包类型为[]byte
+type rawCodec struct {
+}
+
+func (c *rawCodec) Encode(s *Session, pack interface{}) ([]byte, error) {
+	data, ok := pack.([]byte)
+	if !ok {
+		return nil, ErrPacketType
+	}
+	if len(data) > s.sc.MaxPacket {
+		return nil, ErrPacketTooLarge
+	}
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	copy(buf[4:], data)
+	return buf, nil
+}
+
+func (c *rawCodec) Decode(s *Session, r io.Reader) (interface{}, error) {
+	var head [4]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(head[:])
+	if int(n) > s.sc.MaxPacket {
+		return nil, ErrPacketTooLarge
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// textCodec 每个WebSocket消息就是一个数据包，没有长度前缀，数据包类型为string
+// 适用于直接收发文本(例如json)的浏览器客户端，只能用于面向消息的连接
+type textCodec struct {
+}
+
+func (c *textCodec) Encode(s *Session, pack interface{}) ([]byte, error) {
+	var data []byte
+	switch p := pack.(type) {
+	case string:
+		data = []byte(p)
+	case []byte:
+		data = p
+	default:
+		return nil, ErrPacketType
+	}
+	if len(data) > s.sc.MaxPacket {
+		return nil, ErrPacketTooLarge
+	}
+	return data, nil
+}
+
+func (c *textCodec) Decode(s *Session, r io.Reader) (interface{}, error) {
+	mr, ok := r.(MessageReader)
+	if !ok {
+		return nil, ErrPacketType
+	}
+	data, err := mr.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > s.sc.MaxPacket {
+		return nil, ErrPacketTooLarge
+	}
+	return string(data), nil
+}
+
+func init() {
+	RegisteCodec(DefaultCodecName, &rawCodec{})
+	RegisteCodec(TextCodecName, &textCodec{})
+}
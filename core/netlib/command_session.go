@@ -0,0 +1,72 @@
+package netlib
+
+import (
+	"github.com/acoderup/goserver.v1/core/basic"
+)
+
+type sessionOpenedCommand struct {
+	s *Session
+}
+
+func (cmd *sessionOpenedCommand) Done(o *basic.Object) error {
+	defer o.ProcessSeqnum()
+	if cmd.s.handler != nil {
+		cmd.s.handler.OnSessionOpened(cmd.s)
+	}
+	return nil
+}
+
+func SendSessionOpened(s *Session) bool {
+	return s.SendCommand(&sessionOpenedCommand{s: s}, true)
+}
+
+type sessionPacketCommand struct {
+	s    *Session
+	pack interface{}
+}
+
+func (cmd *sessionPacketCommand) Done(o *basic.Object) error {
+	defer o.ProcessSeqnum()
+	if p, ok := cmd.pack.(*Packet); ok && dispatchPacket(cmd.s, p) {
+		return nil
+	}
+	if cmd.s.handler != nil {
+		cmd.s.handler.OnPacketReceived(cmd.s, cmd.pack)
+	}
+	return nil
+}
+
+func SendSessionPacket(s *Session, pack interface{}) bool {
+	return s.SendCommand(&sessionPacketCommand{s: s, pack: pack}, true)
+}
+
+type sessionPacketErrorCommand struct {
+	s   *Session
+	err *PacketError
+}
+
+func (cmd *sessionPacketErrorCommand) Done(o *basic.Object) error {
+	defer o.ProcessSeqnum()
+	if !cmd.s.IsClosed() {
+		onPacketError(cmd.s, cmd.err.PacketId, cmd.err)
+	}
+	return nil
+}
+
+func SendSessionPacketError(s *Session, err *PacketError) bool {
+	return s.SendCommand(&sessionPacketErrorCommand{s: s, err: err}, true)
+}
+
+type sessionCloseCommand struct {
+	s *Session
+}
+
+func (cmd *sessionCloseCommand) Done(o *basic.Object) error {
+	defer o.ProcessSeqnum()
+	o.Terminate(o)
+	return nil
+}
+
+func SendSessionClose(s *Session) bool {
+	return s.SendCommand(&sessionCloseCommand{s: s}, true)
+}
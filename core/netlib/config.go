@@ -0,0 +1,32 @@
+package netlib
+
+import (
+	"github.com/acoderup/goserver.v1/core"
+)
+
+var Config = Configuration{}
+
+type Configuration struct {
+	IoServices []SessionConfig
+}
+
+func (c *Configuration) Name() string {
+	return "netlib"
+}
+
+func (c *Configuration) Init() error {
+	for i := 0; i < len(c.IoServices); i++ {
+		if err := c.IoServices[i].Init(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Configuration) Close() error {
+	return nil
+}
+
+func init() {
+	core.RegistePackage(&Config)
+}
@@ -0,0 +1,99 @@
+package netlib
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/logger"
+)
+
+// Connector 主动连接，连接成功后创建会话，断开后按配置自动重连
+type Connector struct {
+	*basic.Object
+	sc      *SessionConfig
+	session atomic.Value
+	sid     int32
+	stoped  int32
+}
+
+func newConnector(sc *SessionConfig) *Connector {
+	return &Connector{
+		sc: sc,
+	}
+}
+
+func (c *Connector) start(parent *basic.Object) {
+	c.Object = basic.NewObject(c.sc.Id, fmt.Sprintf("connector_%v", c.sc.Name), c.sc.Options, c)
+	c.UserData = c
+	parent.LaunchChild(c.Object)
+	go c.connect()
+}
+
+func (c *Connector) connect() {
+	if atomic.LoadInt32(&c.stoped) != 0 {
+		return
+	}
+	conn, err := net.DialTimeout("tcp", c.sc.Addr(), c.sc.WriteTimeout)
+	if err != nil {
+		logger.Logger.Warnf("Connector [%v] connect %v error: %v", c.sc.Name, c.sc.Addr(), err)
+		c.reconnect()
+		return
+	}
+	if atomic.LoadInt32(&c.stoped) != 0 {
+		conn.Close()
+		return
+	}
+	logger.Logger.Infof("Connector [%v] connected to %v", c.sc.Name, conn.RemoteAddr())
+	s := newSession(int(atomic.AddInt32(&c.sid, 1)), conn, c.sc, c)
+	c.session.Store(s)
+	s.start(c.Object)
+}
+
+func (c *Connector) reconnect() {
+	if !c.sc.IsAutoReconn || atomic.LoadInt32(&c.stoped) != 0 {
+		return
+	}
+	time.AfterFunc(c.sc.ReconnInterval, c.connect)
+}
+
+func (c *Connector) onSessionClosed(s *Session) {
+	logger.Logger.Infof("Connector [%v] session closed: %v", c.sc.Name, s.CloseReason())
+	c.reconnect()
+}
+
+// GetSession 获取当前会话，未连接或者已断开时返回nil
+func (c *Connector) GetSession() *Session {
+	if s, ok := c.session.Load().(*Session); ok && !s.IsClosed() {
+		return s
+	}
+	return nil
+}
+
+func (c *Connector) Send(pack interface{}) bool {
+	if s := c.GetSession(); s != nil {
+		return s.Send(pack)
+	}
+	return false
+}
+
+// Stop 停止重连并关闭会话
+func (c *Connector) Stop() {
+	if !atomic.CompareAndSwapInt32(&c.stoped, 0, 1) {
+		return
+	}
+	c.Object.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		o.Terminate(o)
+		return nil
+	}), true)
+}
+
+func (c *Connector) OnStart() {}
+func (c *Connector) OnTick()  {}
+func (c *Connector) OnStop() {
+	atomic.StoreInt32(&c.stoped, 1)
+	NetModule.unregisteService(c.sc.Id, c)
+}
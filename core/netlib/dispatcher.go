@@ -0,0 +1,83 @@
+package netlib
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/acoderup/goserver.v1/core/logger"
+	"google.golang.org/protobuf/proto"
+)
+
+var (
+	packetHandlerLock sync.RWMutex
+	packetHandlers                    = make(map[int]PacketHandler)
+	packetErrorHook   PacketErrorHook = DefaultPacketErrorHook
+)
+
+// PacketHandler 数据包处理器，在会话所属的Object中执行，返回错误时交给错误钩子处理
+type PacketHandler interface {
+	Process(s *Session, packetid int, msg proto.Message) error
+}
+
+type PacketHandlerWrapper func(s *Session, packetid int, msg proto.Message) error
+
+func (wrapper PacketHandlerWrapper) Process(s *Session, packetid int, msg proto.Message) error {
+	return wrapper(s, packetid, msg)
+}
+
+// PacketErrorHook 未注册、超长、解析失败或者处理出错的数据包的回调，返回false关闭会话
+type PacketErrorHook func(s *Session, packetid int, err error) bool
+
+// DefaultPacketErrorHook 记录日志并关闭会话
+func DefaultPacketErrorHook(s *Session, packetid int, err error) bool {
+	logger.Logger.Warnf("session [%v:%v] packet %v error: %v", s.sc.Name, s.Id, packetid, err)
+	return false
+}
+
+func SetPacketErrorHook(hook PacketErrorHook) {
+	if hook == nil {
+		hook = DefaultPacketErrorHook
+	}
+	packetHandlerLock.Lock()
+	defer packetHandlerLock.Unlock()
+	packetErrorHook = hook
+}
+
+func RegistePacketHandler(packetid int, h PacketHandler) {
+	packetHandlerLock.Lock()
+	defer packetHandlerLock.Unlock()
+	if _, exist := packetHandlers[packetid]; exist {
+		panic(fmt.Sprintf("repeate registe packet handler:%v", packetid))
+	}
+	packetHandlers[packetid] = h
+}
+
+func GetPacketHandler(packetid int) PacketHandler {
+	packetHandlerLock.RLock()
+	defer packetHandlerLock.RUnlock()
+	if h, exist := packetHandlers[packetid]; exist {
+		return h
+	}
+	return nil
+}
+
+// dispatchPacket 分发数据包到注册的处理器，没有处理器时返回false交给SessionHandler
+func dispatchPacket(s *Session, p *Packet) bool {
+	h := GetPacketHandler(p.Id)
+	if h == nil {
+		return false
+	}
+	if err := h.Process(s, p.Id, p.Msg); err != nil {
+		onPacketError(s, p.Id, err)
+	}
+	return true
+}
+
+func onPacketError(s *Session, packetid int, err error) {
+	packetHandlerLock.RLock()
+	hook := packetErrorHook
+	packetHandlerLock.RUnlock()
+	if !hook(s, packetid, err) {
+		s.Close(CloseReason_PacketErr)
+	}
+}
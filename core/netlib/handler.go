@@ -0,0 +1,72 @@
+package netlib
+
+import (
+	"fmt"
+	"sync"
+)
+
+var (
+	//会话建立时在accept/connect协程中查找，注册可以在运行时进行
+	sessionHandlerLock sync.RWMutex
+	sessionHandlers    = make(map[string]SessionHandler)
+)
+
+// SessionHandler 会话生命周期回调，所有回调都在会话自己的Object中执行
+type SessionHandler interface {
+	OnSessionOpened(s *Session)
+	OnSessionClosed(s *Session)
+	OnPacketReceived(s *Session, pack interface{})
+}
+
+type OnSessionOpenedWrapper func(s *Session)
+type OnSessionClosedWrapper func(s *Session)
+type OnPacketReceivedWrapper func(s *Session, pack interface{})
+
+type SessionHandlerWrapper struct {
+	OnSessionOpenedWrapper
+	OnSessionClosedWrapper
+	OnPacketReceivedWrapper
+}
+
+func (wrapper *SessionHandlerWrapper) OnSessionOpened(s *Session) {
+	if wrapper.OnSessionOpenedWrapper != nil {
+		wrapper.OnSessionOpenedWrapper(s)
+	}
+}
+
+func (wrapper *SessionHandlerWrapper) OnSessionClosed(s *Session) {
+	if wrapper.OnSessionClosedWrapper != nil {
+		wrapper.OnSessionClosedWrapper(s)
+	}
+}
+
+func (wrapper *SessionHandlerWrapper) OnPacketReceived(s *Session, pack interface{}) {
+	if wrapper.OnPacketReceivedWrapper != nil {
+		wrapper.OnPacketReceivedWrapper(s, pack)
+	}
+}
+
+func RegisteSessionHandler(name string, h SessionHandler) {
+	sessionHandlerLock.Lock()
+	defer sessionHandlerLock.Unlock()
+	if _, exist := sessionHandlers[name]; exist {
+		panic(fmt.Sprintf("repeate registe session handler:%v", name))
+	}
+	sessionHandlers[name] = h
+}
+
+// UnregisteSessionHandler 注销会话处理器，已经建立的会话不受影响
+func UnregisteSessionHandler(name string) {
+	sessionHandlerLock.Lock()
+	defer sessionHandlerLock.Unlock()
+	delete(sessionHandlers, name)
+}
+
+func GetSessionHandler(name string) SessionHandler {
+	sessionHandlerLock.RLock()
+	defer sessionHandlerLock.RUnlock()
+	if h, exist := sessionHandlers[name]; exist {
+		return h
+	}
+	return nil
+}
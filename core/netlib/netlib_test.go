@@ -0,0 +1,204 @@
+package netlib
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+var (
+	echoOpened = make(chan *Session, 16)
+	echoClosed = make(chan *Session, 16)
+	clientRecv = make(chan []byte, 16)
+)
+
+func init() {
+	RegisteSessionHandler("test-echo", &SessionHandlerWrapper{
+		OnSessionOpenedWrapper: func(s *Session) { echoOpened <- s },
+		OnSessionClosedWrapper: func(s *Session) { echoClosed <- s },
+		OnPacketReceivedWrapper: func(s *Session, pack interface{}) {
+			s.Send(pack)
+		},
+	})
+	RegisteSessionHandler("test-client", &SessionHandlerWrapper{
+		OnPacketReceivedWrapper: func(s *Session, pack interface{}) {
+			clientRecv <- pack.([]byte)
+		},
+	})
+}
+
+// waitSession 等待属于指定服务的会话事件，忽略前面用例遗留的事件
+func waitSession(t *testing.T, ch chan *Session, svc ioService, what string) *Session {
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case s := <-ch:
+			if s.service == svc {
+				return s
+			}
+		case <-timeout:
+			t.Fatalf("wait %v timeout", what)
+			return nil
+		}
+	}
+}
+
+func listen(t *testing.T, sc *SessionConfig) *Acceptor {
+	a, err := NetModule.Listen(sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestEchoLoopback(t *testing.T) {
+	a := listen(t, &SessionConfig{Id: 1, Name: "echo-server", Ip: "127.0.0.1", Handler: "test-echo"})
+	defer a.Stop()
+
+	c, err := NetModule.Connect(&SessionConfig{
+		Id:       2,
+		Name:     "echo-client",
+		Ip:       "127.0.0.1",
+		Port:     a.Addr().(*net.TCPAddr).Port,
+		IsClient: true,
+		Handler:  "test-client",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	waitSession(t, echoOpened, a, "session opened")
+
+	deadline := time.Now().Add(3 * time.Second)
+	for !c.Send([]byte("hello")) {
+		if time.Now().After(deadline) {
+			t.Fatal("connector not connected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case data := <-clientRecv:
+		if !bytes.Equal(data, []byte("hello")) {
+			t.Fatalf("echo mismatch: %q", data)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait echo timeout")
+	}
+}
+
+func TestMaxConn(t *testing.T) {
+	a := listen(t, &SessionConfig{Id: 3, Name: "maxconn-server", Ip: "127.0.0.1", MaxConn: 1, Handler: "test-echo"})
+	defer a.Stop()
+
+	c1, err := net.Dial("tcp", a.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	waitSession(t, echoOpened, a, "first session opened")
+
+	c2, err := net.Dial("tcp", a.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	c2.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err = c2.Read(make([]byte, 1)); err == nil {
+		t.Fatal("connection over MaxConn should be closed")
+	}
+	if n := a.SessionCount(); n != 1 {
+		t.Fatalf("session count=%v, want 1", n)
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	a := listen(t, &SessionConfig{Id: 4, Name: "idle-server", Ip: "127.0.0.1", IdleTimeout: 100, Handler: "test-echo"})
+	defer a.Stop()
+
+	conn, err := net.Dial("tcp", a.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitSession(t, echoOpened, a, "session opened")
+	s := waitSession(t, echoClosed, a, "idle close")
+	if s.CloseReason() != CloseReason_Idle {
+		t.Fatalf("close reason=%v, want %v", s.CloseReason(), CloseReason_Idle)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for a.SessionCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("session count not decreased")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServiceId(t *testing.T) {
+	a := listen(t, &SessionConfig{Id: 5, Name: "id-server", Ip: "127.0.0.1", Handler: "test-echo"})
+	if _, err := NetModule.Listen(&SessionConfig{Id: 5, Name: "id-dup-server", Ip: "127.0.0.1"}); err == nil {
+		t.Fatal("listen with duplicate id should fail")
+	}
+	if _, err := NetModule.Connect(&SessionConfig{Id: 5, Name: "id-dup-client", Ip: "127.0.0.1", IsClient: true}); err == nil {
+		t.Fatal("connect with duplicate id should fail")
+	}
+	a1 := listen(t, &SessionConfig{Name: "id-auto-1", Ip: "127.0.0.1"})
+	defer a1.Stop()
+	a2 := listen(t, &SessionConfig{Name: "id-auto-2", Ip: "127.0.0.1"})
+	defer a2.Stop()
+	if a1.sc.Id == 0 || a1.sc.Id == a2.sc.Id || NetModule.GetAcceptor(a1.sc.Id) != a1 || NetModule.GetAcceptor(a2.sc.Id) != a2 {
+		t.Fatalf("auto allocated ids %v %v", a1.sc.Id, a2.sc.Id)
+	}
+
+	//服务停止后id可以重新使用
+	a.Stop()
+	deadline := time.Now().Add(3 * time.Second)
+	for NetModule.GetAcceptor(5) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("stopped acceptor not unregisted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	a = listen(t, &SessionConfig{Id: 5, Name: "id-server-again", Ip: "127.0.0.1"})
+	a.Stop()
+}
+
+func TestConnectorReconnect(t *testing.T) {
+	a := listen(t, &SessionConfig{Id: 6, Name: "reconn-server", Ip: "127.0.0.1", Handler: "test-echo"})
+	defer a.Stop()
+
+	c, err := NetModule.Connect(&SessionConfig{
+		Id:             7,
+		Name:           "reconn-client",
+		Ip:             "127.0.0.1",
+		Port:           a.Addr().(*net.TCPAddr).Port,
+		IsClient:       true,
+		IsAutoReconn:   true,
+		ReconnInterval: 10,
+		Handler:        "test-client",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Stop()
+	s := waitSession(t, echoOpened, a, "session opened")
+	s.Close("test")
+	waitSession(t, echoOpened, a, "session reopened")
+
+	deadline := time.Now().Add(3 * time.Second)
+	for !c.Send([]byte("again")) {
+		if time.Now().After(deadline) {
+			t.Fatal("connector not reconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case data := <-clientRecv:
+		if !bytes.Equal(data, []byte("again")) {
+			t.Fatalf("echo mismatch: %q", data)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait echo timeout")
+	}
+}
@@ -0,0 +1,226 @@
+package netlib
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/acoderup/goserver.v1/core/module"
+)
+
+var (
+	NetModule           = newNetEngine()
+	ErrNetModuleStopped = errors.New("net module is stopped")
+)
+
+// NetEngine 网络模块，管理所有的Acceptor和Connector
+// 所有服务都是netlib Object的子Object，会话又是服务的子Object
+type NetEngine struct {
+	*basic.Object
+	lock       sync.Mutex
+	acceptors  map[int]*Acceptor
+	connectors map[int]*Connector
+	quit       bool
+}
+
+func newNetEngine() *NetEngine {
+	return &NetEngine{
+		acceptors:  make(map[int]*Acceptor),
+		connectors: make(map[int]*Connector),
+	}
+}
+
+func (this *NetEngine) ModuleName() string {
+	return module.ModuleName_Net
+}
+
+func (this *NetEngine) Init() {
+	for i := 0; i < len(Config.IoServices); i++ {
+		sc := &Config.IoServices[i]
+		if sc.IsClient {
+			if _, err := this.Connect(sc); err != nil {
+				logger.Logger.Errorf("NetModule connect [%v] %v error: %v", sc.Name, sc.Addr(), err)
+			}
+		} else if _, err := this.Listen(sc); err != nil {
+			logger.Logger.Errorf("NetModule listen [%v] %v error: %v", sc.Name, sc.Addr(), err)
+		}
+	}
+}
+
+func (this *NetEngine) Update() {
+}
+
+func (this *NetEngine) Shutdown() {
+	this.lock.Lock()
+	if this.quit {
+		this.lock.Unlock()
+		return
+	}
+	this.quit = true
+	obj := this.Object
+	this.lock.Unlock()
+
+	this.stopServices()
+	if obj == nil {
+		module.UnregisteModule(this)
+		return
+	}
+	//netlib Object停止时确认模块退出
+	obj.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		o.Terminate(o)
+		return nil
+	}), true)
+}
+
+// Listen 启动一个Acceptor
+func (this *NetEngine) Listen(sc *SessionConfig) (*Acceptor, error) {
+	if err := sc.Init(); err != nil {
+		return nil, err
+	}
+	parent, err := this.getObject()
+	if err != nil {
+		return nil, err
+	}
+	a := newAcceptor(sc)
+	if err = this.registeService(sc, a, nil); err != nil {
+		return nil, err
+	}
+	if err = a.start(parent); err != nil {
+		this.lock.Lock()
+		delete(this.acceptors, sc.Id)
+		this.lock.Unlock()
+		return nil, err
+	}
+	return a, nil
+}
+
+// Connect 启动一个Connector，连接失败时根据配置自动重连
+func (this *NetEngine) Connect(sc *SessionConfig) (*Connector, error) {
+	if err := sc.Init(); err != nil {
+		return nil, err
+	}
+	parent, err := this.getObject()
+	if err != nil {
+		return nil, err
+	}
+	c := newConnector(sc)
+	if err = this.registeService(sc, nil, c); err != nil {
+		return nil, err
+	}
+	c.start(parent)
+	return c, nil
+}
+
+// registeService 登记服务，id同时是服务Object的id，未指定时自动分配，重复时返回错误
+func (this *NetEngine) registeService(sc *SessionConfig, a *Acceptor, c *Connector) error {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if sc.Id == 0 {
+		sc.Id = core.NextObjId()
+	}
+	if this.acceptors[sc.Id] != nil || this.connectors[sc.Id] != nil {
+		return fmt.Errorf("session config [%v] id [%v] already in use", sc.Name, sc.Id)
+	}
+	if a != nil {
+		this.acceptors[sc.Id] = a
+	} else {
+		this.connectors[sc.Id] = c
+	}
+	return nil
+}
+
+// unregisteService 服务停止后释放id，id已经被新的服务占用时不处理
+func (this *NetEngine) unregisteService(id int, svc ioService) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if a, ok := this.acceptors[id]; ok && ioService(a) == svc {
+		delete(this.acceptors, id)
+	}
+	if c, ok := this.connectors[id]; ok && ioService(c) == svc {
+		delete(this.connectors, id)
+	}
+}
+
+func (this *NetEngine) GetAcceptor(id int) *Acceptor {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.acceptors[id]
+}
+
+func (this *NetEngine) GetConnector(id int) *Connector {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.connectors[id]
+}
+
+// StopService 停止指定id的服务
+func (this *NetEngine) StopService(id int) {
+	this.lock.Lock()
+	a := this.acceptors[id]
+	c := this.connectors[id]
+	delete(this.acceptors, id)
+	delete(this.connectors, id)
+	this.lock.Unlock()
+	if a != nil {
+		a.Stop()
+	}
+	if c != nil {
+		c.Stop()
+	}
+}
+
+func (this *NetEngine) stopServices() {
+	this.lock.Lock()
+	acceptors := this.acceptors
+	connectors := this.connectors
+	this.acceptors = make(map[int]*Acceptor)
+	this.connectors = make(map[int]*Connector)
+	this.lock.Unlock()
+	for _, a := range acceptors {
+		a.Stop()
+	}
+	for _, c := range connectors {
+		c.Stop()
+	}
+}
+
+// getObject 第一次启动服务时创建netlib Object
+func (this *NetEngine) getObject() (*basic.Object, error) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.quit {
+		return nil, ErrNetModuleStopped
+	}
+	if this.Object == nil {
+		this.Object = basic.NewObject(core.ObjId_NetlibId, "netlib", basic.Options{
+			Interval:     time.Second,
+			MaxDone:      1024,
+			QueueBacklog: 1024,
+		}, this)
+		this.UserData = this
+		core.LaunchChild(this.Object)
+	}
+	return this.Object, nil
+}
+
+func (this *NetEngine) OnStart() {}
+func (this *NetEngine) OnTick()  {}
+func (this *NetEngine) OnStop() {
+	this.lock.Lock()
+	quit := this.quit
+	this.quit = true
+	this.lock.Unlock()
+	this.stopServices()
+	if quit {
+		module.UnregisteModule(this)
+	}
+}
+
+func init() {
+	module.RegisteModule(NetModule, time.Second, 0)
+}
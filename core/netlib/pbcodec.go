@@ -0,0 +1,230 @@
+package netlib
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	ProtobufCodecName = "protobuf"
+
+	//包头：4字节大端长度(不含自身) + 2字节包id + 1字节标记
+	pbHeadSize   = 4
+	pbIdSize     = 2
+	pbFlagSize   = 1
+	pbPrefixSize = pbIdSize + pbFlagSize
+
+	PacketFlag_Compressed byte = 1 << 0
+)
+
+var (
+	packetLock    sync.RWMutex
+	packetsById   = make(map[int]*packetInfo)
+	packetsByType = make(map[reflect.Type]*packetInfo)
+
+	ErrUnknownPacket = errors.New("unknown packet id")
+	ErrDecompress    = errors.New("decompress packet failed")
+
+	flateWriterPool = sync.Pool{
+		New: func() interface{} {
+			w, _ := flate.NewWriter(nil, flate.DefaultCompression)
+			return w
+		},
+	}
+)
+
+// Packet protobuf编解码器解出的数据包
+type Packet struct {
+	Id  int
+	Msg proto.Message
+}
+
+// PacketError 单个数据包非法，连接本身仍然可用，交给错误钩子决定是否关闭会话
+type PacketError struct {
+	PacketId int
+	Size     int
+	Err      error
+}
+
+func (e *PacketError) Error() string {
+	return fmt.Sprintf("packet %v(size=%v): %v", e.PacketId, e.Size, e.Err)
+}
+
+type packetInfo struct {
+	id      int
+	typ     reflect.Type
+	msg     proto.Message
+	maxSize int
+}
+
+// RegistePacket 注册包id和protobuf消息的对应关系，maxSize限制该消息解压后的长度，0表示只受SessionConfig.MaxPacket限制
+func RegistePacket(packetid int, msg proto.Message, maxSize int) {
+	if packetid < 0 || packetid > 0xffff {
+		panic(fmt.Sprintf("packet id out of range:%v", packetid))
+	}
+	packetLock.Lock()
+	defer packetLock.Unlock()
+	if _, exist := packetsById[packetid]; exist {
+		panic(fmt.Sprintf("repeate registe packet:%v", packetid))
+	}
+	typ := reflect.TypeOf(msg)
+	if _, exist := packetsByType[typ]; exist {
+		panic(fmt.Sprintf("repeate registe packet type:%v", typ))
+	}
+	pi := &packetInfo{id: packetid, typ: typ, msg: msg, maxSize: maxSize}
+	packetsById[packetid] = pi
+	packetsByType[typ] = pi
+}
+
+// GetPacketId 获取消息对应的包id，未注册返回-1
+func GetPacketId(msg proto.Message) int {
+	packetLock.RLock()
+	defer packetLock.RUnlock()
+	if pi, exist := packetsByType[reflect.TypeOf(msg)]; exist {
+		return pi.id
+	}
+	return -1
+}
+
+func NewPacket(packetid int) proto.Message {
+	if pi, exist := getPacketInfo(packetid); exist {
+		return pi.msg.ProtoReflect().New().Interface()
+	}
+	return nil
+}
+
+func getPacketInfo(packetid int) (*packetInfo, bool) {
+	packetLock.RLock()
+	defer packetLock.RUnlock()
+	pi, exist := packetsById[packetid]
+	return pi, exist
+}
+
+// pbCodec 长度前缀的protobuf编解码器，数据包类型为proto.Message或者*Packet
+type pbCodec struct {
+}
+
+func (c *pbCodec) Encode(s *Session, pack interface{}) ([]byte, error) {
+	var packetid int
+	var msg proto.Message
+	switch p := pack.(type) {
+	case *Packet:
+		packetid, msg = p.Id, p.Msg
+	case proto.Message:
+		packetid, msg = GetPacketId(p), p
+	default:
+		return nil, ErrPacketType
+	}
+	pi, exist := getPacketInfo(packetid)
+	if !exist {
+		return nil, &PacketError{PacketId: packetid, Err: ErrUnknownPacket}
+	}
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	if pi.maxSize > 0 && len(data) > pi.maxSize {
+		return nil, &PacketError{PacketId: packetid, Size: len(data), Err: ErrPacketTooLarge}
+	}
+	var flag byte
+	if s.sc.CompressThreshold > 0 && len(data) > s.sc.CompressThreshold {
+		if compressed, err := compress(data); err == nil && len(compressed) < len(data) {
+			data = compressed
+			flag |= PacketFlag_Compressed
+		}
+	}
+	if len(data)+pbPrefixSize > s.sc.MaxPacket {
+		return nil, &PacketError{PacketId: packetid, Size: len(data), Err: ErrPacketTooLarge}
+	}
+	buf := make([]byte, pbHeadSize+pbPrefixSize+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(pbPrefixSize+len(data)))
+	binary.BigEndian.PutUint16(buf[pbHeadSize:], uint16(packetid))
+	buf[pbHeadSize+pbIdSize] = flag
+	copy(buf[pbHeadSize+pbPrefixSize:], data)
+	return buf, nil
+}
+
+func (c *pbCodec) Decode(s *Session, r io.Reader) (interface{}, error) {
+	var head [pbHeadSize + pbPrefixSize]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint32(head[:]))
+	packetid := int(binary.BigEndian.Uint16(head[pbHeadSize:]))
+	flag := head[pbHeadSize+pbIdSize]
+	//整个包超过会话上限时无法保证流的可靠性，直接断开
+	if n < pbPrefixSize || n > s.sc.MaxPacket {
+		return nil, ErrPacketTooLarge
+	}
+	size := n - pbPrefixSize
+	pi, exist := getPacketInfo(packetid)
+	if !exist || (pi.maxSize > 0 && size > pi.maxSize && flag&PacketFlag_Compressed == 0) {
+		//丢弃包体，连接继续可用
+		if _, err := io.CopyN(io.Discard, r, int64(size)); err != nil {
+			return nil, err
+		}
+		if !exist {
+			return nil, &PacketError{PacketId: packetid, Size: size, Err: ErrUnknownPacket}
+		}
+		return nil, &PacketError{PacketId: packetid, Size: size, Err: ErrPacketTooLarge}
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if flag&PacketFlag_Compressed != 0 {
+		limit := pi.maxSize
+		if limit <= 0 {
+			limit = s.sc.MaxPacket
+		}
+		var err error
+		if data, err = decompress(data, limit); err != nil {
+			return nil, &PacketError{PacketId: packetid, Size: size, Err: err}
+		}
+	}
+	msg := pi.msg.ProtoReflect().New().Interface()
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, &PacketError{PacketId: packetid, Size: len(data), Err: err}
+	}
+	return &Packet{Id: packetid, Msg: msg}, nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := flateWriterPool.Get().(*flate.Writer)
+	defer flateWriterPool.Put(w)
+	w.Reset(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress 解压后的长度超过limit视为非法包，避免压缩炸弹
+func decompress(data []byte, limit int) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+	out, err := io.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, ErrDecompress
+	}
+	if len(out) > limit {
+		return nil, ErrPacketTooLarge
+	}
+	return out, nil
+}
+
+func init() {
+	RegisteCodec(ProtobufCodecName, &pbCodec{})
+}
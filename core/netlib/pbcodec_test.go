@@ -0,0 +1,167 @@
+package netlib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const (
+	testPacketString = 100
+	testPacketInt    = 101
+)
+
+var (
+	pbServerRecv = make(chan string, 16)
+	pbErrors     = make(chan int, 16)
+)
+
+func init() {
+	RegistePacket(testPacketString, &wrapperspb.StringValue{}, 1024)
+	RegistePacket(testPacketInt, &wrapperspb.Int64Value{}, 0)
+	RegistePacketHandler(testPacketString, PacketHandlerWrapper(func(s *Session, packetid int, msg proto.Message) error {
+		pbServerRecv <- msg.(*wrapperspb.StringValue).GetValue()
+		return nil
+	}))
+}
+
+func newTestSession(sc *SessionConfig) *Session {
+	sc.Codec = ProtobufCodecName
+	sc.Init()
+	return &Session{sc: sc}
+}
+
+func TestPBCodecRoundTrip(t *testing.T) {
+	s := newTestSession(&SessionConfig{CompressThreshold: 64})
+	c := GetCodec(ProtobufCodecName)
+	for _, v := range []string{"hello", strings.Repeat("x", 512)} {
+		data, err := c.Encode(s, wrapperspb.String(v))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if compressed := data[pbHeadSize+pbIdSize]&PacketFlag_Compressed != 0; compressed != (len(v) > 64) {
+			t.Fatalf("compress flag=%v for size %v", compressed, len(v))
+		}
+		pack, err := c.Decode(s, bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := pack.(*Packet)
+		if p.Id != testPacketString || p.Msg.(*wrapperspb.StringValue).GetValue() != v {
+			t.Fatalf("decode mismatch: %v", p)
+		}
+	}
+}
+
+func TestPBCodecPacketError(t *testing.T) {
+	s := newTestSession(&SessionConfig{})
+	c := GetCodec(ProtobufCodecName)
+	if _, err := c.Encode(s, wrapperspb.Bool(true)); err == nil {
+		t.Fatal("encode unregisted message should fail")
+	}
+
+	frame := func(packetid int, payload []byte) []byte {
+		buf := make([]byte, pbHeadSize+pbPrefixSize+len(payload))
+		binary.BigEndian.PutUint32(buf, uint32(pbPrefixSize+len(payload)))
+		binary.BigEndian.PutUint16(buf[pbHeadSize:], uint16(packetid))
+		copy(buf[pbHeadSize+pbPrefixSize:], payload)
+		return buf
+	}
+	good, _ := c.Encode(s, wrapperspb.Int64(7))
+	stream := bytes.NewReader(append(append(frame(999, []byte("junk")), frame(testPacketString, make([]byte, 2048))...), good...))
+
+	for _, want := range []error{ErrUnknownPacket, ErrPacketTooLarge} {
+		_, err := c.Decode(s, stream)
+		var pe *PacketError
+		if !errors.As(err, &pe) || pe.Err != want {
+			t.Fatalf("err=%v, want %v", err, want)
+		}
+	}
+	//非法包被丢弃后，后续的包仍然可以正常解析
+	pack, err := c.Decode(s, stream)
+	if err != nil || pack.(*Packet).Msg.(*wrapperspb.Int64Value).GetValue() != 7 {
+		t.Fatalf("decode after packet error failed: %v %v", pack, err)
+	}
+}
+
+func TestPBDispatchLoopback(t *testing.T) {
+	SetPacketErrorHook(func(s *Session, packetid int, err error) bool {
+		pbErrors <- packetid
+		return true
+	})
+	defer SetPacketErrorHook(nil)
+
+	a := listen(t, &SessionConfig{Id: 10, Name: "pb-server", Ip: "127.0.0.1", Codec: ProtobufCodecName})
+	defer a.Stop()
+
+	conn, err := net.Dial("tcp", a.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	s := newTestSession(&SessionConfig{})
+	c := GetCodec(ProtobufCodecName)
+	unknown := []byte{0, 0, 0, 4, 0x03, 0xe7, 0, 'x'}
+	good, _ := c.Encode(s, wrapperspb.String("dispatch"))
+	conn.Write(append(unknown, good...))
+
+	select {
+	case id := <-pbErrors:
+		if id != 999 {
+			t.Fatalf("error hook packet id=%v", id)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait error hook timeout")
+	}
+	select {
+	case v := <-pbServerRecv:
+		if v != "dispatch" {
+			t.Fatalf("handler got %q", v)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait handler timeout")
+	}
+}
+
+func TestPacketRegistryConcurrent(t *testing.T) {
+	s := newTestSession(&SessionConfig{Name: "registry"})
+	c := GetCodec(ProtobufCodecName)
+	registed := GetPacketId(&wrapperspb.UInt32Value{}) >= 0
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if registed {
+			return
+		}
+		//会话协程编解码的同时注册新的消息和处理器
+		RegistePacket(testPacketInt+1, &wrapperspb.UInt32Value{}, 0)
+		RegistePacketHandler(testPacketInt+1, PacketHandlerWrapper(func(s *Session, packetid int, msg proto.Message) error {
+			return nil
+		}))
+		SetPacketErrorHook(nil)
+	}()
+	for i := 0; i < 100; i++ {
+		data, err := c.Encode(s, wrapperspb.String("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = c.Decode(s, bytes.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+		GetPacketHandler(testPacketInt + 1)
+		GetPacketId(&wrapperspb.UInt32Value{})
+	}
+	wg.Wait()
+	if GetPacketId(&wrapperspb.UInt32Value{}) != testPacketInt+1 || GetPacketHandler(testPacketInt+1) == nil {
+		t.Fatal("packet not registed")
+	}
+}
@@ -0,0 +1,234 @@
+package netlib
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/container"
+	"github.com/acoderup/goserver.v1/core/logger"
+)
+
+const (
+	CloseReason_Active    = "active close"
+	CloseReason_ReadErr   = "read error"
+	CloseReason_WriteErr  = "write error"
+	CloseReason_Idle      = "idle timeout"
+	CloseReason_SendFull  = "send queue full"
+	CloseReason_PacketErr = "packet error"
+	CloseReason_Terminate = "terminated"
+)
+
+// ioService 会话所属的服务(Acceptor、Connector)
+type ioService interface {
+	onSessionClosed(s *Session)
+}
+
+// Session 网络会话
+// 每个会话是所属服务Object的子Object，所有回调都在会话自己的Object中串行执行
+type Session struct {
+	*basic.Object
+	Id          int
+	sc          *SessionConfig
+	conn        net.Conn
+	codec       PacketCodec
+	handler     SessionHandler
+	service     ioService
+	sendQ       chan []byte
+	quit        chan struct{}
+	closed      int32
+	closeReason string
+	attrs       *container.SynchronizedMap
+	recvPackCnt int64
+	sendPackCnt int64
+	lastRecv    int64
+}
+
+func newSession(id int, conn net.Conn, sc *SessionConfig, svc ioService) *Session {
+	s := &Session{
+		Id:       id,
+		sc:       sc,
+		conn:     conn,
+		codec:    GetCodec(sc.Codec),
+		handler:  GetSessionHandler(sc.Handler),
+		service:  svc,
+		sendQ:    make(chan []byte, sc.MaxPend),
+		quit:     make(chan struct{}),
+		attrs:    container.NewSynchronizedMap(),
+		lastRecv: time.Now().UnixNano(),
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetNoDelay(sc.NoDelay)
+		if sc.RcvBuff > 0 {
+			tcpConn.SetReadBuffer(sc.RcvBuff)
+		}
+		if sc.SndBuff > 0 {
+			tcpConn.SetWriteBuffer(sc.SndBuff)
+		}
+	}
+	return s
+}
+
+func (s *Session) start(parent *basic.Object) {
+	s.Object = basic.NewObject(s.Id, s.sc.Name, s.sc.Options, s)
+	s.UserData = s
+	parent.LaunchChild(s.Object)
+	//先投递打开事件，保证OnSessionOpened在所有数据包之前执行
+	SendSessionOpened(s)
+	go s.readRoutine()
+	go s.writeRoutine()
+}
+
+func (s *Session) readRoutine() {
+	var reader io.Reader = s.conn
+	if _, ok := s.conn.(MessageReader); !ok {
+		//面向消息的连接自带缓冲，并且编解码器需要直接访问消息边界
+		reader = bufio.NewReaderSize(s.conn, s.readBufSize())
+	}
+	for {
+		if s.sc.IdleTimeout > 0 {
+			s.conn.SetReadDeadline(time.Now().Add(s.sc.IdleTimeout))
+		}
+		pack, err := s.codec.Decode(s, reader)
+		if pe, ok := err.(*PacketError); ok {
+			//单个包非法，在会话的Object中交给错误钩子处理
+			SendSessionPacketError(s, pe)
+			continue
+		}
+		if err != nil {
+			reason := CloseReason_ReadErr
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				reason = CloseReason_Idle
+			}
+			if !s.IsClosed() {
+				logger.Logger.Tracef("session [%v:%v] %v: %v", s.sc.Name, s.Id, reason, err)
+			}
+			s.Close(reason)
+			return
+		}
+		atomic.AddInt64(&s.recvPackCnt, 1)
+		atomic.StoreInt64(&s.lastRecv, time.Now().UnixNano())
+		SendSessionPacket(s, pack)
+	}
+}
+
+func (s *Session) writeRoutine() {
+	for {
+		select {
+		case data := <-s.sendQ:
+			s.conn.SetWriteDeadline(time.Now().Add(s.sc.WriteTimeout))
+			if _, err := s.conn.Write(data); err != nil {
+				logger.Logger.Tracef("session [%v:%v] write error: %v", s.sc.Name, s.Id, err)
+				s.Close(CloseReason_WriteErr)
+				return
+			}
+			atomic.AddInt64(&s.sendPackCnt, 1)
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *Session) readBufSize() int {
+	if s.sc.RcvBuff > 0 {
+		return s.sc.RcvBuff
+	}
+	return 4096
+}
+
+// Send 发送数据包，可以在任意协程中调用，发送队列满时会话会被关闭
+func (s *Session) Send(pack interface{}) bool {
+	if s.IsClosed() {
+		return false
+	}
+	data, err := s.codec.Encode(s, pack)
+	if err != nil {
+		logger.Logger.Warnf("session [%v:%v] encode packet error: %v", s.sc.Name, s.Id, err)
+		return false
+	}
+	return s.SendRaw(data)
+}
+
+// SendRaw 发送已经编码好的数据
+func (s *Session) SendRaw(data []byte) bool {
+	if s.IsClosed() {
+		return false
+	}
+	select {
+	case s.sendQ <- data:
+		return true
+	default:
+		logger.Logger.Warnf("session [%v:%v] send queue full(%v), close it", s.sc.Name, s.Id, s.sc.MaxPend)
+		s.Close(CloseReason_SendFull)
+		return false
+	}
+}
+
+// Close 关闭会话，可以在任意协程中调用，OnSessionClosed在会话的Object中回调
+func (s *Session) Close(reason string) {
+	if !s.shutdownConn(reason) {
+		return
+	}
+	SendSessionClose(s)
+}
+
+func (s *Session) shutdownConn(reason string) bool {
+	if !atomic.CompareAndSwapInt32(&s.closed, 0, 1) {
+		return false
+	}
+	s.closeReason = reason
+	s.conn.Close()
+	close(s.quit)
+	return true
+}
+
+func (s *Session) IsClosed() bool {
+	return atomic.LoadInt32(&s.closed) != 0
+}
+
+func (s *Session) CloseReason() string {
+	return s.closeReason
+}
+
+func (s *Session) GetSessionConfig() *SessionConfig {
+	return s.sc
+}
+
+func (s *Session) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+func (s *Session) LocalAddr() net.Addr {
+	return s.conn.LocalAddr()
+}
+
+func (s *Session) SetAttribute(k, v interface{}) {
+	s.attrs.Set(k, v)
+}
+
+func (s *Session) GetAttribute(k interface{}) interface{} {
+	return s.attrs.Get(k)
+}
+
+func (s *Session) RemoveAttribute(k interface{}) {
+	s.attrs.Delete(k)
+}
+
+func (s *Session) GetStats() (recvPackCnt, sendPackCnt int64, lastRecv time.Time) {
+	return atomic.LoadInt64(&s.recvPackCnt), atomic.LoadInt64(&s.sendPackCnt), time.Unix(0, atomic.LoadInt64(&s.lastRecv))
+}
+
+func (s *Session) OnStart() {}
+func (s *Session) OnTick()  {}
+func (s *Session) OnStop() {
+	s.shutdownConn(CloseReason_Terminate)
+	if s.handler != nil {
+		s.handler.OnSessionClosed(s)
+	}
+	if s.service != nil {
+		s.service.onSessionClosed(s)
+	}
+}
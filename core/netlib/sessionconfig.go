@@ -0,0 +1,128 @@
+package netlib
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/basic"
+)
+
+const (
+	Protocol_TCP = "tcp"
+	Protocol_WS  = "ws"
+)
+
+type SessionConfig struct {
+	//服务id，同时作为服务Object的id，同一进程内不能重复，0表示自动分配
+	Id   int
+	Name string
+	Ip   string
+	Port int
+	//传输协议，tcp(默认)或者ws，ws只对Acceptor有效
+	Protocol string
+	//WebSocket的http路径，默认为/
+	Path string
+	//WebSocket发送使用文本帧，默认使用二进制帧，接收两种帧都支持
+	TextFrame bool
+	//WebSocket允许的Origin，为空时只允许同源或者没有Origin的请求，*表示不检查
+	Origins []string
+	//WebSocket单帧最大长度，默认为MaxPacket加包头
+	MaxFrame int
+	//WebSocket发送ping的间隔(毫秒)，0表示不发送
+	PingInterval time.Duration
+	//true表示主动连接(Connector)，false表示监听(Acceptor)
+	IsClient bool
+	//连接断开后是否自动重连，只对Connector有效
+	IsAutoReconn bool
+	//重连间隔(毫秒)
+	ReconnInterval time.Duration
+	//最大连接数，只对Acceptor有效，0表示不限制
+	MaxConn int
+	//socket读写缓冲区大小
+	RcvBuff int
+	SndBuff int
+	//单个数据包的最大长度
+	MaxPacket int
+	//发送队列长度，队列满时关闭会话
+	MaxPend int
+	//空闲超时(毫秒)，超过该时间没有收到数据关闭会话，0表示不检查
+	IdleTimeout time.Duration
+	//写超时(毫秒)
+	WriteTimeout time.Duration
+	NoDelay      bool
+	//编解码器名称，见RegisteCodec
+	Codec string
+	//数据包超过该长度时压缩(字节)，0表示不压缩，只对protobuf编解码器有效
+	CompressThreshold int
+	//会话处理器名称，见RegisteSessionHandler
+	Handler string
+	//会话Object的参数
+	Options basic.Options
+
+	inited bool
+}
+
+func (sc *SessionConfig) Init() error {
+	if sc.inited {
+		return nil
+	}
+	sc.inited = true
+	if sc.Name == "" {
+		sc.Name = fmt.Sprintf("%v:%v", sc.Ip, sc.Port)
+	}
+	switch sc.Protocol {
+	case "":
+		sc.Protocol = Protocol_TCP
+	case Protocol_TCP, Protocol_WS:
+	default:
+		return fmt.Errorf("session config [%v] protocol [%v] not supported", sc.Name, sc.Protocol)
+	}
+	if sc.Path == "" {
+		sc.Path = "/"
+	}
+	if sc.PingInterval > 0 {
+		sc.PingInterval = time.Millisecond * sc.PingInterval
+	}
+	if sc.ReconnInterval <= 0 {
+		sc.ReconnInterval = time.Second * 5
+	} else {
+		sc.ReconnInterval = time.Millisecond * sc.ReconnInterval
+	}
+	if sc.MaxPacket <= 0 {
+		sc.MaxPacket = 64 * 1024
+	}
+	if sc.MaxFrame <= 0 {
+		sc.MaxFrame = sc.MaxPacket + 64
+	}
+	if sc.MaxPend <= 0 {
+		sc.MaxPend = 1024
+	}
+	if sc.IdleTimeout > 0 {
+		sc.IdleTimeout = time.Millisecond * sc.IdleTimeout
+	}
+	if sc.WriteTimeout <= 0 {
+		sc.WriteTimeout = time.Second * 30
+	} else {
+		sc.WriteTimeout = time.Millisecond * sc.WriteTimeout
+	}
+	if sc.Codec == "" {
+		sc.Codec = DefaultCodecName
+	}
+	if GetCodec(sc.Codec) == nil {
+		return fmt.Errorf("session config [%v] codec [%v] not registed", sc.Name, sc.Codec)
+	}
+	if sc.Options.QueueBacklog <= 0 {
+		sc.Options.QueueBacklog = 1024
+	}
+	if sc.Options.MaxDone <= 0 {
+		sc.Options.MaxDone = 1024
+	}
+	if sc.Options.Interval > 0 {
+		sc.Options.Interval = time.Millisecond * sc.Options.Interval
+	}
+	return nil
+}
+
+func (sc *SessionConfig) Addr() string {
+	return fmt.Sprintf("%v:%v", sc.Ip, sc.Port)
+}
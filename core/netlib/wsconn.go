@@ -0,0 +1,149 @@
+package netlib
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// MessageReader 面向消息的连接，按帧读取完整的消息，见textCodec
+type MessageReader interface {
+	ReadMessage() ([]byte, error)
+}
+
+// wsConn 把WebSocket连接适配为net.Conn，会话的读写协程不需要区分传输协议
+// 每次Write发送一帧，Read把连续的帧当作字节流读取
+type wsConn struct {
+	*websocket.Conn
+	sc        *SessionConfig
+	msgType   int
+	reader    io.Reader
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func newWSConn(conn *websocket.Conn, sc *SessionConfig) *wsConn {
+	c := &wsConn{
+		Conn:    conn,
+		sc:      sc,
+		msgType: websocket.BinaryMessage,
+		done:    make(chan struct{}),
+	}
+	if sc.TextFrame {
+		c.msgType = websocket.TextMessage
+	}
+	conn.SetReadLimit(int64(sc.MaxFrame))
+	conn.SetPongHandler(func(string) error {
+		//收到pong说明连接还活着，延长读超时
+		if sc.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(sc.IdleTimeout))
+		}
+		return nil
+	})
+	if sc.PingInterval > 0 {
+		go c.pingRoutine()
+	}
+	return c
+}
+
+func (c *wsConn) pingRoutine() {
+	ticker := time.NewTicker(c.sc.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.sc.WriteTimeout)); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *wsConn) Read(b []byte) (int, error) {
+	for {
+		if c.reader == nil {
+			_, r, err := c.NextReader()
+			if err != nil {
+				return 0, err
+			}
+			c.reader = r
+		}
+		n, err := c.reader.Read(b)
+		if err == io.EOF {
+			c.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	c.reader = nil
+	_, r, err := c.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func (c *wsConn) Write(b []byte) (int, error) {
+	if err := c.WriteMessage(c.msgType, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *wsConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *wsConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	})
+	return c.Conn.Close()
+}
+
+// newUpgrader 根据配置创建WebSocket握手器
+func newUpgrader(sc *SessionConfig) *websocket.Upgrader {
+	u := &websocket.Upgrader{
+		ReadBufferSize:  sc.RcvBuff,
+		WriteBufferSize: sc.SndBuff,
+	}
+	if len(sc.Origins) > 0 {
+		u.CheckOrigin = func(r *http.Request) bool {
+			return checkOrigin(sc.Origins, r.Header.Get("Origin"))
+		}
+	}
+	return u
+}
+
+func checkOrigin(origins []string, origin string) bool {
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	for _, o := range origins {
+		if o == "*" || strings.EqualFold(o, origin) || strings.EqualFold(o, u.Host) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package netlib
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func wsURL(a *Acceptor, path string) string {
+	return fmt.Sprintf("ws://%v%v", a.Addr(), path)
+}
+
+func TestWSBinaryEcho(t *testing.T) {
+	a := listen(t, &SessionConfig{Id: 20, Name: "ws-server", Ip: "127.0.0.1", Protocol: Protocol_WS, Path: "/ws", Handler: "test-echo", PingInterval: 20})
+	defer a.Stop()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(a, "/ws"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+	waitSession(t, echoOpened, a, "session opened")
+
+	payload := []byte("hello ws")
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	if err = conn.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	//读取过程中ping由PingHandler处理
+	mt, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt != websocket.BinaryMessage || string(data[4:]) != string(payload) {
+		t.Fatalf("echo mismatch: type=%v data=%q", mt, data)
+	}
+	go conn.ReadMessage()
+	select {
+	case <-pinged:
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait ping timeout")
+	}
+}
+
+func TestWSTextFrame(t *testing.T) {
+	a := listen(t, &SessionConfig{Id: 21, Name: "ws-text-server", Ip: "127.0.0.1", Protocol: Protocol_WS, TextFrame: true, Codec: TextCodecName, Handler: "test-echo"})
+	defer a.Stop()
+
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(a, "/"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err = conn.WriteMessage(websocket.TextMessage, []byte(`{"cmd":"hi"}`)); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	mt, data, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mt != websocket.TextMessage || string(data) != `{"cmd":"hi"}` {
+		t.Fatalf("echo mismatch: type=%v data=%q", mt, data)
+	}
+}
+
+func TestWSOriginAndMaxFrame(t *testing.T) {
+	a := listen(t, &SessionConfig{Id: 22, Name: "ws-origin-server", Ip: "127.0.0.1", Protocol: Protocol_WS, Origins: []string{"game.example.com"}, MaxFrame: 16, Handler: "test-echo"})
+	defer a.Stop()
+
+	header := http.Header{}
+	header.Set("Origin", "http://evil.example.com")
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL(a, "/"), header); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("origin not checked, err=%v", err)
+	}
+
+	header.Set("Origin", "https://game.example.com")
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(a, "/"), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitSession(t, echoOpened, a, "session opened")
+	conn.WriteMessage(websocket.BinaryMessage, make([]byte, 64))
+	s := waitSession(t, echoClosed, a, "max frame close")
+	if s.CloseReason() != CloseReason_ReadErr {
+		t.Fatalf("close reason=%v", s.CloseReason())
+	}
+}
+
+func TestCheckOrigin(t *testing.T) {
+	cases := []struct {
+		origins []string
+		origin  string
+		want    bool
+	}{
+		{[]string{"*"}, "http://any.com", true},
+		{[]string{"a.com"}, "http://a.com", true},
+		{[]string{"http://a.com"}, "http://a.com", true},
+		{[]string{"a.com"}, "http://b.com", false},
+		{[]string{"a.com"}, "", true},
+	}
+	for _, c := range cases {
+		if got := checkOrigin(c.origins, c.origin); got != c.want {
+			t.Fatalf("checkOrigin(%v, %q)=%v, want %v", c.origins, c.origin, got, c.want)
+		}
+	}
+}
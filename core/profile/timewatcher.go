@@ -5,7 +5,7 @@ import "time"
 type TimeWatcher struct {
 	name       string    //模块名称
 	elementype int       //类型
-	tStart     time.Time //开始时间
+	tStart     time.Time //开始时间，耗时统计使用真实时间，不受框架时钟影响
 	next       *TimeWatcher
 }
 
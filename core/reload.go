@@ -0,0 +1,284 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// Reloadable 支持热加载的功能包
+// 重新加载时只处理配置有变化的功能包，先把新的配置解析到一个同类型的新对象cfg中，
+// Validate通过后再调用Reload应用，任何一步出错都保留旧的配置
+type Reloadable interface {
+	Package
+	Validate(cfg Package) error
+	Reload(cfg Package) error
+}
+
+// ConfigSubscriber 配置变化的通知，pkg为应用了新配置的功能包
+type ConfigSubscriber func(pkg Package)
+
+type configSubscription struct {
+	obj *basic.Object
+	f   ConfigSubscriber
+}
+
+var (
+	reloadLock sync.Mutex
+	//每个功能包最后一次应用的配置，用来判断是否有变化
+	appliedSettings = make(map[string]interface{})
+	subscribers     = make(map[string][]configSubscription)
+	watchQuit       chan struct{}
+)
+
+// SubscribeConfig 订阅功能包的配置变化，通知作为命令投递到obj中执行，obj为空时在重新加载的协程中直接执行
+func SubscribeConfig(name string, obj *basic.Object, f ConfigSubscriber) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	subscribers[name] = append(subscribers[name], configSubscription{obj: obj, f: f})
+}
+
+// notifySubscribers 在释放reloadLock之后调用，订阅者中可以再访问配置
+func notifySubscribers(pkg Package, subs []configSubscription) {
+	for _, sub := range subs {
+		f := sub.f
+		if sub.obj == nil {
+			f(pkg)
+			continue
+		}
+		sub.obj.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+			defer o.ProcessSeqnum()
+			f(pkg)
+			return nil
+		}), true)
+	}
+}
+
+// reloadNotify 重新加载成功的功能包和当时的订阅者
+type reloadNotify struct {
+	pkg  Package
+	subs []configSubscription
+}
+
+// ReloadPackages 按ConfigSource重新合并配置，把有变化的配置应用到支持热加载的功能包
+// 返回重新加载的功能包名字，部分功能包失败时返回的错误包含所有失败的原因
+// Validate和Reload在调用者的协程中执行，需要在core object中调用，文件监视触发的重新加载会投递到core object
+func ReloadPackages() ([]string, error) {
+	reloaded, notifies, err := reloadPackages()
+	for _, n := range notifies {
+		notifySubscribers(n.pkg, n.subs)
+	}
+	return reloaded, err
+}
+
+func reloadPackages() ([]string, []reloadNotify, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	if !configLoaded {
+		return nil, nil, errors.New("no config loaded")
+	}
+	ConfigSource.Defaults = packageDefaults()
+	vp, err := ConfigSource.Load()
+	if err != nil {
+		return nil, nil, err
+	}
+	effectiveSettings = vp.AllSettings()
+
+	keys := make([]string, 0, len(packages))
+	for k := range vp.AllSettings() {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var reloaded []string
+	var notifies []reloadNotify
+	var errs []string
+	for _, k := range keys {
+		pkg, exist := packages[k]
+		if !exist || !IsPackageLoaded(k) {
+			continue
+		}
+		settings := vp.Get(k)
+		if reflect.DeepEqual(appliedSettings[k], settings) {
+			continue
+		}
+		diff := diffSettings(k, appliedSettings[k], settings)
+		rp, ok := pkg.(Reloadable)
+		if !ok {
+			logger.Logger.Warnf("package [%v] config changed but not reloadable, restart to apply: %v", k, strings.Join(diff, ", "))
+			continue
+		}
+		if err = reloadPackage(vp, rp); err != nil {
+			logger.Logger.Errorf("package [%v] reload failed, keep old config: %v", k, err)
+			errs = append(errs, fmt.Sprintf("%v: %v", k, err))
+			continue
+		}
+		appliedSettings[k] = settings
+		reloaded = append(reloaded, k)
+		logger.Logger.Infof("package [%v] reloaded: %v", k, strings.Join(diff, ", "))
+		notifies = append(notifies, reloadNotify{pkg: rp, subs: append([]configSubscription(nil), subscribers[k]...)})
+	}
+	if len(errs) > 0 {
+		return reloaded, notifies, errors.New(strings.Join(errs, "; "))
+	}
+	return reloaded, notifies, nil
+}
+
+// reloadPackage 解析到新对象，校验后应用
+func reloadPackage(vp *viper.Viper, rp Reloadable) error {
+	t := reflect.TypeOf(rp)
+	if t.Kind() != reflect.Ptr {
+		return fmt.Errorf("package type %v is not a pointer", t)
+	}
+	cfg, ok := reflect.New(t.Elem()).Interface().(Package)
+	if !ok {
+		return fmt.Errorf("package type %v can not be created", t)
+	}
+	if err := vp.UnmarshalKey(rp.Name(), cfg); err != nil {
+		return err
+	}
+	if err := rp.Validate(cfg); err != nil {
+		return err
+	}
+	return rp.Reload(cfg)
+}
+
+// diffSettings 列出两份配置不同的项，形如 name.key: old -> new
+func diffSettings(prefix string, old, new interface{}) []string {
+	om, ok1 := old.(map[string]interface{})
+	nm, ok2 := new.(map[string]interface{})
+	if !ok1 || !ok2 {
+		if reflect.DeepEqual(old, new) {
+			return nil
+		}
+		return []string{fmt.Sprintf("%v: %v -> %v", prefix, old, new)}
+	}
+	keys := make(map[string]struct{})
+	for k := range om {
+		keys[k] = struct{}{}
+	}
+	for k := range nm {
+		keys[k] = struct{}{}
+	}
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var diff []string
+	for _, k := range names {
+		diff = append(diff, diffSettings(prefix+"."+k, om[k], nm[k])...)
+	}
+	return diff
+}
+
+// WatchConfig 监视基础配置文件和环境覆盖文件，文件变化时自动重新加载
+func WatchConfig() error {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	if watchQuit != nil {
+		return nil
+	}
+	if !configLoaded {
+		return errors.New("no config loaded")
+	}
+	files := make(map[string]bool)
+	for _, file := range ConfigSource.Files() {
+		file, _ = filepath.Abs(file)
+		files[file] = true
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("config file %v.%v not found", ConfigSource.Name, ConfigSource.Type)
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	//监视目录，编辑器保存时可能先删除再重建文件
+	for file := range files {
+		if err = w.Add(filepath.Dir(file)); err != nil {
+			w.Close()
+			return err
+		}
+		logger.Logger.Infof("watching config file %v", file)
+	}
+	watchQuit = make(chan struct{})
+	go watchRoutine(w, files, watchQuit)
+	return nil
+}
+
+// StopWatchConfig 停止监视配置文件
+func StopWatchConfig() {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	if watchQuit != nil {
+		close(watchQuit)
+		watchQuit = nil
+	}
+}
+
+// configWatchDelay 文件变化后等待一段时间再加载，合并连续的写入
+var configWatchDelay = 200 * time.Millisecond
+
+func watchRoutine(w *fsnotify.Watcher, files map[string]bool, quit chan struct{}) {
+	defer w.Close()
+	var delay <-chan time.Time
+	for {
+		select {
+		case <-quit:
+			return
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if !files[filepath.Clean(ev.Name)] || !ev.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				continue
+			}
+			delay = time.After(configWatchDelay)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			logger.Logger.Warn("config watcher error ", err)
+		case <-delay:
+			delay = nil
+			reloadOnCore()
+		}
+	}
+}
+
+// reloadOnCore 在core object中重新加载，core object还没有创建时直接执行
+func reloadOnCore() {
+	reload := func() {
+		if _, err := ReloadPackages(); err != nil {
+			logger.Logger.Error("reload config error ", err)
+		}
+	}
+	obj := CoreObject()
+	if obj == nil {
+		reload()
+		return
+	}
+	obj.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		reload()
+		return nil
+	}), true)
+}
+
+func init() {
+	RegisteHook(HOOK_CONFIG_RELOAD, func() error {
+		_, err := ReloadPackages()
+		return err
+	})
+}
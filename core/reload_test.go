@@ -0,0 +1,140 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/basic"
+)
+
+type reloadTestPackage struct {
+	Value int
+	Label string
+}
+
+func (p *reloadTestPackage) Init() error  { return nil }
+func (p *reloadTestPackage) Close() error { return nil }
+
+func (p *reloadTestPackage) Name() string {
+	return "reloadtest"
+}
+
+func (p *reloadTestPackage) Validate(cfg Package) error {
+	if cfg.(*reloadTestPackage).Value < 0 {
+		return errors.New("negative value")
+	}
+	return nil
+}
+
+func (p *reloadTestPackage) Reload(cfg Package) error {
+	*p = *cfg.(*reloadTestPackage)
+	return nil
+}
+
+func writeReloadTestConfig(t *testing.T, value int) {
+	body := fmt.Sprintf(`{"reloadtest": {"Value": %d, "Label": "a"}}`, value)
+	if err := os.WriteFile("reloadtest.json", []byte(body), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadPackages(t *testing.T) {
+	t.Chdir(t.TempDir())
+	pkg := &reloadTestPackage{}
+	RegistePackage(pkg)
+	defer delete(packages, pkg.Name())
+	writeReloadTestConfig(t, 1)
+	LoadPackages("reloadtest.json")
+	if pkg.Value != 1 {
+		t.Fatalf("load value=%v", pkg.Value)
+	}
+
+	obj := basic.NewObject(ObjId_CoreId, "reload-test", basic.Options{MaxDone: 16, QueueBacklog: 16}, nil)
+	obj.Active()
+	notified := make(chan int, 4)
+	SubscribeConfig(pkg.Name(), obj, func(p Package) {
+		notified <- p.(*reloadTestPackage).Value
+	})
+	defer delete(subscribers, pkg.Name())
+
+	if names, err := ReloadPackages(); err != nil || len(names) != 0 {
+		t.Fatalf("unchanged reload names=%v err=%v", names, err)
+	}
+
+	writeReloadTestConfig(t, 2)
+	if names, err := ReloadPackages(); err != nil || len(names) != 1 || names[0] != pkg.Name() {
+		t.Fatalf("reload names=%v err=%v", names, err)
+	}
+	select {
+	case v := <-notified:
+		if v != 2 {
+			t.Fatalf("notified value=%v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscriber not notified")
+	}
+
+	writeReloadTestConfig(t, -1)
+	if _, err := ReloadPackages(); err == nil || !strings.Contains(err.Error(), "negative value") {
+		t.Fatalf("invalid config accepted, err=%v", err)
+	}
+	if pkg.Value != 2 {
+		t.Fatalf("old config not kept, value=%v", pkg.Value)
+	}
+
+	//订阅者在释放reloadLock之后调用，可以再访问配置
+	effective := make(chan map[string]interface{}, 4)
+	SubscribeConfig(pkg.Name(), nil, func(p Package) {
+		effective <- EffectiveConfig()
+	})
+	writeReloadTestConfig(t, 4)
+	done := make(chan error, 1)
+	go func() {
+		_, err := ReloadPackages()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscriber without object deadlocked on reloadLock")
+	}
+	if cfg := <-effective; cfg["reloadtest"] == nil {
+		t.Fatalf("effective config in subscriber=%v", cfg)
+	}
+	<-notified
+
+	//文件变化触发的重新加载在core object中执行
+	old := AppCtx.CoreObj
+	AppCtx.CoreObj = obj
+	defer func() { AppCtx.CoreObj = old }()
+	configWatchDelay = 10 * time.Millisecond
+	if err := WatchConfig(); err != nil {
+		t.Fatal(err)
+	}
+	defer StopWatchConfig()
+	writeReloadTestConfig(t, 3)
+	select {
+	case v := <-notified:
+		if v != 3 {
+			t.Fatalf("watch notified value=%v", v)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("config watcher not reloaded")
+	}
+}
+
+func TestDiffSettings(t *testing.T) {
+	old := map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": "x", "d": true}}
+	now := map[string]interface{}{"a": 1, "b": map[string]interface{}{"c": "y"}, "e": 2}
+	diff := strings.Join(diffSettings("p", old, now), ", ")
+	if diff != "p.b.c: x -> y, p.b.d: true -> <nil>, p.e: <nil> -> 2" {
+		t.Fatalf("diff=%v", diff)
+	}
+}
@@ -0,0 +1,271 @@
+package signal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/acoderup/goserver.v1/core/module"
+)
+
+// 内置的信号动作
+const (
+	//优雅退出，超过StopDeadline仍未退出时输出堆栈后强制退出
+	Action_Stop = "stop"
+	//执行HOOK_CONFIG_RELOAD重新加载配置
+	Action_Reload = "reload"
+	//重新加载日志配置，重新打开日志文件
+	Action_LogReopen = "logreopen"
+	//把所有协程的堆栈写到DumpDir中的文件
+	Action_Dump = "dump"
+	//在debug日志级别和原来的级别之间切换
+	Action_DebugLog = "debuglog"
+	//忽略信号
+	Action_Ignore = "ignore"
+)
+
+// defaultActions 没有配置Actions时使用，当前平台不支持的信号会跳过
+var defaultActions = map[string]string{
+	"SIGINT":  Action_Stop,
+	"SIGTERM": Action_Stop,
+	"SIGHUP":  Action_Reload,
+}
+
+// ActionHandler 命名的信号动作，可以在配置中通过名字绑定到信号
+// inPlace在信号协程中直接执行，f投递到Executor中执行，两者都可以为空
+type ActionHandler struct {
+	Name    string
+	inPlace func(s os.Signal) error
+	f       func(s os.Signal) error
+}
+
+func (this *ActionHandler) ProcessInPlace(s os.Signal, ud interface{}) error {
+	logger.Logger.Warnf("Receive signal %v, do action %v", s, this.Name)
+	if this.inPlace == nil {
+		return nil
+	}
+	return this.inPlace(s)
+}
+
+func (this *ActionHandler) Process(s os.Signal, ud interface{}) error {
+	if this.f == nil {
+		return nil
+	}
+	return this.f(s)
+}
+
+func (this *ActionHandler) needDispatch() bool {
+	return this.f != nil
+}
+
+var (
+	actionLock sync.RWMutex
+	actions    = make(map[string]*ActionHandler)
+)
+
+// RegisteAction 注册信号动作，同名的动作会被替换，动作投递到Executor中执行
+func RegisteAction(name string, f func(s os.Signal) error) {
+	registeAction(&ActionHandler{Name: name, f: f})
+}
+
+// RegisteInPlaceAction 注册在信号协程中直接执行的动作，Executor卡住时也能执行
+// 动作不能访问只属于某个对象的状态
+func RegisteInPlaceAction(name string, f func(s os.Signal) error) {
+	registeAction(&ActionHandler{Name: name, inPlace: f})
+}
+
+func registeAction(ah *ActionHandler) {
+	actionLock.Lock()
+	defer actionLock.Unlock()
+	actions[strings.ToLower(ah.Name)] = ah
+}
+
+func GetAction(name string) *ActionHandler {
+	actionLock.RLock()
+	defer actionLock.RUnlock()
+	return actions[strings.ToLower(name)]
+}
+
+// validateActions 检查信号和动作都能识别
+func validateActions(mapping map[string]string) error {
+	for name, action := range mapping {
+		if _, err := ParseSignal(name); err != nil {
+			return err
+		}
+		if GetAction(action) == nil {
+			return fmt.Errorf("signal %v bind to unknown action %v", name, action)
+		}
+	}
+	return nil
+}
+
+// applyActions 按配置把信号绑定到动作，替换这些信号上原有的处理器
+// 上一次绑定而这次配置中没有的信号，解除绑定的动作
+func (this *SignalHandler) applyActions(mapping map[string]string, strict bool) error {
+	if strict {
+		if err := validateActions(mapping); err != nil {
+			return err
+		}
+	}
+	names := make([]string, 0, len(mapping))
+	for name := range mapping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	bound := make(map[os.Signal]Handler)
+	for _, name := range names {
+		s, err := ParseSignal(name)
+		if err != nil {
+			if strict {
+				return err
+			}
+			continue
+		}
+		action := GetAction(mapping[name])
+		if action == nil {
+			return fmt.Errorf("signal %v bind to unknown action %v", name, mapping[name])
+		}
+		this.ClearHandler(s)
+		if err = this.RegisteHandler(s, action, nil); err != nil {
+			return err
+		}
+		bound[s] = action
+		logger.Logger.Tracef("signal %v -> %v", name, action.Name)
+	}
+	this.lock.Lock()
+	prev := this.bound
+	this.bound = bound
+	this.lock.Unlock()
+	for s, h := range prev {
+		if _, exist := bound[s]; !exist {
+			this.UnregisteHandler(s, h)
+			logger.Logger.Tracef("signal %v unbind %v", s, h.(*ActionHandler).Name)
+		}
+	}
+	return nil
+}
+
+var (
+	stopOnce     sync.Once
+	deadlineOnce sync.Once
+)
+
+// armStopDeadline 在信号协程中设置强制退出的定时器，Executor卡住时仍然会强制退出
+func armStopDeadline(s os.Signal) error {
+	deadlineOnce.Do(func() {
+		if deadline := currentConfig().StopDeadline; deadline > 0 {
+			time.AfterFunc(deadline, func() {
+				forceExit(fmt.Sprintf("graceful stop exceed %v", deadline))
+			})
+		}
+	})
+	return nil
+}
+
+func actionStop(s os.Signal) error {
+	stopOnce.Do(module.Stop)
+	return nil
+}
+
+func actionReload(s os.Signal) error {
+	if err := core.ExecuteHook(core.HOOK_CONFIG_RELOAD); err != nil {
+		logger.Logger.Error("ExecuteHook(HOOK_CONFIG_RELOAD) error ", err)
+		return err
+	}
+	return nil
+}
+
+func actionLogReopen(s os.Signal) error {
+	file := currentConfig().LoggerFile
+	if file == "" {
+		return nil
+	}
+	if err := logger.Reload(file); err != nil {
+		logger.Logger.Errorf("reload logger %v error %v", file, err)
+		return err
+	}
+	return nil
+}
+
+func actionDump(s os.Signal) error {
+	file, err := DumpStacks()
+	if err != nil {
+		logger.Logger.Error("dump stacks error ", err)
+		return err
+	}
+	logger.Logger.Warnf("stacks dumped to %v", file)
+	return nil
+}
+
+var (
+	debugLock        sync.Mutex
+	levelBeforeDebug string
+)
+
+func actionDebugLog(s os.Signal) error {
+	debugLock.Lock()
+	defer debugLock.Unlock()
+	level := currentConfig().DebugLevel
+	if levelBeforeDebug == "" {
+		levelBeforeDebug = logger.GetLevel()
+		logger.Logger.Warnf("log level %v -> %v", levelBeforeDebug, level)
+		return logger.SetLevel(level)
+	}
+	old := levelBeforeDebug
+	levelBeforeDebug = ""
+	logger.Logger.Warnf("log level %v -> %v", level, old)
+	return logger.SetLevel(old)
+}
+
+// osExit 测试时替换
+var osExit = os.Exit
+
+// forceExit 输出堆栈后立即退出进程
+func forceExit(reason string) {
+	file, err := DumpStacks()
+	if err != nil {
+		logger.Logger.Errorf("%v, force exit, dump stacks error %v", reason, err)
+	} else {
+		logger.Logger.Errorf("%v, force exit, stacks dumped to %v", reason, file)
+	}
+	logger.Logger.Flush()
+	osExit(1)
+}
+
+// DumpStacks 把所有协程的堆栈写到DumpDir中，返回文件名
+func DumpStacks() (string, error) {
+	dir := currentConfig().DumpDir
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	file := filepath.Join(dir, fmt.Sprintf("stack-%v-%v.txt", os.Getpid(), time.Now().Format("20060102150405.000")))
+	f, err := os.Create(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if err = pprof.Lookup("goroutine").WriteTo(f, 2); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+func init() {
+	registeAction(&ActionHandler{Name: Action_Stop, inPlace: armStopDeadline, f: actionStop})
+	RegisteAction(Action_Reload, actionReload)
+	//排查问题的动作不依赖对象状态，在信号协程中执行，core卡住时也能生效
+	RegisteInPlaceAction(Action_LogReopen, actionLogReopen)
+	RegisteInPlaceAction(Action_Dump, actionDump)
+	RegisteInPlaceAction(Action_DebugLog, actionDebugLog)
+	RegisteInPlaceAction(Action_Ignore, func(s os.Signal) error { return nil })
+}
@@ -1,13 +1,42 @@
 package signal
 
 import (
+	"errors"
+	"sync"
+	"time"
+
 	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/logger"
+)
+
+var (
+	Config = Configuration{}
+	//Reload在core object中替换Config，信号协程和定时器协程通过currentConfig读取
+	configLock sync.RWMutex
 )
 
-var Config = Configuration{}
+// currentConfig 当前配置的副本
+func currentConfig() Configuration {
+	configLock.RLock()
+	defer configLock.RUnlock()
+	return Config
+}
 
 type Configuration struct {
 	SupportSignal bool
+	//信号到动作的映射，例如 {"SIGTERM": "stop", "SIGHUP": "reload", "SIGUSR1": "logreopen", "SIGUSR2": "debuglog", "SIGQUIT": "dump"}
+	//为空时使用默认的映射：SIGINT、SIGTERM优雅退出，SIGHUP重新加载配置
+	Actions map[string]string
+	//优雅退出的期限(毫秒)，0表示不限制
+	StopDeadline time.Duration
+	//堆栈文件的目录
+	DumpDir string
+	//logreopen重新加载的日志配置文件
+	LoggerFile string
+	//debuglog切换到的日志级别
+	DebugLevel string
+	//在这个时间(毫秒)内连续收到两次SIGINT时，输出堆栈后立即退出，0表示不启用
+	ForceExitWindow time.Duration
 }
 
 func (c *Configuration) Name() string {
@@ -15,13 +44,71 @@ func (c *Configuration) Name() string {
 }
 
 func (c *Configuration) Init() error {
+	c.normalize()
 	if c.SupportSignal {
+		if err := c.applyActions(); err != nil {
+			return err
+		}
 		//demon goroutine
 		go SignalHandlerModule.ProcessSignal()
 	}
 	return nil
 }
 
+// normalize 转换时间单位，填充默认值
+func (c *Configuration) normalize() {
+	if c.StopDeadline > 0 {
+		c.StopDeadline = time.Millisecond * c.StopDeadline
+	}
+	if c.ForceExitWindow > 0 {
+		c.ForceExitWindow = time.Millisecond * c.ForceExitWindow
+	}
+	if c.LoggerFile == "" {
+		c.LoggerFile = "logger.xml"
+	}
+	if c.DebugLevel == "" {
+		c.DebugLevel = "debug"
+	}
+}
+
+func (c *Configuration) applyActions() error {
+	var err error
+	if len(c.Actions) > 0 {
+		err = SignalHandlerModule.applyActions(c.Actions, true)
+	} else {
+		err = SignalHandlerModule.applyActions(defaultActions, false)
+	}
+	if err != nil {
+		logger.Logger.Error("signal actions config error ", err)
+		return err
+	}
+	return nil
+}
+
+// Validate 热加载前校验，信号映射必须都能识别，SupportSignal不能热加载
+func (c *Configuration) Validate(cfg core.Package) error {
+	nc := cfg.(*Configuration)
+	if nc.SupportSignal != c.SupportSignal {
+		return errors.New("SupportSignal can not be changed by reload")
+	}
+	return validateActions(nc.Actions)
+}
+
+// Reload 热加载，重新绑定信号动作
+func (c *Configuration) Reload(cfg core.Package) error {
+	nc := cfg.(*Configuration)
+	nc.normalize()
+	if nc.SupportSignal {
+		if err := nc.applyActions(); err != nil {
+			return err
+		}
+	}
+	configLock.Lock()
+	*c = *nc
+	configLock.Unlock()
+	return nil
+}
+
 func (c *Configuration) Close() error {
 	return nil
 }
@@ -7,6 +7,9 @@ import (
 	"github.com/acoderup/goserver.v1/core/module"
 )
 
+// KillSignalHandler 收到os.Kill时关闭进程
+//
+// Deprecated: os.Kill无法被捕获，该处理器不会被调用，也不再默认注册，保留只为兼容已有的引用
 type KillSignalHandler struct {
 }
 
@@ -15,7 +18,3 @@ func (ish *KillSignalHandler) Process(s os.Signal, ud interface{}) error {
 	module.Stop()
 	return nil
 }
-
-func init() {
-	SignalHandlerModule.RegisteHandler(os.Kill, &KillSignalHandler{}, nil)
-}
@@ -8,7 +8,10 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"time"
 
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/basic"
 	"github.com/acoderup/goserver.v1/core/logger"
 )
 
@@ -18,10 +21,22 @@ type Handler interface {
 	Process(s os.Signal, ud interface{}) error
 }
 
+// InPlaceHandler 处理器中可以在信号协程中直接执行的部分，在投递Process之前调用
+type InPlaceHandler interface {
+	ProcessInPlace(s os.Signal, ud interface{}) error
+}
+
 type SignalHandler struct {
 	lock sync.RWMutex
 	sc   chan os.Signal
 	mh   map[os.Signal]map[Handler]interface{}
+	//处理器作为命令投递到Executor中执行，Executor为空时使用core对象
+	//core对象还没有创建时在信号协程中直接执行，InPlaceHandler的部分总是在信号协程中执行
+	Executor *basic.Object
+	//上一次收到SIGINT的时间，用于连续两次SIGINT强制退出
+	lastInterrupt time.Time
+	//applyActions绑定的动作，重新绑定时解除配置中已经去掉的信号
+	bound map[os.Signal]Handler
 }
 
 func NewSignalHandler() *SignalHandler {
@@ -94,13 +109,63 @@ func (this *SignalHandler) ProcessSignal() {
 				}
 			}
 			this.lock.RUnlock()
+			if s == os.Interrupt && this.escalate(time.Now()) {
+				forceExit(fmt.Sprintf("receive %v twice in %v", s, currentConfig().ForceExitWindow))
+				continue
+			}
 			if ok && len(handlers) > 0 {
-				for hk, hv := range handlers {
-					utils.CatchPanic(func() { hk.Process(s, hv) })
-				}
+				this.dispatch(s, handlers)
 				//} else {
 				//	logger.Logger.Warn("-------->UnHandle Signal:", s)
 			}
 		}
 	}
 }
+
+// escalate 在ForceExitWindow内第二次收到SIGINT时返回true
+func (this *SignalHandler) escalate(now time.Time) bool {
+	window := currentConfig().ForceExitWindow
+	if window <= 0 {
+		return false
+	}
+	last := this.lastInterrupt
+	this.lastInterrupt = now
+	return !last.IsZero() && now.Sub(last) <= window
+}
+
+func (this *SignalHandler) executor() *basic.Object {
+	if this.Executor != nil {
+		return this.Executor
+	}
+	return core.CoreObject()
+}
+
+// dispatch 调用处理器，投递到Executor的协程中执行，避免处理器和对象内的逻辑并发
+// InPlaceHandler的部分先在信号协程中执行，不需要投递的动作不再进入Executor
+func (this *SignalHandler) dispatch(s os.Signal, handlers map[Handler]interface{}) {
+	for hk, hv := range handlers {
+		if ih, ok := hk.(InPlaceHandler); ok {
+			utils.CatchPanic(func() { ih.ProcessInPlace(s, hv) })
+		}
+		if ah, ok := hk.(*ActionHandler); ok && !ah.needDispatch() {
+			delete(handlers, hk)
+		}
+	}
+	if len(handlers) == 0 {
+		return
+	}
+	obj := this.executor()
+	if obj == nil {
+		for hk, hv := range handlers {
+			utils.CatchPanic(func() { hk.Process(s, hv) })
+		}
+		return
+	}
+	obj.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		for hk, hv := range handlers {
+			utils.CatchPanic(func() { hk.Process(s, hv) })
+		}
+		return nil
+	}), true)
+}
@@ -0,0 +1,24 @@
+package signal
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// signalNames 配置中可以使用的信号名，不同平台支持的信号不同，见signal_names_*.go
+var signalNames = map[string]os.Signal{
+	"SIGINT": os.Interrupt,
+}
+
+// ParseSignal 解析信号名，大小写和SIG前缀都可以省略，例如 SIGHUP、hup
+func ParseSignal(name string) (os.Signal, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if s, exist := signalNames[name]; exist {
+		return s, nil
+	}
+	return nil, fmt.Errorf("signal %v not supported on this platform", name)
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package signal
+
+import (
+	"syscall"
+)
+
+func init() {
+	signalNames["SIGHUP"] = syscall.SIGHUP
+	signalNames["SIGTERM"] = syscall.SIGTERM
+	signalNames["SIGQUIT"] = syscall.SIGQUIT
+	signalNames["SIGUSR1"] = syscall.SIGUSR1
+	signalNames["SIGUSR2"] = syscall.SIGUSR2
+}
@@ -0,0 +1,9 @@
+package signal
+
+import (
+	"syscall"
+)
+
+func init() {
+	signalNames["SIGTERM"] = syscall.SIGTERM
+}
@@ -0,0 +1,246 @@
+package signal
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/logger"
+)
+
+// stopHandler 先停止接收系统信号再关闭，否则运行时的SIGURG等信号会写入已经关闭的channel
+func stopHandler(sh *SignalHandler) {
+	signal.Stop(sh.sc)
+	close(sh.sc)
+}
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"SIGINT", "int", " Sigint "} {
+		if s, err := ParseSignal(name); err != nil || s != os.Interrupt {
+			t.Fatalf("parse %q = %v, %v", name, s, err)
+		}
+	}
+	if _, err := ParseSignal("SIGNOPE"); err == nil {
+		t.Fatal("unknown signal parsed")
+	}
+}
+
+func TestApplyActions(t *testing.T) {
+	got := make(chan os.Signal, 1)
+	RegisteAction("test", func(s os.Signal) error {
+		got <- s
+		return nil
+	})
+	sh := NewSignalHandler()
+	if err := sh.applyActions(map[string]string{"SIGINT": "nosuchaction"}, true); err == nil {
+		t.Fatal("unknown action accepted")
+	}
+	if err := sh.applyActions(map[string]string{"SIGNOPE": "test"}, true); err == nil {
+		t.Fatal("unknown signal accepted in strict mode")
+	}
+	if err := sh.applyActions(map[string]string{"SIGNOPE": "test", "int": "test"}, false); err != nil {
+		t.Fatal(err)
+	}
+	go sh.ProcessSignal()
+	defer stopHandler(sh)
+	sh.sc <- os.Interrupt
+	select {
+	case s := <-got:
+		if s != os.Interrupt {
+			t.Fatalf("action got signal %v", s)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("action not called")
+	}
+}
+
+func TestApplyActionsUnbind(t *testing.T) {
+	sh := NewSignalHandler()
+	defer stopHandler(sh)
+	if err := sh.applyActions(map[string]string{"SIGINT": Action_Stop, "SIGNOPE": Action_Dump}, false); err != nil {
+		t.Fatal(err)
+	}
+	other := &InterruptSignalHandler{}
+	sh.RegisteHandler(os.Interrupt, other, nil)
+	if err := sh.applyActions(map[string]string{"SIGNOPE": Action_Dump}, false); err != nil {
+		t.Fatal(err)
+	}
+	//只解除上一次绑定的动作，其它处理器保留
+	sh.lock.RLock()
+	handlers := sh.mh[os.Interrupt]
+	sh.lock.RUnlock()
+	if _, exist := handlers[GetAction(Action_Stop)]; exist || len(handlers) != 1 {
+		t.Fatalf("SIGINT handlers after rebind=%v", handlers)
+	}
+}
+
+func TestDebugLogAndDump(t *testing.T) {
+	old := logger.GetLevel()
+	Config.DebugLevel = "error"
+	defer func() { Config.DebugLevel = "" }()
+	actionDebugLog(os.Interrupt)
+	if lv := logger.GetLevel(); lv != "error" {
+		t.Fatalf("debuglog level=%v", lv)
+	}
+	actionDebugLog(os.Interrupt)
+	if lv := logger.GetLevel(); lv != old {
+		t.Fatalf("debuglog not restored, level=%v", lv)
+	}
+
+	Config.DumpDir = t.TempDir()
+	defer func() { Config.DumpDir = "" }()
+	file, err := DumpStacks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(file); err != nil || fi.Size() == 0 {
+		t.Fatalf("dump file %v stat=%v err=%v", file, fi, err)
+	}
+}
+
+type chanHandler chan os.Signal
+
+func (h chanHandler) Process(s os.Signal, ud interface{}) error {
+	h <- s
+	return nil
+}
+
+func TestDispatchToExecutor(t *testing.T) {
+	obj := basic.NewObject(core.ObjId_CoreId, "signal-test", basic.Options{MaxDone: 16, QueueBacklog: 16}, nil)
+	obj.Active()
+	//默认投递到core对象，core对象不存在时才在信号协程中执行
+	old := core.AppCtx.CoreObj
+	defer func() { core.AppCtx.CoreObj = old }()
+	for _, useCore := range []bool{false, true} {
+		sh := NewSignalHandler()
+		if useCore {
+			core.AppCtx.CoreObj = obj
+		} else {
+			sh.Executor = obj
+		}
+		got := make(chanHandler, 1)
+		sh.RegisteHandler(os.Interrupt, got, nil)
+		go sh.ProcessSignal()
+
+		//executor忙时处理器不会在信号协程中执行
+		release := make(chan struct{})
+		obj.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+			defer o.ProcessSeqnum()
+			<-release
+			return nil
+		}), true)
+		sh.sc <- os.Interrupt
+		select {
+		case <-got:
+			t.Fatalf("useCore=%v handler run outside executor", useCore)
+		case <-time.After(50 * time.Millisecond):
+		}
+		close(release)
+		select {
+		case <-got:
+		case <-time.After(time.Second):
+			t.Fatalf("useCore=%v handler not dispatched", useCore)
+		}
+		stopHandler(sh)
+	}
+}
+
+func TestDoubleInterruptForceExit(t *testing.T) {
+	exited := make(chan int, 1)
+	osExit = func(code int) { exited <- code }
+	defer func() { osExit = os.Exit }()
+	Config.ForceExitWindow = time.Second
+	Config.DumpDir = t.TempDir()
+	defer func() { Config.ForceExitWindow, Config.DumpDir = 0, "" }()
+
+	sh := NewSignalHandler()
+	got := make(chanHandler, 2)
+	sh.RegisteHandler(os.Interrupt, got, nil)
+	go sh.ProcessSignal()
+	defer stopHandler(sh)
+
+	sh.sc <- os.Interrupt
+	<-got
+	sh.sc <- os.Interrupt
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Fatalf("exit code=%v", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second interrupt not escalated")
+	}
+	if len(got) != 0 {
+		t.Fatal("handler called on escalated interrupt")
+	}
+}
+
+func TestReloadWhileSignalling(t *testing.T) {
+	saved := Config
+	defer func() { Config = saved }()
+	sh := NewSignalHandler()
+	defer stopHandler(sh)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			sh.escalate(time.Now())
+		}
+	}()
+	//热加载和信号协程读取配置并发
+	for i := 0; i < 100; i++ {
+		if err := Config.Reload(&Configuration{ForceExitWindow: time.Duration(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
+
+func TestInPlaceActionsWhenExecutorBlocked(t *testing.T) {
+	exited := make(chan int, 1)
+	osExit = func(code int) { exited <- code }
+	defer func() { osExit = os.Exit }()
+	dir := t.TempDir()
+	Config.StopDeadline = 200 * time.Millisecond
+	Config.DumpDir = dir
+	defer func() { Config.StopDeadline, Config.DumpDir = 0, "" }()
+	deadlineOnce = sync.Once{}
+
+	obj := basic.NewObject(core.ObjId_CoreId, "signal-blocked", basic.Options{MaxDone: 16, QueueBacklog: 16}, nil)
+	obj.Active()
+	//模拟卡住的core对象
+	obj.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		select {}
+	}), true)
+	sh := NewSignalHandler()
+	sh.Executor = obj
+	sh.RegisteHandler(os.Interrupt, GetAction(Action_Dump), nil)
+	sh.RegisteHandler(os.Interrupt, GetAction(Action_Stop), nil)
+	go sh.ProcessSignal()
+	defer stopHandler(sh)
+
+	sh.sc <- os.Interrupt
+	deadline := time.Now().Add(150 * time.Millisecond)
+	for {
+		if files, _ := os.ReadDir(dir); len(files) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("dump not done while executor blocked")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case code := <-exited:
+		if code != 1 {
+			t.Fatalf("exit code=%v", code)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stop deadline not armed while executor blocked")
+	}
+}
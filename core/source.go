@@ -0,0 +1,86 @@
+package core
+
+import (
+	"encoding/json"
+	"flag"
+	"strings"
+
+	"github.com/acoderup/goserver.v1/core/viperx"
+)
+
+// ConfigSource 功能包的分层配置来源，优先级见viperx.Source
+// 在LoadPackages之前设置Envs、Sets，或者用RegisteConfigFlags从命令行读取
+var ConfigSource = &viperx.Source{EnvPrefix: "GOSERVER"}
+
+// Defaulter 提供内置默认配置的功能包，默认配置的优先级最低
+// 有默认配置的功能包即使配置文件中没有它的配置也会加载
+type Defaulter interface {
+	Defaults() map[string]interface{}
+}
+
+var (
+	//最后一次加载的完整配置
+	effectiveSettings map[string]interface{}
+	configLoaded      bool
+)
+
+func packageDefaults() map[string]interface{} {
+	defaults := make(map[string]interface{})
+	for name, pkg := range packages {
+		if d, ok := pkg.(Defaulter); ok {
+			defaults[name] = d.Defaults()
+		}
+	}
+	return defaults
+}
+
+// EffectiveConfig 最后一次加载的合并后的配置，敏感配置已隐藏
+func EffectiveConfig() map[string]interface{} {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+	return viperx.Redact(effectiveSettings)
+}
+
+// DumpConfig 合并后的配置，json格式，敏感配置已隐藏
+func DumpConfig() string {
+	buf, err := json.MarshalIndent(EffectiveConfig(), "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(buf)
+}
+
+// listFlag 可以重复或者逗号分隔的命令行参数
+type listFlag struct {
+	list  *[]string
+	split bool
+}
+
+func (f *listFlag) String() string {
+	if f.list == nil {
+		return ""
+	}
+	return strings.Join(*f.list, ",")
+}
+
+func (f *listFlag) Set(s string) error {
+	if !f.split {
+		*f.list = append(*f.list, s)
+		return nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f.list = append(*f.list, item)
+		}
+	}
+	return nil
+}
+
+// RegisteConfigFlags 注册配置相关的命令行参数，需要在LoadPackages之前解析
+//
+//	-config.env prod,local          环境覆盖文件
+//	-config.set signal.stopdeadline=3000  覆盖配置项，可以重复
+func RegisteConfigFlags(fs *flag.FlagSet) {
+	fs.Var(&listFlag{list: &ConfigSource.Envs, split: true}, "config.env", "config overlay environments, load <config>.<env>.<type> in order")
+	fs.Var(&listFlag{list: &ConfigSource.Sets}, "config.set", "override config item, e.g. signal.stopdeadline=3000, repeatable")
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"flag"
+	"os"
+	"testing"
+)
+
+type sourceTestPackage struct {
+	Size     int
+	Mode     string
+	Password string
+}
+
+func (p *sourceTestPackage) Name() string { return "sourcetest" }
+func (p *sourceTestPackage) Init() error  { return nil }
+func (p *sourceTestPackage) Close() error { return nil }
+
+func (p *sourceTestPackage) Defaults() map[string]interface{} {
+	return map[string]interface{}{"Size": 8, "Mode": "default", "Password": "secret"}
+}
+
+func TestLoadPackagesLayers(t *testing.T) {
+	t.Chdir(t.TempDir())
+	pkg := &sourceTestPackage{}
+	RegistePackage(pkg)
+	defer delete(packages, pkg.Name())
+	old := *ConfigSource
+	defer func() { *ConfigSource = old }()
+
+	//配置文件中没有sourcetest，使用默认配置加载
+	if err := os.WriteFile("layers.json", []byte(`{"core": {"MaxProcs": 1}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("layers.dev.json", []byte(`{"sourcetest": {"Mode": "dev"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisteConfigFlags(fs)
+	if err := fs.Parse([]string{"-config.env", "dev", "-config.set", "sourcetest.size=16"}); err != nil {
+		t.Fatal(err)
+	}
+	LoadPackages("layers.json")
+	if pkg.Size != 16 || pkg.Mode != "dev" || pkg.Password != "secret" {
+		t.Fatalf("loaded config=%+v", *pkg)
+	}
+	eff := EffectiveConfig()["sourcetest"].(map[string]interface{})
+	if eff["password"] != "******" || eff["mode"] != "dev" {
+		t.Fatalf("effective config=%v", eff)
+	}
+}
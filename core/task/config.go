@@ -41,6 +41,17 @@ func (c *Configuration) Init() error {
 	return nil
 }
 
+// Defaults 默认配置，配置文件中没有executor时也会加载
+func (c *Configuration) Defaults() map[string]interface{} {
+	return map[string]interface{}{
+		"Options": map[string]interface{}{"QueueBacklog": 1024, "MaxDone": 1024},
+		"Worker": map[string]interface{}{
+			"WorkerCnt": 8,
+			"Options":   map[string]interface{}{"QueueBacklog": 1024, "MaxDone": 1024},
+		},
+	}
+}
+
 func (c *Configuration) Close() error {
 	return nil
 }
@@ -0,0 +1,38 @@
+package timer
+
+import (
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/basic"
+)
+
+type clockChangedCommand struct {
+	//时钟向后跳变的距离(负数)
+	backward time.Duration
+}
+
+func (ccc *clockChangedCommand) Done(o *basic.Object) error {
+	defer o.ProcessSeqnum()
+	if ccc.backward < 0 {
+		TimerModule.shiftTimers(ccc.backward)
+	}
+	//向前跳变才会错过周期，向后跳变已经平移过到期时间
+	TimerModule.tick(ccc.backward == 0)
+	return nil
+}
+
+// SendClockChanged 时钟跳变后立即重新计算到期的定时器，不用等到下一次心跳
+func SendClockChanged() bool {
+	if TimerModule.Object == nil {
+		return false
+	}
+	return TimerModule.SendCommand(&clockChangedCommand{}, true)
+}
+
+// sendClockBackward 时钟向后跳变，所有定时器的到期时间跟着平移，保持剩余的等待时间不变
+func sendClockBackward(d time.Duration) bool {
+	if TimerModule.Object == nil {
+		return false
+	}
+	return TimerModule.SendCommand(&clockChangedCommand{backward: d}, true)
+}
@@ -0,0 +1,67 @@
+package timer
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/basic"
+)
+
+// TimerInfo 定时器的快照
+type TimerInfo struct {
+	Handle   TimerHandle
+	Owner    string
+	Action   string
+	Interval time.Duration
+	Next     time.Time
+	//剩余的触发次数，-1表示不限
+	Times int
+}
+
+type snapshotTimerCommand struct {
+	c chan []TimerInfo
+}
+
+func (stc *snapshotTimerCommand) Done(o *basic.Object) error {
+	defer o.ProcessSeqnum()
+
+	infos := make([]TimerInfo, 0, TimerModule.tq.Len())
+	for _, te := range TimerModule.tq.queue {
+		if te.stoped {
+			continue
+		}
+		info := TimerInfo{Handle: te.h, Action: actionName(te.ta), Interval: te.interval, Next: te.next, Times: te.times}
+		if te.sink != nil {
+			info.Owner = te.sink.GetTreeName()
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Next.Before(infos[j].Next) })
+	stc.c <- infos
+	return nil
+}
+
+func actionName(ta TimerAction) string {
+	if n, ok := ta.(interface{ Name() string }); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", ta)
+}
+
+// Snapshot 在timer object中获取所有定时器，按下次触发时间排序，timeout内没有完成返回false
+func (tm *TimerMgr) Snapshot(timeout time.Duration) ([]TimerInfo, bool) {
+	if tm.Object == nil {
+		return nil, false
+	}
+	stc := &snapshotTimerCommand{c: make(chan []TimerInfo, 1)}
+	if !tm.SendCommand(stc, true) {
+		return nil, false
+	}
+	select {
+	case infos := <-stc.c:
+		return infos, true
+	case <-time.After(timeout):
+		return nil, false
+	}
+}
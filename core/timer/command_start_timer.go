@@ -27,7 +27,7 @@ func (stc *startTimerCommand) Done(o *basic.Object) error {
 		interval: stc.interval,
 		times:    stc.times,
 		h:        stc.h,
-		next:     time.Now().Add(stc.interval),
+		next:     core.Now().Add(stc.interval),
 	}
 
 	heap.Push(TimerModule.tq, te)
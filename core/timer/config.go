@@ -33,6 +33,13 @@ func (c *Configuration) Init() error {
 	return nil
 }
 
+// Defaults 默认配置，配置文件中没有timer时也会加载
+func (c *Configuration) Defaults() map[string]interface{} {
+	return map[string]interface{}{
+		"Options": map[string]interface{}{"QueueBacklog": 1024, "MaxDone": 1024, "Interval": 10},
+	}
+}
+
 func (c *Configuration) Close() error {
 	return nil
 }
@@ -2,6 +2,7 @@ package timer
 
 import (
 	"container/heap"
+	"sync"
 	"time"
 
 	"github.com/acoderup/goserver.v1/core"
@@ -13,6 +14,7 @@ var (
 	TimerHandleGenerator uint32      = 1
 	InvalidTimerHandle   TimerHandle = 0
 	TimerModule          *TimerMgr   = NewTimerMgr()
+	clockHookOnce        sync.Once
 )
 
 type TimerMgr struct {
@@ -46,7 +48,13 @@ func (tm *TimerMgr) TimerCount() int {
 }
 
 func (tm *TimerMgr) OnTick() {
-	nowTime := time.Now()
+	tm.tick(false)
+}
+
+// tick 触发到期的定时器，skipMissed为true时(时钟向前跳变后)跳过错过的周期
+// 正常心跳延迟时仍然补发错过的周期
+func (tm *TimerMgr) tick(skipMissed bool) {
+	nowTime := core.Now()
 	for {
 		if tm.tq.Len() > 0 {
 			t := heap.Pop(tm.tq)
@@ -58,6 +66,10 @@ func (tm *TimerMgr) OnTick() {
 					//Avoid async stop timer failed
 					if te.times != 0 {
 						te.next = te.next.Add(te.interval)
+						//时钟向前跳变后，跳过错过的周期，避免同一个定时器在一次心跳内被连续触发
+						if skipMissed && !te.next.After(nowTime) {
+							te.next = nowTime.Add(te.interval)
+						}
 						heap.Push(tm.tq, te)
 					}
 					if !SendTimeout(te) {
@@ -78,6 +90,24 @@ func (tm *TimerMgr) OnTick() {
 	}
 }
 
-func (tm *TimerMgr) OnStart() {}
+func (tm *TimerMgr) OnStart() {
+	//模块可能被重启，时钟回调只注册一次
+	clockHookOnce.Do(func() {
+		core.AppClock.RegisteChangedHook(func(old, now time.Time) {
+			if now.After(old) {
+				SendClockChanged()
+			} else if now.Before(old) {
+				sendClockBackward(now.Sub(old))
+			}
+		})
+	})
+}
 
 func (tm *TimerMgr) OnStop() {}
+
+// shiftTimers 平移所有定时器的到期时间，所有定时器平移相同的距离，堆的顺序不变
+func (tm *TimerMgr) shiftTimers(d time.Duration) {
+	for _, te := range tm.tq.queue {
+		te.next = te.next.Add(d)
+	}
+}
@@ -2,8 +2,11 @@ package timer
 
 import (
 	"container/heap"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/acoderup/goserver.v1/core/basic"
 )
 
 func TestTimerQueuePush(t *testing.T) {
@@ -86,3 +89,62 @@ func BenchmarkTimerQueuePop(b *testing.B) {
 	}
 	b.StopTimer()
 }
+
+func TestShiftTimers(t *testing.T) {
+	tm := NewTimerMgr()
+	tNow := time.Now()
+	for i, d := range []time.Duration{time.Hour, time.Second, time.Minute} {
+		heap.Push(tm.tq, &TimerEntity{h: TimerHandle(i + 1), next: tNow.Add(d)})
+	}
+	tm.shiftTimers(-time.Hour)
+	te := heap.Pop(tm.tq).(*TimerEntity)
+	if !te.next.Equal(tNow.Add(time.Second - time.Hour)) {
+		t.Fatalf("first timer next=%v", te.next.Sub(tNow))
+	}
+	for tm.tq.Len() > 0 {
+		te = heap.Pop(tm.tq).(*TimerEntity)
+	}
+	if !te.next.Equal(tNow) {
+		t.Fatalf("last timer next=%v", te.next.Sub(tNow))
+	}
+}
+
+func TestTickMissedPeriods(t *testing.T) {
+	var fired int32
+	sink := basic.NewObject(1, "timer_sink", basic.Options{Interval: time.Second, MaxDone: 10}, nil)
+	sink.Active()
+	ta := TimerActionWrapper(func(h TimerHandle, ud interface{}) bool {
+		atomic.AddInt32(&fired, 1)
+		return true
+	})
+	waitFired := func(n int32) {
+		deadline := time.Now().Add(5 * time.Second)
+		for atomic.LoadInt32(&fired) < n && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(10 * time.Millisecond)
+		if got := atomic.LoadInt32(&fired); got != n {
+			t.Fatalf("fired=%v, want %v", got, n)
+		}
+	}
+
+	//心跳延迟，补发错过的周期
+	tm := NewTimerMgr()
+	te := &TimerEntity{sink: sink, ta: ta, h: generateTimerHandle(), interval: time.Second, times: -1,
+		next: time.Now().Add(-3500 * time.Millisecond)}
+	heap.Push(tm.tq, te)
+	tm.tick(false)
+	waitFired(4)
+
+	//时钟跳变，只触发一次
+	atomic.StoreInt32(&fired, 0)
+	tm = NewTimerMgr()
+	te = &TimerEntity{sink: sink, ta: ta, h: generateTimerHandle(), interval: time.Second, times: -1,
+		next: time.Now().Add(-3500 * time.Millisecond)}
+	heap.Push(tm.tq, te)
+	tm.tick(true)
+	waitFired(1)
+	if !te.next.After(time.Now()) {
+		t.Fatalf("next=%v not skipped past now", te.next)
+	}
+}
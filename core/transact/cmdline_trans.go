@@ -0,0 +1,96 @@
+package transact
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/acoderup/goserver.v1/core/cmdline"
+)
+
+const traceTimeLayout = "15:04:05.000"
+
+func transStatsTable() *cmdline.Table {
+	stats := Stats()
+	types := make([]int, 0, len(stats))
+	for tt := range stats {
+		types = append(types, tt)
+	}
+	sort.Ints(types)
+	t := cmdline.NewTable("type", "exec", "commit", "rollback", "timeout", "yield", "resume", "avg(ms)", "max(ms)", "saga", "compensate", "compensate failed", "conflict")
+	for _, tt := range types {
+		s := stats[tt]
+		var avg int64
+		if done := s.CommitTimes + s.RollbackTimes; done > 0 {
+			avg = s.TotalRuningTime / done
+		}
+		t.AddRow(tt, s.ExecuteTimes, s.CommitTimes, s.RollbackTimes, s.TimeoutTimes, s.YieldTimes, s.ResumeTimes, avg, s.MaxRuningTime, s.SagaStepTimes, s.CompensateTimes, s.CompensateFailedTimes, s.OutcomeConflictTimes)
+	}
+	return t
+}
+
+// addTraceRows 按树的层次输出节点，事件按时间顺序合并成一列
+func addTraceRows(t *cmdline.Table, tr *TransNodeTrace, depth int) {
+	var events []string
+	for _, e := range tr.Events {
+		ev := e.Ts.Format(traceTimeLayout) + " " + e.Name()
+		if e.Peer != TransNodeIDNil {
+			ev += fmt.Sprintf("(%v)", e.Peer)
+		}
+		events = append(events, ev)
+	}
+	t.AddRow(strings.Repeat("  ", depth)+fmt.Sprint(tr.TId), tr.Tt, tr.Result, strings.Join(events, ", "))
+	for _, c := range tr.Childs {
+		addTraceRows(t, c, depth+1)
+	}
+}
+
+func init() {
+	cmdline.RegisteCommand(&cmdline.Command{
+		Name:  "trans",
+		Usage: "show transaction stats, failed history and timelines",
+		Run: func(ctx *cmdline.CmdContext) (interface{}, error) {
+			return transStatsTable(), nil
+		},
+		Subs: []*cmdline.Command{{
+			Name:  "failed",
+			Usage: "show recently failed transactions, newest first",
+			Flags: []*cmdline.Flag{{Name: "limit", Type: cmdline.FlagType_Int, Default: "20", Usage: "max transactions to show"}},
+			Run: func(ctx *cmdline.CmdContext) (interface{}, error) {
+				t := cmdline.NewTable("root", "type", "result", "start", "nodes")
+				for i, tr := range GetFailedTrans() {
+					if i >= int(ctx.Int("limit")) {
+						break
+					}
+					var start string
+					if len(tr.Events) > 0 {
+						start = tr.Events[0].Ts.Format(traceTimeLayout)
+					}
+					t.AddRow(tr.TId, tr.Tt, tr.Result, start, countTraceNodes(tr))
+				}
+				return t, nil
+			},
+		}, {
+			Name:  "trace",
+			Usage: "show the timeline tree of a transaction",
+			Flags: []*cmdline.Flag{{Name: "root", Type: cmdline.FlagType_Int, Required: true, Usage: "root transaction node id"}},
+			Run: func(ctx *cmdline.CmdContext) (interface{}, error) {
+				tr := GetTransTrace(TransNodeID(ctx.Int("root")))
+				if tr == nil {
+					return nil, fmt.Errorf("transaction %v not found", ctx.Int("root"))
+				}
+				t := cmdline.NewTable("node", "type", "result", "events")
+				addTraceRows(t, tr, 0)
+				return t, nil
+			},
+		}},
+	})
+}
+
+func countTraceNodes(tr *TransNodeTrace) int {
+	n := 1
+	for _, c := range tr.Childs {
+		n += countTraceNodes(c)
+	}
+	return n
+}
@@ -2,6 +2,8 @@
 package transact
 
 import (
+	"time"
+
 	"github.com/acoderup/goserver.v1/core"
 	"github.com/acoderup/goserver.v1/core/logger"
 )
@@ -10,7 +12,24 @@ var Config = Configuration{}
 
 type Configuration struct {
 	TxSkeletonName string
-	tcs            TransactCommSkeleton
+	//事务日志文件，为空表示不记录日志，崩溃后无法恢复两阶段提交的事务
+	TransLogPath string
+	//每条日志都调用fsync
+	TransLogSync bool
+	//恢复的参与者等待父节点决定的时间(毫秒)，超时后回滚
+	RecoverTimeout time.Duration
+	//提交的决定保留的时间(毫秒)，用于回答恢复后的参与者的查询
+	DecisionRetention time.Duration
+	//运行中压缩事务日志的间隔(毫秒)
+	CompactInterval time.Duration
+	//记录已经结束的事务节点结果的时间(毫秒)，用于识别重复的启动消息和迟到的命令
+	DedupWindow time.Duration
+	//保留最近结束的事务节点时间线的数量
+	TraceHistory int
+	//保留最近失败的事务树的数量
+	FailedHistory int
+	tcs           TransactCommSkeleton
+	tlog          TransLog
 }
 
 func (this *Configuration) Name() string {
@@ -24,6 +43,39 @@ func (this *Configuration) Init() error {
 			logger.Logger.Warnf("%v TxSkeletonName not registed!!!", this.TxSkeletonName)
 		}
 	}
+	if this.RecoverTimeout <= 0 {
+		this.RecoverTimeout = DefaultTransactTimeout
+	} else {
+		this.RecoverTimeout = time.Millisecond * this.RecoverTimeout
+	}
+	if this.DecisionRetention <= 0 {
+		this.DecisionRetention = 10 * time.Minute
+	} else {
+		this.DecisionRetention = time.Millisecond * this.DecisionRetention
+	}
+	if this.CompactInterval <= 0 {
+		this.CompactInterval = time.Minute
+	} else {
+		this.CompactInterval = time.Millisecond * this.CompactInterval
+	}
+	if this.DedupWindow <= 0 {
+		this.DedupWindow = DefaultDedupWindow
+	} else {
+		this.DedupWindow = time.Millisecond * this.DedupWindow
+	}
+	if this.TraceHistory <= 0 {
+		this.TraceHistory = DefaultTraceHistory
+	}
+	if this.FailedHistory <= 0 {
+		this.FailedHistory = DefaultFailedHistory
+	}
+	if this.TransLogPath != "" && this.tlog == nil {
+		tlog, err := NewFileTransLog(this.TransLogPath, this.TransLogSync)
+		if err != nil {
+			return err
+		}
+		this.tlog = tlog
+	}
 	return nil
 }
 
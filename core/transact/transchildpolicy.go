@@ -0,0 +1,139 @@
+package transact
+
+import (
+	"time"
+)
+
+// 子事务部分失败时父节点的处理策略
+const (
+	//任意子事务失败，整个事务回滚
+	TransChildPolicy_AllOrNothing TransChildPolicy = iota
+	//成功的子事务达到Quorum个时提交，否则回滚
+	TransChildPolicy_Quorum
+	//提交成功的子事务，失败的子事务通过FailedChilds查询
+	TransChildPolicy_BestEffort
+)
+
+type TransChildPolicy int
+
+// waitChild 受MaxChildInFlight限制还未发出的子事务
+type waitChild struct {
+	tnp     *TransNodeParam
+	ud      interface{}
+	timeout time.Duration
+}
+
+// FailedChilds 失败的子事务及其返回码，子事务成功但OnChildTransRep返回失败的记为TransResult_Failed
+func (this *TransNode) FailedChilds() map[TransNodeID]int {
+	return this.failChild
+}
+
+// SucceededChilds 已经成功返回的子事务
+func (this *TransNode) SucceededChilds() []TransNodeID {
+	var childs []TransNodeID
+	for tid := range this.finChild {
+		if _, failed := this.failChild[tid]; !failed {
+			childs = append(childs, tid)
+		}
+	}
+	return childs
+}
+
+// quorum 提交需要的成功子事务数量，未设置时为多数
+func (this *TransNode) quorum() int {
+	if this.MyTnp.Quorum > 0 {
+		return this.MyTnp.Quorum
+	}
+	return len(this.Childs)/2 + 1
+}
+
+// childsSatisfied 所有子事务都返回后，按策略判断是否可以提交
+func (this *TransNode) childsSatisfied() bool {
+	switch this.MyTnp.ChildPolicy {
+	case TransChildPolicy_Quorum:
+		return len(this.Childs)-len(this.failChild) >= this.quorum()
+	case TransChildPolicy_BestEffort:
+		return true
+	}
+	return len(this.failChild) == 0
+}
+
+// quorumLost 失败的子事务过多，已经不可能达到Quorum
+func (this *TransNode) quorumLost() bool {
+	return this.MyTnp.ChildPolicy == TransChildPolicy_Quorum && len(this.Childs)-len(this.failChild) < this.quorum()
+}
+
+// skipChildCmd 失败的和未发出的子事务不需要通知提交或者回滚
+func (this *TransNode) skipChildCmd(tid TransNodeID) bool {
+	if _, failed := this.failChild[tid]; failed {
+		return true
+	}
+	for _, w := range this.waitChilds {
+		if w.tnp.TId == tid {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *TransNode) childInFlight() int {
+	return len(this.Childs) - len(this.finChild) - len(this.waitChilds)
+}
+
+// launchWaitChilds 有子事务返回后，继续发出等待中的子事务
+func (this *TransNode) launchWaitChilds() {
+	for len(this.waitChilds) > 0 && !this.done {
+		if this.MyTnp.MaxChildInFlight > 0 && this.childInFlight() >= this.MyTnp.MaxChildInFlight {
+			return
+		}
+		w := this.waitChilds[0]
+		this.waitChilds = this.waitChilds[1:]
+		this.launchChildTrans(w.tnp, w.ud, w.timeout)
+	}
+}
+
+// partialChildTransRep Quorum和BestEffort策略下处理子事务的返回，失败的子事务只记录，不立即回滚
+func (this *TransNode) partialChildTransRep(child TransNodeID, retCode int, ret TransExeResult) TransExeResult {
+	if retCode != TransResult_Success || ret != TransExeResult_Success {
+		if this.failChild == nil {
+			this.failChild = make(map[TransNodeID]int)
+		}
+		if retCode == TransResult_Success {
+			retCode = TransResult_Failed
+		}
+		this.failChild[child] = retCode
+	}
+	if this.quorumLost() {
+		this.reportChildsFailed()
+		return TransExeResult_Success
+	}
+	this.launchWaitChilds()
+	if len(this.Childs) != len(this.finChild) || this.yield != this.resume {
+		return TransExeResult_Success
+	}
+	if !this.childsSatisfied() {
+		this.reportChildsFailed()
+		return TransExeResult_Success
+	}
+	if this.MyTnp.LevelNo == TransRootNodeLevel {
+		this.commit()
+	} else {
+		this.logPrepare()
+		if Config.tcs != nil {
+			this.TransRep.RetCode = TransResult_Success
+			Config.tcs.SendTransResult(this.ParentTnp, this.MyTnp, this.TransRep)
+		}
+		if this.isSelfDecide() {
+			this.commit()
+		}
+	}
+	return TransExeResult_Success
+}
+
+func (this *TransNode) reportChildsFailed() {
+	if this.MyTnp.LevelNo > TransRootNodeLevel && Config.tcs != nil {
+		this.TransRep.RetCode = TransResult_Failed
+		Config.tcs.SendTransResult(this.ParentTnp, this.MyTnp, this.TransRep)
+	}
+	this.rollback(TransNodeIDNil)
+}
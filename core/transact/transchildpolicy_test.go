@@ -0,0 +1,143 @@
+package transact
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// childSkeleton 记录启动的子事务和发送的命令，在core object中写入，测试协程中读取
+type childSkeleton struct {
+	testSkeleton
+	lock   sync.Mutex
+	starts []TransNodeID
+}
+
+func (ts *childSkeleton) SendTransStart(parent, me *TransNodeParam, ud interface{}) bool {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	ts.starts = append(ts.starts, me.TId)
+	return true
+}
+
+func (ts *childSkeleton) SendCmdToTransNode(tnp *TransNodeParam, cmd TransCmd) bool {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	return ts.testSkeleton.SendCmdToTransNode(tnp, cmd)
+}
+
+func (ts *childSkeleton) started() []TransNodeID {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	return append([]TransNodeID(nil), ts.starts...)
+}
+
+func (ts *childSkeleton) sentCmds() map[TransNodeID]TransCmd {
+	ts.lock.Lock()
+	defer ts.lock.Unlock()
+	cmds := make(map[TransNodeID]TransCmd, len(ts.cmds))
+	for k, v := range ts.cmds {
+		cmds[k] = v
+	}
+	return cmds
+}
+
+// startBroadcast 根节点启动n个两阶段子事务，返回根节点和最终结果
+func startBroadcast(t *testing.T, root *TransNodeParam, n int) (*TransNode, *childSkeleton, chan bool) {
+	ts := &childSkeleton{testSkeleton: testSkeleton{cmds: make(map[TransNodeID]TransCmd)}}
+	oldTcs := Config.tcs
+	Config.tcs = ts
+	t.Cleanup(func() { Config.tcs = oldTcs })
+
+	sagaTypeSeq++
+	root.Tt = sagaTypeSeq
+	result := make(chan bool, 1)
+	RegisteHandler(root.Tt, &TransHanderWrapper{
+		OnExecuteWrapper: func(n *TransNode, ud interface{}) TransExeResult {
+			for i := 0; i < count(ud); i++ {
+				n.StartChildTrans(&TransNodeParam{Tt: root.Tt, Tct: TransactCommitPolicy_TwoPhase}, nil, time.Minute)
+			}
+			return TransExeResult_Success
+		},
+		OnCommitWrapper:   func(n *TransNode) TransExeResult { result <- true; return TransExeResult_Success },
+		OnRollBackWrapper: func(n *TransNode) TransExeResult { result <- false; return TransExeResult_Success },
+	})
+	var tnode *TransNode
+	runOnCore(func() {
+		tnode = DTCModule.StartTrans(root, n, time.Minute)
+		tnode.Go(nil)
+	})
+	return tnode, ts, result
+}
+
+func count(ud interface{}) int {
+	n, _ := ud.(int)
+	return n
+}
+
+func childRep(tnode *TransNode, child TransNodeID, retCode int) {
+	runOnCore(func() { ProcessTransResult(tnode.MyTnp.TId, child, retCode, nil) })
+}
+
+func expectResult(t *testing.T, result chan bool, committed bool) {
+	select {
+	case r := <-result:
+		if r != committed {
+			t.Fatalf("expect committed=%v got %v", committed, r)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("wait trans result timeout")
+	}
+}
+
+func TestChildPolicyQuorumInFlight(t *testing.T) {
+	tnode, ts, result := startBroadcast(t, &TransNodeParam{ChildPolicy: TransChildPolicy_Quorum, Quorum: 2, MaxChildInFlight: 2}, 3)
+	starts := ts.started()
+	if len(starts) != 2 {
+		t.Fatalf("in flight limit not applied, starts=%v", starts)
+	}
+	c1, c2 := starts[0], starts[1]
+	childRep(tnode, c1, TransResult_Success)
+	if starts = ts.started(); len(starts) != 3 {
+		t.Fatalf("wait child not launched, starts=%v", starts)
+	}
+	c3 := starts[2]
+	childRep(tnode, c2, TransResult_Failed)
+	childRep(tnode, c3, TransResult_Success)
+	expectResult(t, result, true)
+	cmds := ts.sentCmds()
+	if cmds[c1] != TransCmd_Commit || cmds[c3] != TransCmd_Commit {
+		t.Fatalf("succeeded childs not committed, cmds=%v", cmds)
+	}
+	if _, exist := cmds[c2]; exist {
+		t.Fatalf("failed child should not receive cmd, cmds=%v", cmds)
+	}
+}
+
+func TestChildPolicyQuorumLost(t *testing.T) {
+	tnode, ts, result := startBroadcast(t, &TransNodeParam{ChildPolicy: TransChildPolicy_Quorum}, 3)
+	starts := ts.started()
+	childRep(tnode, starts[0], TransResult_Failed)
+	childRep(tnode, starts[1], TransResult_TimeOut)
+	expectResult(t, result, false)
+	if cmds := ts.sentCmds(); cmds[starts[2]] != TransCmd_RollBack || len(cmds) != 1 {
+		t.Fatalf("pending child not rollback, cmds=%v", cmds)
+	}
+}
+
+func TestChildPolicyBestEffort(t *testing.T) {
+	tnode, ts, result := startBroadcast(t, &TransNodeParam{ChildPolicy: TransChildPolicy_BestEffort}, 2)
+	starts := ts.started()
+	childRep(tnode, starts[0], TransResult_Success)
+	childRep(tnode, starts[1], TransResult_Failed)
+	expectResult(t, result, true)
+	var failed map[TransNodeID]int
+	var succeeded []TransNodeID
+	runOnCore(func() { failed, succeeded = tnode.FailedChilds(), tnode.SucceededChilds() })
+	if len(failed) != 1 || failed[starts[1]] != TransResult_Failed {
+		t.Fatalf("failed childs=%v", failed)
+	}
+	if len(succeeded) != 1 || succeeded[0] != starts[0] {
+		t.Fatalf("succeeded childs=%v", succeeded)
+	}
+}
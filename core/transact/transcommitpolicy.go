@@ -3,6 +3,8 @@ package transact
 const (
 	TransactCommitPolicy_SelfDecide TransactCommitPolicy = iota
 	TransactCommitPolicy_TwoPhase
+	//saga: 按顺序执行各个步骤，失败时逆序补偿，见RegisteSaga
+	TransactCommitPolicy_Saga
 )
 
 type TransactCommitPolicy int
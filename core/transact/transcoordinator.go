@@ -23,6 +23,14 @@ type transactCoordinater struct {
 	transPool map[TransNodeID]*TransNode
 	quit      bool
 	reaped    bool
+	decisions map[TransNodeID]*transDecision
+	lastPrune time.Time
+	//上次压缩后追加的日志数量，上次压缩保留的日志数量
+	logAppends  int64
+	compactKept int
+	lastCompact time.Time
+	tracer      transTracer
+	dedup       transDedup
 }
 
 func (this *transactCoordinater) ModuleName() string {
@@ -30,9 +38,18 @@ func (this *transactCoordinater) ModuleName() string {
 }
 
 func (this *transactCoordinater) Init() {
+	this.recover()
 }
 
 func (this *transactCoordinater) Update() {
+	if Config.tlog == nil || this.quit {
+		return
+	}
+	if nowTime := time.Now(); nowTime.Sub(this.lastCompact) >= Config.CompactInterval {
+		this.lastCompact = nowTime
+		//日志可能在其他Object中同时追加，由TransLog.Compact保证压缩期间追加的记录不会丢失
+		this.compactTransLog()
+	}
 }
 
 func (this *transactCoordinater) Shutdown() {
@@ -65,6 +82,9 @@ func (this *transactCoordinater) reapRoutine() {
 }
 
 func (this *transactCoordinater) destroy() {
+	if Config.tlog != nil {
+		Config.tlog.Close()
+	}
 	module.UnregisteModule(this)
 }
 
@@ -74,6 +94,8 @@ func (this *transactCoordinater) releaseTrans(tnode *TransNode) {
 	}
 	timer.StopTimer(tnode.timeHandle)
 	this.delTransNode(tnode)
+	this.addCompleted(tnode)
+	this.traceFinish(tnode)
 }
 
 func (this *transactCoordinater) spawnTransNodeID() TransNodeID {
@@ -102,6 +124,9 @@ func (this *transactCoordinater) createTransNode(tnp *TransNodeParam, ud interfa
 	if tnp.TId == TransNodeIDNil {
 		tnp.TId = this.spawnTransNodeID()
 	}
+	if tnp.RootTId == TransNodeIDNil {
+		tnp.RootTId = tnp.TId
+	}
 
 	if Config.tcs != nil {
 		tnp.SkeletonID = Config.tcs.GetSkeletonID()
@@ -119,6 +144,7 @@ func (this *transactCoordinater) createTransNode(tnp *TransNodeParam, ud interfa
 		createTime: time.Now(),
 	}
 
+	tnode.trace(TransEvent_Created, TransNodeIDNil, 0)
 	this.addTransNode(tnode)
 
 	if h, ok := timer.StartTimer(tta, tnode, tnp.TimeOut, 1); ok {
@@ -143,7 +169,7 @@ func (this *transactCoordinater) StartTrans(tnp *TransNodeParam, ud interface{},
 func (this *transactCoordinater) ProcessTransResult(tid, childtid TransNodeID, retCode int, ud interface{}) bool {
 	tnode := this.getTransNode(tid)
 	if tnode == nil {
-		return false
+		return this.dedupTransResult(tid, childtid, retCode)
 	}
 	ret := tnode.childTransRep(childtid, retCode, ud)
 	if ret != TransExeResult_Success {
@@ -157,6 +183,9 @@ func (this *transactCoordinater) ProcessTransStart(parentTnp, myTnp *TransNodePa
 		logger.Logger.Warn("transactCoordinater.processTransStart find shutdowning, parent=", parentTnp, " selfparam=", myTnp)
 		return false
 	}
+	if this.dedupTransStart(myTnp) {
+		return true
+	}
 	tnode := this.createTransNode(myTnp, ud, timeout)
 	if tnode == nil {
 		return false
@@ -174,7 +203,7 @@ func (this *transactCoordinater) ProcessTransStart(parentTnp, myTnp *TransNodePa
 func (this *transactCoordinater) ProcessTransCmd(tid TransNodeID, cmd TransCmd) bool {
 	tnode := this.getTransNode(tid)
 	if tnode == nil {
-		return false
+		return this.dedupTransCmd(tid, cmd)
 	}
 
 	switch cmd {
@@ -208,7 +237,7 @@ func (this *transactCoordinater) delTransNode(tnode *TransNode) {
 }
 
 func init() {
-	module.RegisteModule(DTCModule, time.Hour, 1)
+	module.RegisteModule(DTCModule, time.Second, 1)
 }
 
 func ProcessTransResult(tid, childtid TransNodeID, retCode int, ud interface{}) bool {
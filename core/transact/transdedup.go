@@ -0,0 +1,136 @@
+package transact
+
+import (
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/logger"
+)
+
+const DefaultDedupWindow = time.Minute
+
+// completedTrans 最近结束的事务节点，用于识别重发的启动消息和迟到的命令
+type completedTrans struct {
+	tid     TransNodeID
+	outcome TransCmd
+	parent  *TransNodeParam
+	me      *TransNodeParam
+	ts      time.Time
+}
+
+// transDedup 按结束时间先后保存，过期的从头部淘汰
+type transDedup struct {
+	completed map[TransNodeID]*completedTrans
+	order     []*completedTrans
+}
+
+func dedupWindow() time.Duration {
+	if Config.DedupWindow > 0 {
+		return Config.DedupWindow
+	}
+	return DefaultDedupWindow
+}
+
+// addCompleted 记录结束的事务节点，调用方持有锁
+func (this *transDedup) addCompleted(tnode *TransNode, nowTime time.Time) {
+	if this.completed == nil {
+		this.completed = make(map[TransNodeID]*completedTrans)
+	}
+	c := &completedTrans{tid: tnode.MyTnp.TId, outcome: tnode.outcome, parent: tnode.ParentTnp, me: tnode.MyTnp, ts: nowTime}
+	this.completed[c.tid] = c
+	this.order = append(this.order, c)
+	window := dedupWindow()
+	for len(this.order) > 0 && nowTime.Sub(this.order[0].ts) >= window {
+		old := this.order[0]
+		this.order = this.order[1:]
+		if this.completed[old.tid] == old {
+			delete(this.completed, old.tid)
+		}
+	}
+}
+
+func (this *transDedup) getCompleted(tid TransNodeID) *completedTrans {
+	if c, exist := this.completed[tid]; exist && time.Since(c.ts) < dedupWindow() {
+		return c
+	}
+	return nil
+}
+
+func (this *transactCoordinater) addCompleted(tnode *TransNode) {
+	if tnode.outcome == TransCmd_Invalid {
+		return
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.dedup.addCompleted(tnode, time.Now())
+}
+
+// GetCompletedOutcome 查询最近结束的事务节点的结果，超出DedupWindow后不再记录
+func (this *transactCoordinater) GetCompletedOutcome(tid TransNodeID) (TransCmd, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if c := this.dedup.getCompleted(tid); c != nil {
+		return c.outcome, true
+	}
+	return TransCmd_Invalid, false
+}
+
+func (this *transactCoordinater) getCompleted(tid TransNodeID) *completedTrans {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return this.dedup.getCompleted(tid)
+}
+
+// dedupTransStart 重复的启动消息不再创建节点，已经结束的节点向父节点重发结果
+func (this *transactCoordinater) dedupTransStart(myTnp *TransNodeParam) bool {
+	if this.getTransNode(myTnp.TId) != nil {
+		logger.Logger.Tracef("transactCoordinater duplicate start, node %v is running", myTnp.TId)
+		return true
+	}
+	c := this.getCompleted(myTnp.TId)
+	if c == nil {
+		return false
+	}
+	logger.Logger.Tracef("transactCoordinater duplicate start, node %v completed with %v", myTnp.TId, c.outcome)
+	this.answerOutcome(c)
+	return true
+}
+
+// dedupTransCmd 已经结束的节点收到命令，结果一致时直接确认，不一致时回复记录的结果
+func (this *transactCoordinater) dedupTransCmd(tid TransNodeID, cmd TransCmd) bool {
+	c := this.getCompleted(tid)
+	if c == nil {
+		return false
+	}
+	if c.outcome != cmd {
+		logger.Logger.Warnf("transactCoordinater late cmd %v for node %v, which completed with %v", cmd, tid, c.outcome)
+		this.answerOutcome(c)
+	}
+	return true
+}
+
+// dedupTransResult 已经结束的节点收到子节点的结果，通常是子节点对迟到的命令回复的结果
+// 提交时所有子事务都已经返回，之后收到失败说明子节点已经回滚(例如超时后才收到提交的命令)，记录错误需要人工处理
+// 回滚后收到成功是正常的，子节点会收到回滚的命令
+func (this *transactCoordinater) dedupTransResult(tid, childtid TransNodeID, retCode int) bool {
+	c := this.getCompleted(tid)
+	if c == nil {
+		return false
+	}
+	if c.outcome == TransCmd_Commit && retCode != TransResult_Success {
+		logger.Logger.Errorf("transactCoordinater outcome conflict, node %v committed but child %v rolled back, retCode=%v", tid, childtid, retCode)
+		incTransStats(c.me.Tt, TransStatsOp_OutcomeConflict)
+	}
+	return true
+}
+
+// answerOutcome 向父节点回复本节点的结果，父节点已经结束时由dedupTransResult处理
+func (this *transactCoordinater) answerOutcome(c *completedTrans) {
+	if Config.tcs == nil || c.parent == nil {
+		return
+	}
+	tr := &TransResult{RetCode: TransResult_Success}
+	if c.outcome != TransCmd_Commit {
+		tr.RetCode = TransResult_Failed
+	}
+	Config.tcs.SendTransResult(c.parent, c.me, tr)
+}
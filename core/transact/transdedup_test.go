@@ -0,0 +1,112 @@
+package transact
+
+import (
+	"testing"
+	"time"
+)
+
+type resultSkeleton struct {
+	testSkeleton
+	results []int
+}
+
+func (ts *resultSkeleton) SendTransResult(parent, me *TransNodeParam, tr *TransResult) bool {
+	ts.results = append(ts.results, tr.RetCode)
+	return true
+}
+
+func TestTransDedup(t *testing.T) {
+	ts := &resultSkeleton{testSkeleton: testSkeleton{cmds: make(map[TransNodeID]TransCmd)}}
+	oldTcs := Config.tcs
+	Config.tcs = ts
+	t.Cleanup(func() { Config.tcs = oldTcs })
+
+	sagaTypeSeq++
+	tt := sagaTypeSeq
+	var executed int
+	RegisteHandler(tt, &TransHanderWrapper{
+		OnExecuteWrapper: func(n *TransNode, ud interface{}) TransExeResult {
+			executed++
+			return TransExeResult_Success
+		},
+	})
+	parent := &TransNodeParam{TId: TransNodeID(tt)<<20 + 1, Tt: tt}
+	me := &TransNodeParam{TId: TransNodeID(tt)<<20 + 2, Tt: tt, LevelNo: 1, Tct: TransactCommitPolicy_SelfDecide}
+	runOnCore(func() {
+		if !ProcessTransStart(parent, me, nil, time.Minute) || !ProcessTransStart(parent, me, nil, time.Minute) {
+			t.Error("process trans start failed")
+		}
+	})
+	if executed != 1 {
+		t.Fatalf("duplicate start executed %v times", executed)
+	}
+	if len(ts.results) != 2 || ts.results[1] != TransResult_Success {
+		t.Fatalf("duplicate start not answered with outcome, results=%v", ts.results)
+	}
+	if outcome, exist := DTCModule.GetCompletedOutcome(me.TId); !exist || outcome != TransCmd_Commit {
+		t.Fatalf("completed outcome=%v exist=%v", outcome, exist)
+	}
+
+	runOnCore(func() {
+		if !ProcessTransCmd(me.TId, TransCmd_Commit) {
+			t.Error("late commit cmd not acked")
+		}
+		if !ProcessTransCmd(me.TId, TransCmd_RollBack) {
+			t.Error("late rollback cmd not acked")
+		}
+		if ProcessTransCmd(me.TId+100, TransCmd_Commit) {
+			t.Error("cmd for unknown node acked")
+		}
+	})
+	if len(ts.results) != 3 || ts.results[2] != TransResult_Success {
+		t.Fatalf("conflicting cmd not answered with outcome, results=%v", ts.results)
+	}
+}
+
+// loopbackResultSkeleton 结果直接交给本地的协调器处理，记录是否被接受
+type loopbackResultSkeleton struct {
+	testSkeleton
+	accepted []bool
+}
+
+func (ts *loopbackResultSkeleton) SendTransResult(parent, me *TransNodeParam, tr *TransResult) bool {
+	ts.accepted = append(ts.accepted, ProcessTransResult(parent.TId, me.TId, tr.RetCode, nil))
+	return true
+}
+
+func TestTransDedupLateCmdAnswer(t *testing.T) {
+	ts := &loopbackResultSkeleton{testSkeleton: testSkeleton{cmds: make(map[TransNodeID]TransCmd)}}
+	oldTcs := Config.tcs
+	Config.tcs = ts
+	t.Cleanup(func() { Config.tcs = oldTcs })
+
+	sagaTypeSeq++
+	tt := sagaTypeSeq
+	RegisteHandler(tt, &TransHanderWrapper{
+		OnExecuteWrapper: func(n *TransNode, ud interface{}) TransExeResult {
+			if n.MyTnp.LevelNo > TransRootNodeLevel {
+				return TransExeResult_Failed
+			}
+			return TransExeResult_Success
+		},
+	})
+	parent := &TransNodeParam{TId: TransNodeID(tt)<<20 + 1, Tt: tt}
+	me := &TransNodeParam{TId: TransNodeID(tt)<<20 + 2, Tt: tt, LevelNo: 1, Tct: TransactCommitPolicy_SelfDecide}
+	runOnCore(func() {
+		//子节点失败回滚，父节点还不存在
+		ProcessTransStart(parent, me, nil, time.Minute)
+		DTCModule.StartTrans(parent, nil, time.Minute).Go(nil)
+	})
+	if outcome, _ := DTCModule.GetCompletedOutcome(parent.TId); outcome != TransCmd_Commit {
+		t.Fatalf("parent outcome=%v", outcome)
+	}
+
+	//父节点迟到的提交命令，子节点回复的结果由已经结束的父节点处理
+	runOnCore(func() { ProcessTransCmd(me.TId, TransCmd_Commit) })
+	if len(ts.accepted) != 2 || ts.accepted[0] || !ts.accepted[1] {
+		t.Fatalf("late result not handled by completed parent, accepted=%v", ts.accepted)
+	}
+	if stats := Stats()[int(tt)]; stats.OutcomeConflictTimes != 1 {
+		t.Fatalf("outcome conflict not recorded, stats=%+v", stats)
+	}
+}
@@ -0,0 +1,200 @@
+// translog
+package transact
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	TransLogOp_ChildStart int = iota + 1
+	TransLogOp_Prepare
+	TransLogOp_Commit
+	TransLogOp_RollBack
+	TransLogOp_End
+)
+
+// TransLogRecord 事务日志记录
+// ChildStart: 节点启动了两阶段提交的子事务，Child为子节点参数
+// Prepare: 两阶段提交的参与者执行成功，等待父节点的决定
+// Commit/RollBack: 节点做出(或者收到)决定，之后才向子节点发送命令
+// End: 决定已经发送给所有子节点
+type TransLogRecord struct {
+	Op     int
+	TId    TransNodeID
+	Tnp    *TransNodeParam `json:",omitempty"`
+	Parent *TransNodeParam `json:",omitempty"`
+	Child  *TransNodeParam `json:",omitempty"`
+	Ts     int64
+}
+
+// TransLog 事务日志的存储
+// Append 可能在不同的Object中并发调用，返回后记录必须已经持久化
+// Rewrite 用给定的记录替换全部日志，用于恢复完成后重写日志
+// Compact 只保留keep返回true的记录，过滤和重写期间不能有记录追加进来，用于运行中定期压缩日志
+type TransLog interface {
+	Append(r *TransLogRecord) error
+	Load() ([]*TransLogRecord, error)
+	Rewrite(records []*TransLogRecord) error
+	Compact(keep func(r *TransLogRecord) bool) error
+	Close() error
+}
+
+// SetTransLog 设置事务日志，为nil时关闭日志
+func SetTransLog(l TransLog) {
+	Config.tlog = l
+}
+
+func GetTransLog() TransLog {
+	return Config.tlog
+}
+
+// FileTransLog 基于文件的事务日志，每条记录一行json
+type FileTransLog struct {
+	lock sync.Mutex
+	path string
+	sync bool
+	f    *os.File
+	w    *bufio.Writer
+}
+
+// NewFileTransLog 打开事务日志文件，sync为true时每条记录都调用fsync
+func NewFileTransLog(path string, sync bool) (*FileTransLog, error) {
+	l := &FileTransLog{path: path, sync: sync}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *FileTransLog) open() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	l.f = f
+	l.w = bufio.NewWriter(f)
+	return nil
+}
+
+func (l *FileTransLog) Append(r *TransLogRecord) error {
+	if r.Ts == 0 {
+		r.Ts = time.Now().UnixNano()
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, err = l.w.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err = l.w.Flush(); err != nil {
+		return err
+	}
+	if l.sync {
+		return l.f.Sync()
+	}
+	return nil
+}
+
+func (l *FileTransLog) Load() ([]*TransLogRecord, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.load()
+}
+
+func (l *FileTransLog) load() ([]*TransLogRecord, error) {
+	f, err := os.Open(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var records []*TransLogRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		r := &TransLogRecord{}
+		if err = json.Unmarshal(scanner.Bytes(), r); err != nil {
+			//最后一行可能因为崩溃只写了一半，丢弃
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
+
+func (l *FileTransLog) Rewrite(records []*TransLogRecord) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.rewrite(records)
+}
+
+// Compact 读取、过滤和重写都持有锁，期间追加的记录不会在改名时丢失
+func (l *FileTransLog) Compact(keep func(r *TransLogRecord) bool) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	records, err := l.load()
+	if err != nil {
+		return err
+	}
+	kept := records[:0]
+	for _, r := range records {
+		if keep(r) {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(records) {
+		return nil
+	}
+	return l.rewrite(kept)
+}
+
+func (l *FileTransLog) rewrite(records []*TransLogRecord) error {
+	tmp := l.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		w.Write(append(data, '\n'))
+	}
+	if err = w.Flush(); err == nil {
+		err = f.Sync()
+	}
+	f.Close()
+	if err != nil {
+		return err
+	}
+	l.f.Close()
+	if err = os.Rename(tmp, l.path); err != nil {
+		l.open()
+		return err
+	}
+	return l.open()
+}
+
+func (l *FileTransLog) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	l.w.Flush()
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
@@ -0,0 +1,220 @@
+package transact
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testSkeleton struct {
+	cmds map[TransNodeID]TransCmd
+}
+
+func (ts *testSkeleton) SendTransResult(parent, me *TransNodeParam, tr *TransResult) bool {
+	return true
+}
+func (ts *testSkeleton) SendTransStart(parent, me *TransNodeParam, ud interface{}) bool { return true }
+func (ts *testSkeleton) SendCmdToTransNode(tnp *TransNodeParam, cmd TransCmd) bool {
+	ts.cmds[tnp.TId] = cmd
+	return true
+}
+func (ts *testSkeleton) GetSkeletonID() int { return 1 }
+func (ts *testSkeleton) GetAreaID() int     { return 1 }
+
+func setupTestLog(t *testing.T) (*FileTransLog, *testSkeleton) {
+	tlog, err := NewFileTransLog(filepath.Join(t.TempDir(), "trans.log"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := &testSkeleton{cmds: make(map[TransNodeID]TransCmd)}
+	oldTcs, oldLog, oldRetention := Config.tcs, Config.tlog, Config.DecisionRetention
+	Config.tcs, Config.tlog, Config.DecisionRetention = ts, tlog, time.Minute
+	t.Cleanup(func() {
+		tlog.Close()
+		Config.tcs, Config.tlog, Config.DecisionRetention = oldTcs, oldLog, oldRetention
+	})
+	return tlog, ts
+}
+
+func tnp(tid TransNodeID, level int) *TransNodeParam {
+	return &TransNodeParam{TId: tid, LevelNo: level, Tct: TransactCommitPolicy_TwoPhase}
+}
+
+func TestFileTransLog(t *testing.T) {
+	tlog, _ := setupTestLog(t)
+	tlog.Append(&TransLogRecord{Op: TransLogOp_ChildStart, TId: 1, Tnp: tnp(1, 0), Child: tnp(2, 1)})
+	tlog.Append(&TransLogRecord{Op: TransLogOp_Commit, TId: 1})
+	//模拟崩溃时只写了一半的记录
+	f, _ := os.OpenFile(tlog.path, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString(`{"Op":5,"TI`)
+	f.Close()
+
+	records, err := tlog.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Child.TId != 2 || records[1].Op != TransLogOp_Commit {
+		t.Fatalf("load records=%v", records)
+	}
+	if err = tlog.Rewrite(records[:1]); err != nil {
+		t.Fatal(err)
+	}
+	tlog.Append(&TransLogRecord{Op: TransLogOp_End, TId: 1})
+	if records, _ = tlog.Load(); len(records) != 2 || records[1].Op != TransLogOp_End {
+		t.Fatalf("records after rewrite=%v", records)
+	}
+}
+
+func TestTransLogRecover(t *testing.T) {
+	tlog, ts := setupTestLog(t)
+	//1: 根节点已经决定提交，但没有来得及通知子节点
+	tlog.Append(&TransLogRecord{Op: TransLogOp_ChildStart, TId: 1, Tnp: tnp(1, 0), Child: tnp(11, 1)})
+	tlog.Append(&TransLogRecord{Op: TransLogOp_Commit, TId: 1})
+	//2: 根节点还没有决定，按presumed abort回滚
+	tlog.Append(&TransLogRecord{Op: TransLogOp_ChildStart, TId: 2, Tnp: tnp(2, 0), Child: tnp(21, 1)})
+	//3: 已经结束的事务不需要处理
+	tlog.Append(&TransLogRecord{Op: TransLogOp_ChildStart, TId: 3, Tnp: tnp(3, 0), Child: tnp(31, 1)})
+	tlog.Append(&TransLogRecord{Op: TransLogOp_RollBack, TId: 3})
+	tlog.Append(&TransLogRecord{Op: TransLogOp_End, TId: 3})
+
+	coordinator := &transactCoordinater{transPool: make(map[TransNodeID]*TransNode)}
+	coordinator.recover()
+
+	if ts.cmds[11] != TransCmd_Commit || ts.cmds[21] != TransCmd_RollBack {
+		t.Fatalf("recover cmds=%v", ts.cmds)
+	}
+	if _, exist := ts.cmds[31]; exist {
+		t.Fatal("ended transaction should not be resent")
+	}
+	//压缩后只保留提交的决定，再次恢复不会重发
+	states := analyzeTransLog(mustLoad(t, tlog))
+	if len(states) != 1 || !states[1].ended || states[1].decision != TransCmd_Commit {
+		t.Fatalf("states after recover=%v", states)
+	}
+
+	//恢复后的参与者查询结果
+	ts.cmds = make(map[TransNodeID]TransCmd)
+	coordinator.ProcessTransQuery(1, tnp(11, 1))
+	coordinator.ProcessTransQuery(2, queryTnp(21, time.Now()))
+	if ts.cmds[11] != TransCmd_Commit || ts.cmds[21] != TransCmd_RollBack {
+		t.Fatalf("query answers=%v", ts.cmds)
+	}
+	//子节点创建的时间早于决定的保留时间，提交的决定可能已经过期，结果未知不回复
+	if coordinator.ProcessTransQuery(4, queryTnp(41, time.Now().Add(-2*time.Minute))) {
+		t.Fatal("expired query answered")
+	}
+	if cmd, exist := ts.cmds[41]; exist {
+		t.Fatalf("presumed %v after decision retention", cmd)
+	}
+}
+
+func queryTnp(tid TransNodeID, created time.Time) *TransNodeParam {
+	p := tnp(tid, 1)
+	p.TimeOut = time.Second
+	p.ExpiresTs = created.Add(p.TimeOut).UnixNano()
+	return p
+}
+
+func TestCompactTransLog(t *testing.T) {
+	tlog, _ := setupTestLog(t)
+	coordinator := &transactCoordinater{transPool: make(map[TransNodeID]*TransNode)}
+	old := time.Now().Add(-2 * time.Minute).UnixNano()
+	//1: 进行中的事务
+	coordinator.appendLog(&TransLogRecord{Op: TransLogOp_ChildStart, TId: 1, Tnp: tnp(1, 0), Child: tnp(11, 1)})
+	//2: 刚提交的事务，保留决定
+	coordinator.appendLog(&TransLogRecord{Op: TransLogOp_Commit, TId: 2})
+	coordinator.appendLog(&TransLogRecord{Op: TransLogOp_End, TId: 2})
+	//3: 提交的决定已经超过保留时间
+	coordinator.appendLog(&TransLogRecord{Op: TransLogOp_Commit, TId: 3, Ts: old})
+	coordinator.appendLog(&TransLogRecord{Op: TransLogOp_End, TId: 3, Ts: old})
+	//4: 回滚结束的事务
+	coordinator.appendLog(&TransLogRecord{Op: TransLogOp_RollBack, TId: 4})
+	coordinator.appendLog(&TransLogRecord{Op: TransLogOp_End, TId: 4})
+
+	coordinator.compactTransLog()
+	states := analyzeTransLog(mustLoad(t, tlog))
+	if len(states) != 2 || states[1] == nil || states[1].ended || states[2] == nil || states[2].decision != TransCmd_Commit {
+		t.Fatalf("states after compact=%v", states)
+	}
+
+	//没有新的记录时，保留的决定过期后也会被压缩
+	Config.DecisionRetention = time.Nanosecond
+	coordinator.appendLog(&TransLogRecord{Op: TransLogOp_End, TId: 1})
+	coordinator.compactTransLog()
+	coordinator.compactTransLog()
+	if records := mustLoad(t, tlog); len(records) != 0 || coordinator.compactKept != 0 {
+		t.Fatalf("records after retention=%v", records)
+	}
+}
+
+func mustLoad(t *testing.T, tlog TransLog) []*TransLogRecord {
+	records, err := tlog.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return records
+}
+
+func TestCompactWhileAppending(t *testing.T) {
+	tlog, _ := setupTestLog(t)
+	tlog.Append(&TransLogRecord{Op: TransLogOp_RollBack, TId: 1})
+	tlog.Append(&TransLogRecord{Op: TransLogOp_End, TId: 1})
+
+	//过滤期间其他协程追加的记录，压缩后仍然存在
+	filtering := make(chan struct{})
+	appended := make(chan struct{})
+	go func() {
+		<-filtering
+		tlog.Append(&TransLogRecord{Op: TransLogOp_ChildStart, TId: 2, Tnp: tnp(2, 0), Child: tnp(21, 1)})
+		close(appended)
+	}()
+	var once sync.Once
+	err := tlog.Compact(func(r *TransLogRecord) bool {
+		once.Do(func() {
+			close(filtering)
+			time.Sleep(20 * time.Millisecond)
+		})
+		return r.TId != 1
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-appended
+	if records := mustLoad(t, tlog); len(records) != 1 || records[0].TId != 2 {
+		t.Fatalf("records after compact=%v", records)
+	}
+
+	//协调者在其他协程追加日志时定期压缩
+	coordinator := &transactCoordinater{transPool: make(map[TransNodeID]*TransNode)}
+	n := 200
+	go func() {
+		for i := 0; i < n; i++ {
+			tid := TransNodeID(100 + i)
+			coordinator.appendLog(&TransLogRecord{Op: TransLogOp_ChildStart, TId: tid, Tnp: tnp(tid, 0), Child: tnp(tid*10, 1)})
+			coordinator.appendLog(&TransLogRecord{Op: TransLogOp_RollBack, TId: tid})
+			coordinator.appendLog(&TransLogRecord{Op: TransLogOp_End, TId: tid})
+			coordinator.appendLog(&TransLogRecord{Op: TransLogOp_ChildStart, TId: -tid, Tnp: tnp(-tid, 0), Child: tnp(-tid*10, 1)})
+		}
+		close(filtering)
+	}()
+	filtering = make(chan struct{})
+	for running := true; running; {
+		select {
+		case <-filtering:
+			running = false
+		default:
+		}
+		coordinator.compactTransLog()
+	}
+	states := analyzeTransLog(mustLoad(t, tlog))
+	for i := 0; i < n; i++ {
+		if st := states[TransNodeID(-100-i)]; st == nil || len(st.childs) != 1 {
+			t.Fatalf("in progress transaction %v lost", -100-i)
+		}
+		if st := states[TransNodeID(100+i)]; st != nil && st.ended {
+			t.Fatalf("ended transaction %v kept", 100+i)
+		}
+	}
+}
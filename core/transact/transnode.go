@@ -31,6 +31,7 @@ const (
 	TransExeResult_HadDone
 	TransExeResult_StartChildFailed
 	TransExeResult_UnsafeExecuteEnv
+	TransExeResult_TypeMismatch
 )
 const (
 	///transact owner type
@@ -60,6 +61,7 @@ type TransCmd int
 type TransNodeID int64
 type TransNodeParam struct {
 	TId        TransNodeID
+	RootTId    TransNodeID
 	Tt         TransType
 	Ot         TransOwnerType
 	Tct        TransactCommitPolicy
@@ -69,6 +71,12 @@ type TransNodeParam struct {
 	AreaID     int
 	TimeOut    time.Duration
 	ExpiresTs  int64
+	//子事务失败时的处理策略
+	ChildPolicy TransChildPolicy
+	//TransChildPolicy_Quorum需要成功的子事务数量，0表示多数
+	Quorum int
+	//同时执行的子事务数量上限，0表示不限制
+	MaxChildInFlight int
 }
 
 type TransResult struct {
@@ -83,6 +91,11 @@ const (
 	TransStatsOp_Yiled
 	TransStatsOp_Resume
 	TransStatsOp_Timeout
+	TransStatsOp_SagaStep
+	TransStatsOp_Compensate
+	TransStatsOp_CompensateRetry
+	TransStatsOp_CompensateFailed
+	TransStatsOp_OutcomeConflict
 )
 
 type TransStats struct {
@@ -94,6 +107,13 @@ type TransStats struct {
 	ResumeTimes     int64
 	TotalRuningTime int64
 	MaxRuningTime   int64
+	//saga
+	SagaStepTimes         int64
+	CompensateTimes       int64
+	CompensateRetryTimes  int64
+	CompensateFailedTimes int64
+	//子节点结束的结果和已经结束的父节点不一致
+	OutcomeConflictTimes int64
 }
 
 func (stats *TransStats) incStats(op int) {
@@ -110,6 +130,16 @@ func (stats *TransStats) incStats(op int) {
 		atomic.AddInt64(&stats.ResumeTimes, 1)
 	case TransStatsOp_Timeout:
 		atomic.AddInt64(&stats.TimeoutTimes, 1)
+	case TransStatsOp_SagaStep:
+		atomic.AddInt64(&stats.SagaStepTimes, 1)
+	case TransStatsOp_Compensate:
+		atomic.AddInt64(&stats.CompensateTimes, 1)
+	case TransStatsOp_CompensateRetry:
+		atomic.AddInt64(&stats.CompensateRetryTimes, 1)
+	case TransStatsOp_CompensateFailed:
+		atomic.AddInt64(&stats.CompensateFailedTimes, 1)
+	case TransStatsOp_OutcomeConflict:
+		atomic.AddInt64(&stats.OutcomeConflictTimes, 1)
 	}
 }
 
@@ -123,6 +153,8 @@ type TransNode struct {
 	ownerObj     *basic.Object
 	Childs       map[TransNodeID]*TransNodeParam
 	finChild     map[TransNodeID]interface{}
+	failChild    map[TransNodeID]int
+	waitChilds   []*waitChild
 	timeHandle   timer.TimerHandle
 	handler      TransHandler
 	AsynCallback TransCallback
@@ -132,18 +164,26 @@ type TransNode struct {
 	yield        bool
 	resume       bool
 	done         bool
+	logged       bool
+	restored     bool
+	outcome      TransCmd
+	timeline     transTimeline
 	owner        *transactCoordinater
 	ud           interface{}
 }
 
 func (this *TransNode) incStats(op int) {
-	if s, exist := transStats.Load(this.MyTnp.Tt); exist {
+	incTransStats(this.MyTnp.Tt, op)
+}
+
+func incTransStats(tt TransType, op int) {
+	if s, exist := transStats.Load(tt); exist {
 		if stats, ok := s.(*TransStats); ok {
 			stats.incStats(op)
 		}
 	} else {
 		stats := &TransStats{}
-		transStats.Store(this.MyTnp.Tt, stats)
+		transStats.Store(tt, stats)
 		stats.incStats(op)
 	}
 }
@@ -170,6 +210,7 @@ func (this *TransNode) execute(ud interface{}) TransExeResult {
 	this.start = true
 	ret := this.handler.OnExcute(this, ud)
 	this.incStats(TransStatsOp_Exe)
+	this.trace(TransEvent_Executed, TransNodeIDNil, int(ret))
 	if ret == TransExeResult_Yield {
 		return this.Yield()
 	}
@@ -181,16 +222,20 @@ func (this *TransNode) doneExecRet(ter TransExeResult) TransExeResult {
 	if this.done {
 		return TransExeResult_HadDone
 	}
+	if ter == TransExeResult_Success && len(this.Childs) == len(this.finChild) && !this.childsSatisfied() {
+		ter = TransExeResult_Failed
+	}
 	if ter == TransExeResult_Success {
 		if len(this.Childs) == len(this.finChild) {
 			if this.MyTnp.LevelNo <= TransRootNodeLevel {
 				return this.commit()
 			} else {
+				this.logPrepare()
 				if Config.tcs != nil {
 					this.TransRep.RetCode = TransResult_Success
 					Config.tcs.SendTransResult(this.ParentTnp, this.MyTnp, this.TransRep)
 				}
-				if this.MyTnp.Tct == TransactCommitPolicy_SelfDecide {
+				if this.isSelfDecide() {
 					return this.commit()
 				}
 			}
@@ -228,16 +273,20 @@ func (this *TransNode) commit() TransExeResult {
 	defer this.notifyBrother(TransExeResult_Success)
 
 	this.done = true
+	this.outcome = TransCmd_Commit
+	this.trace(TransEvent_Commit, TransNodeIDNil, this.TransRep.RetCode)
+	this.logDecision(TransLogOp_Commit)
 	this.handler.OnCommit(this)
 	this.incStats(TransStatsOp_Commit)
 	this.statsRuningTime()
 	if len(this.Childs) > 0 && Config.tcs != nil {
-		for _, v := range this.Childs {
-			if v.Tct == TransactCommitPolicy_TwoPhase {
+		for k, v := range this.Childs {
+			if v.Tct == TransactCommitPolicy_TwoPhase && !this.skipChildCmd(k) {
 				Config.tcs.SendCmdToTransNode(v, TransCmd_Commit)
 			}
 		}
 	}
+	this.logEnd()
 
 	return TransExeResult_Success
 }
@@ -261,16 +310,20 @@ func (this *TransNode) rollback(exclude TransNodeID) TransExeResult {
 	defer this.notifyBrother(TransExeResult_Failed)
 
 	this.done = true
+	this.outcome = TransCmd_RollBack
+	this.trace(TransEvent_RollBack, exclude, this.TransRep.RetCode)
+	this.logDecision(TransLogOp_RollBack)
 	this.handler.OnRollBack(this)
 	this.incStats(TransStatsOp_Rollback)
 	this.statsRuningTime()
 	if len(this.Childs) > 0 && Config.tcs != nil {
 		for k, v := range this.Childs {
-			if k != exclude && v.Tct == TransactCommitPolicy_TwoPhase {
+			if k != exclude && v.Tct == TransactCommitPolicy_TwoPhase && !this.skipChildCmd(k) {
 				Config.tcs.SendCmdToTransNode(v, TransCmd_RollBack)
 			}
 		}
 	}
+	this.logEnd()
 
 	return TransExeResult_Success
 }
@@ -295,6 +348,7 @@ func (this *TransNode) timeout() TransExeResult {
 		}
 	}
 	this.incStats(TransStatsOp_Timeout)
+	this.trace(TransEvent_Timeout, TransNodeIDNil, TransResult_TimeOut)
 	this.rollback(TransNodeIDNil)
 	return TransExeResult_Success
 }
@@ -322,18 +376,24 @@ func (this *TransNode) childTransRep(child TransNodeID, retCode int, ud interfac
 		return TransExeResult_ChildNodeRepeateRet
 	}
 	this.finChild[child] = ud
+	this.trace(TransEvent_ChildResult, child, retCode)
 	ret := this.handler.OnChildTransRep(this, child, retCode, ud)
+	if this.MyTnp.ChildPolicy != TransChildPolicy_AllOrNothing {
+		return this.partialChildTransRep(child, retCode, ret)
+	}
 	if retCode == TransResult_Success && ret == TransExeResult_Success {
+		this.launchWaitChilds()
 		// the child nodes are returned and also run their own end (note: they may be executed asynchronously)
 		if len(this.Childs) == len(this.finChild) && this.yield == this.resume {
 			if this.MyTnp.LevelNo == TransRootNodeLevel {
 				this.commit()
 			} else {
+				this.logPrepare()
 				if Config.tcs != nil {
 					this.TransRep.RetCode = retCode
 					Config.tcs.SendTransResult(this.ParentTnp, this.MyTnp, this.TransRep)
 				}
-				if this.MyTnp.Tct == TransactCommitPolicy_SelfDecide {
+				if this.isSelfDecide() {
 					this.commit()
 				}
 			}
@@ -363,18 +423,69 @@ func (this *TransNode) StartChildTrans(tnp *TransNodeParam, ud interface{}, time
 	}
 
 	tnp.TId = this.owner.spawnTransNodeID()
-	tnp.TimeOut = timeout
-	tnp.ExpiresTs = time.Now().Add(timeout).UnixNano()
 	tnp.LevelNo = this.MyTnp.LevelNo + 1
+	tnp.RootTId = this.MyTnp.RootTId
 
 	if this.Childs == nil {
 		this.Childs = make(map[TransNodeID]*TransNodeParam)
 	}
 	this.Childs[tnp.TId] = tnp
+	if this.MyTnp.MaxChildInFlight > 0 && this.childInFlight() > this.MyTnp.MaxChildInFlight {
+		//超过并发上限，等有子事务返回后再发出
+		this.waitChilds = append(this.waitChilds, &waitChild{tnp: tnp, ud: ud, timeout: timeout})
+		return TransExeResult_Success
+	}
+	this.launchChildTrans(tnp, ud, timeout)
+	return TransExeResult_Success
+}
+
+func (this *TransNode) launchChildTrans(tnp *TransNodeParam, ud interface{}, timeout time.Duration) {
+	tnp.TimeOut = timeout
+	tnp.ExpiresTs = time.Now().Add(timeout).UnixNano()
+	this.trace(TransEvent_ChildStarted, tnp.TId, 0)
+	if tnp.Tct == TransactCommitPolicy_TwoPhase {
+		//先记录子事务，崩溃恢复时才能通知到它
+		this.appendLog(&TransLogRecord{Op: TransLogOp_ChildStart, Tnp: this.MyTnp, Parent: this.ParentTnp, Child: tnp})
+	}
 	if Config.tcs != nil {
 		Config.tcs.SendTransStart(this.MyTnp, tnp, ud)
 	}
-	return TransExeResult_Success
+}
+
+// isSelfDecide 执行成功后自己提交，不等待父节点的决定
+func (this *TransNode) isSelfDecide() bool {
+	return this.MyTnp.Tct == TransactCommitPolicy_SelfDecide || this.MyTnp.Tct == TransactCommitPolicy_Saga
+}
+
+// appendLog 记录事务日志，节点一旦有记录，之后的决定和结束也都会记录
+func (this *TransNode) appendLog(r *TransLogRecord) {
+	r.TId = this.MyTnp.TId
+	if this.owner.appendLog(r) {
+		this.logged = true
+	}
+}
+
+// logPrepare 两阶段提交的参与者执行成功，在返回结果之前记录
+func (this *TransNode) logPrepare() {
+	if this.MyTnp.Tct == TransactCommitPolicy_TwoPhase {
+		this.appendLog(&TransLogRecord{Op: TransLogOp_Prepare, Tnp: this.MyTnp, Parent: this.ParentTnp})
+	}
+}
+
+func (this *TransNode) logDecision(op int) {
+	if !this.logged {
+		return
+	}
+	this.appendLog(&TransLogRecord{Op: op})
+	if op == TransLogOp_Commit {
+		this.owner.addDecision(this.MyTnp.TId, TransCmd_Commit, time.Now())
+	}
+}
+
+func (this *TransNode) logEnd() {
+	if this.logged {
+		this.appendLog(&TransLogRecord{Op: TransLogOp_End})
+	}
 }
 
 func (this *TransNode) GetChildTransParam(childid TransNodeID) *TransNodeParam {
@@ -386,6 +497,7 @@ func (this *TransNode) GetChildTransParam(childid TransNodeID) *TransNodeParam {
 
 func (this *TransNode) Yield() TransExeResult {
 	this.yield = true
+	this.trace(TransEvent_Yielded, TransNodeIDNil, 0)
 	SendTranscatYield(this)
 	this.incStats(TransStatsOp_Yiled)
 	return TransExeResult_Success
@@ -393,11 +505,18 @@ func (this *TransNode) Yield() TransExeResult {
 
 func (this *TransNode) Resume() TransExeResult {
 	this.resume = true
+	this.trace(TransEvent_Resumed, TransNodeIDNil, this.TransRep.RetCode)
 	SendTranscatResume(this)
 	this.incStats(TransStatsOp_Resume)
 	return TransExeResult_Success
 }
 
+// Reyield 在AsynCallback中再次挂起，等待下一次Resume，例如saga的下一个异步步骤
+func (this *TransNode) Reyield() TransExeResult {
+	this.resume = false
+	return this.Yield()
+}
+
 func (this *TransNode) Go(obj *basic.Object) TransExeResult {
 	this.ownerObj = obj
 	return this.execute(this.ud)
@@ -407,6 +526,10 @@ func (this *TransNode) checkExeOver() {
 	if this.resume == this.yield {
 		if this.AsynCallback != nil {
 			this.AsynCallback(this)
+			//回调中可能调用Reyield再次挂起
+			if this.resume != this.yield {
+				return
+			}
 		}
 		if this.done == false {
 			var ter TransExeResult
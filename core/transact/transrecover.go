@@ -0,0 +1,304 @@
+// transrecover
+package transact
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/acoderup/goserver.v1/core/timer"
+)
+
+// TransactQuerySkeleton 可选接口，参与者恢复后向父节点查询事务的结果
+// 父节点通过ProcessTransQuery处理查询，并用SendCmdToTransNode回复决定
+type TransactQuerySkeleton interface {
+	SendTransQuery(parent, me *TransNodeParam) bool
+}
+
+type transDecision struct {
+	cmd TransCmd
+	ts  time.Time
+}
+
+// transLogState 根据日志还原出的节点状态
+type transLogState struct {
+	tid      TransNodeID
+	tnp      *TransNodeParam
+	parent   *TransNodeParam
+	childs   map[TransNodeID]*TransNodeParam
+	prepared bool
+	decision TransCmd
+	decideTs int64
+	ended    bool
+	records  []*TransLogRecord
+}
+
+// analyzeTransLog 按节点汇总日志记录
+func analyzeTransLog(records []*TransLogRecord) map[TransNodeID]*transLogState {
+	states := make(map[TransNodeID]*transLogState)
+	for _, r := range records {
+		st, exist := states[r.TId]
+		if !exist {
+			st = &transLogState{tid: r.TId, childs: make(map[TransNodeID]*TransNodeParam)}
+			states[r.TId] = st
+		}
+		st.records = append(st.records, r)
+		if r.Tnp != nil {
+			st.tnp = r.Tnp
+		}
+		if r.Parent != nil {
+			st.parent = r.Parent
+		}
+		switch r.Op {
+		case TransLogOp_ChildStart:
+			if r.Child != nil {
+				st.childs[r.Child.TId] = r.Child
+			}
+		case TransLogOp_Prepare:
+			st.prepared = true
+		case TransLogOp_Commit:
+			st.decision, st.decideTs = TransCmd_Commit, r.Ts
+		case TransLogOp_RollBack:
+			st.decision, st.decideTs = TransCmd_RollBack, r.Ts
+		case TransLogOp_End:
+			st.ended = true
+		}
+	}
+	return states
+}
+
+// recover 重放事务日志，处理崩溃前没有结束的事务
+// 已经做出决定的：重新向子节点发送决定
+// 已经prepare但没有收到决定的参与者：恢复节点并向父节点查询
+// 其它情况：按presumed abort回滚所有子节点
+func (this *transactCoordinater) recover() {
+	tlog := Config.tlog
+	if tlog == nil {
+		return
+	}
+	records, err := tlog.Load()
+	if err != nil {
+		logger.Logger.Errorf("transactCoordinater.recover load translog error: %v", err)
+		return
+	}
+	if len(records) == 0 {
+		return
+	}
+	states := analyzeTransLog(records)
+	tids := make([]TransNodeID, 0, len(states))
+	for tid := range states {
+		tids = append(tids, tid)
+	}
+	sort.Slice(tids, func(i, j int) bool { return tids[i] < tids[j] })
+
+	nowTime := time.Now()
+	var keep []*TransLogRecord
+	var resolved, restored int
+	for _, tid := range tids {
+		st := states[tid]
+		if st.decision == TransCmd_Commit && nowTime.Sub(time.Unix(0, st.decideTs)) < Config.DecisionRetention {
+			//保留提交的决定，用于回答恢复后的参与者的查询
+			this.addDecision(tid, TransCmd_Commit, time.Unix(0, st.decideTs))
+		}
+		if st.ended {
+			if st.decision == TransCmd_Commit && nowTime.Sub(time.Unix(0, st.decideTs)) < Config.DecisionRetention {
+				keep = append(keep, st.records...)
+			}
+			continue
+		}
+		switch {
+		case st.decision != TransCmd_Invalid:
+			logger.Logger.Infof("transactCoordinater.recover resend %v to childs of %v", st.decision, tid)
+			this.sendCmdToChilds(st.childs, st.decision, TransNodeIDNil)
+			if st.decision == TransCmd_Commit {
+				keep = append(keep, st.records...)
+				keep = append(keep, &TransLogRecord{Op: TransLogOp_End, TId: tid, Ts: nowTime.UnixNano()})
+			}
+			resolved++
+		case st.prepared && st.tnp != nil:
+			if this.restoreTransNode(st) {
+				keep = append(keep, st.records...)
+				restored++
+				continue
+			}
+			fallthrough
+		default:
+			logger.Logger.Infof("transactCoordinater.recover presumed abort %v", tid)
+			this.sendCmdToChilds(st.childs, TransCmd_RollBack, TransNodeIDNil)
+			resolved++
+		}
+	}
+	this.compactKept = len(keep)
+	if err = tlog.Rewrite(keep); err != nil {
+		logger.Logger.Warnf("transactCoordinater.recover rewrite translog error: %v", err)
+	}
+	logger.Logger.Infof("transactCoordinater.recover done, records=%v resolved=%v indoubt=%v", len(records), resolved, restored)
+}
+
+// restoreTransNode 恢复处于不确定状态的参与者，等待父节点的决定，超时后回滚
+func (this *transactCoordinater) restoreTransNode(st *transLogState) bool {
+	handler := GetHandler(st.tnp.Tt)
+	if handler == nil {
+		logger.Logger.Warnf("transactCoordinater.restoreTransNode handler not found, tnp=%v", *st.tnp)
+		return false
+	}
+	tnode := &TransNode{
+		MyTnp:      st.tnp,
+		ParentTnp:  st.parent,
+		handler:    handler,
+		owner:      this,
+		TransRep:   &TransResult{RetCode: TransResult_Success},
+		TransEnv:   NewTransCtx(),
+		createTime: time.Now(),
+		ownerObj:   core.CoreObject(),
+		start:      true,
+		logged:     true,
+		restored:   true,
+	}
+	if len(st.childs) > 0 {
+		tnode.Childs = st.childs
+		tnode.finChild = make(map[TransNodeID]interface{})
+		for childId := range st.childs {
+			tnode.finChild[childId] = nil
+		}
+	}
+	this.addTransNode(tnode)
+	if h, ok := timer.StartTimer(tta, tnode, Config.RecoverTimeout, 1); ok {
+		tnode.timeHandle = h
+	}
+	logger.Logger.Infof("transactCoordinater.restoreTransNode in doubt %v, parent=%v", st.tid, st.parent)
+	if qs, ok := Config.tcs.(TransactQuerySkeleton); ok && st.parent != nil {
+		qs.SendTransQuery(st.parent, st.tnp)
+	}
+	return true
+}
+
+// ProcessTransQuery 子节点查询事务结果，正在进行中的事务不回复，结束后会正常发送决定
+// 只有在提交的决定一定还保留着时才按presumed abort回答回滚：本节点记录了事务日志，
+// 并且子节点创建的时间在DecisionRetention之内(父节点的决定不会早于子节点创建)；
+// 否则结果未知，不回复，参与者保持不确定状态直到RecoverTimeout
+func (this *transactCoordinater) ProcessTransQuery(parentTid TransNodeID, child *TransNodeParam) bool {
+	if Config.tcs == nil || child == nil {
+		return false
+	}
+	if tnode := this.getTransNode(parentTid); tnode != nil {
+		return true
+	}
+	cmd := TransCmd_Invalid
+	if d, exist := this.getDecision(parentTid); exist {
+		cmd = d
+	} else if c := this.getCompleted(parentTid); c != nil {
+		cmd = c.outcome
+	} else if this.presumeAbort(child) {
+		cmd = TransCmd_RollBack
+	}
+	if cmd == TransCmd_Invalid {
+		logger.Logger.Warnf("transactCoordinater.ProcessTransQuery parent=%v child=%v outcome unknown, decision may have expired", parentTid, child.TId)
+		return false
+	}
+	logger.Logger.Infof("transactCoordinater.ProcessTransQuery parent=%v child=%v answer=%v", parentTid, child.TId, cmd)
+	return Config.tcs.SendCmdToTransNode(child, cmd)
+}
+
+// presumeAbort 没有找到决定时能否认为事务已经回滚
+func (this *transactCoordinater) presumeAbort(child *TransNodeParam) bool {
+	if Config.tlog == nil || child.ExpiresTs == 0 {
+		return false
+	}
+	createTs := time.Unix(0, child.ExpiresTs).Add(-child.TimeOut)
+	return time.Since(createTs) < Config.DecisionRetention
+}
+
+// compactTransLog 压缩事务日志：去掉已经结束的事务，提交的决定保留DecisionRetention时间
+// 日志没有新的记录并且上次压缩后没有保留的记录时跳过
+// 状态按读到的记录分析，之后追加的记录所属的事务在分析结果中不会是已结束的，压缩时保留
+func (this *transactCoordinater) compactTransLog() {
+	tlog := Config.tlog
+	if tlog == nil {
+		return
+	}
+	if atomic.SwapInt64(&this.logAppends, 0) == 0 && this.compactKept == 0 {
+		return
+	}
+	records, err := tlog.Load()
+	if err != nil {
+		logger.Logger.Warnf("transactCoordinater.compactTransLog load translog error: %v", err)
+		return
+	}
+	states := analyzeTransLog(records)
+	nowTime := time.Now()
+	var total, kept int
+	err = tlog.Compact(func(r *TransLogRecord) bool {
+		total++
+		st, exist := states[r.TId]
+		if !exist || !st.ended || st.decision == TransCmd_Commit && nowTime.Sub(time.Unix(0, st.decideTs)) < Config.DecisionRetention {
+			kept++
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		logger.Logger.Warnf("transactCoordinater.compactTransLog compact translog error: %v", err)
+		return
+	}
+	this.compactKept = kept
+	if kept != total {
+		logger.Logger.Tracef("transactCoordinater.compactTransLog records=%v keep=%v", total, kept)
+	}
+}
+
+func (this *transactCoordinater) appendLog(r *TransLogRecord) bool {
+	if Config.tlog == nil {
+		return false
+	}
+	if err := Config.tlog.Append(r); err != nil {
+		logger.Logger.Errorf("transactCoordinater.appendLog op=%v tid=%v error: %v", r.Op, r.TId, err)
+		return false
+	}
+	atomic.AddInt64(&this.logAppends, 1)
+	return true
+}
+
+func (this *transactCoordinater) sendCmdToChilds(childs map[TransNodeID]*TransNodeParam, cmd TransCmd, exclude TransNodeID) {
+	if Config.tcs == nil {
+		return
+	}
+	for k, v := range childs {
+		if k != exclude && v.Tct == TransactCommitPolicy_TwoPhase {
+			Config.tcs.SendCmdToTransNode(v, cmd)
+		}
+	}
+}
+
+// addDecision 记录提交的决定，只保留DecisionRetention时间内的
+func (this *transactCoordinater) addDecision(tid TransNodeID, cmd TransCmd, ts time.Time) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.decisions == nil {
+		this.decisions = make(map[TransNodeID]*transDecision)
+	}
+	this.decisions[tid] = &transDecision{cmd: cmd, ts: ts}
+	if nowTime := time.Now(); nowTime.Sub(this.lastPrune) >= time.Minute {
+		this.lastPrune = nowTime
+		for k, d := range this.decisions {
+			if nowTime.Sub(d.ts) >= Config.DecisionRetention {
+				delete(this.decisions, k)
+			}
+		}
+	}
+}
+
+func (this *transactCoordinater) getDecision(tid TransNodeID) (TransCmd, bool) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if d, exist := this.decisions[tid]; exist && time.Since(d.ts) < Config.DecisionRetention {
+		return d.cmd, true
+	}
+	return TransCmd_Invalid, false
+}
+
+func ProcessTransQuery(parentTid TransNodeID, child *TransNodeParam) bool {
+	return DTCModule.ProcessTransQuery(parentTid, child)
+}
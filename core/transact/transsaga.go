@@ -0,0 +1,204 @@
+// transsaga
+package transact
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/acoderup/goserver.v1/core/timer"
+)
+
+const DefaultSagaRetryInterval = time.Second
+
+type sagaCtxKey struct{}
+
+// SagaAction 执行步骤，返回TransExeResult_Yield表示异步执行，完成后设置TransRep.RetCode并调用Resume
+type SagaAction func(n *TransNode, ud interface{}) TransExeResult
+
+// SagaCompensate 补偿步骤，返回失败时按RetryInterval重试
+type SagaCompensate func(n *TransNode) TransExeResult
+
+type SagaStep struct {
+	Name       string
+	Action     SagaAction
+	Compensate SagaCompensate
+}
+
+// Saga 按顺序执行的步骤，某一步失败(或者事务超时)时逆序补偿已经完成的步骤
+// 步骤之间通过TransNode.TransEnv共享数据
+type Saga struct {
+	Steps []*SagaStep
+	//单个补偿步骤的最大重试次数，<=0表示一直重试
+	MaxRetry int
+	//补偿失败的重试间隔
+	RetryInterval time.Duration
+	//saga结束的回调，committed为false表示已经补偿完成
+	OnDone func(n *TransNode, committed bool)
+}
+
+// RegisteSaga 注册saga类型的事务，启动时TransNodeParam.Tct应设置为TransactCommitPolicy_Saga
+func RegisteSaga(tt TransType, saga *Saga) {
+	if saga == nil || len(saga.Steps) == 0 {
+		panic(fmt.Sprintf("RegisteSaga empty saga, type=%v", tt))
+	}
+	for i, step := range saga.Steps {
+		if step.Action == nil {
+			panic(fmt.Sprintf("RegisteSaga step %v(%v) has no action, type=%v", i, step.Name, tt))
+		}
+	}
+	if saga.RetryInterval <= 0 {
+		saga.RetryInterval = DefaultSagaRetryInterval
+	}
+	RegisteHandler(tt, &sagaHandler{saga: saga})
+}
+
+type sagaState struct {
+	saga *Saga
+	ud   interface{}
+	//已经完成的步骤数
+	next    int
+	pending bool
+	retry   int
+}
+
+func getSagaState(n *TransNode) *sagaState {
+	if st, ok := n.TransEnv.GetField(sagaCtxKey{}).(*sagaState); ok {
+		return st
+	}
+	return nil
+}
+
+// run 从下一个步骤开始顺序执行
+func (st *sagaState) run(n *TransNode) TransExeResult {
+	for st.next < len(st.saga.Steps) {
+		step := st.saga.Steps[st.next]
+		ret := step.Action(n, st.ud)
+		n.incStats(TransStatsOp_SagaStep)
+		switch ret {
+		case TransExeResult_Success:
+			st.next++
+		case TransExeResult_Yield:
+			st.pending = true
+			return TransExeResult_Yield
+		default:
+			logger.Logger.Warnf("saga %v step %v(%v) failed: %v", n.MyTnp.TId, st.next, step.Name, ret)
+			n.TransRep.RetCode = TransResult_Failed
+			return ret
+		}
+	}
+	return TransExeResult_Success
+}
+
+// onAsyncDone 异步步骤完成，继续执行后续步骤
+func (st *sagaState) onAsyncDone(n *TransNode) {
+	if !st.pending {
+		return
+	}
+	st.pending = false
+	if n.done {
+		//事务已经超时回滚，迟到的成功步骤也需要补偿
+		if n.TransRep.RetCode == TransResult_Success {
+			st.next++
+			st.compensate(n, st.next-1, st.next-1, false)
+		}
+		return
+	}
+	if n.TransRep.RetCode != TransResult_Success {
+		return
+	}
+	st.next++
+	switch st.run(n) {
+	case TransExeResult_Yield:
+		n.Reyield()
+	case TransExeResult_Success:
+	default:
+		n.TransRep.RetCode = TransResult_Failed
+	}
+}
+
+// compensate 从idx开始逆序补偿到stop，补偿失败时启动定时器重试，notify表示完成后回调OnDone
+func (st *sagaState) compensate(n *TransNode, idx, stop int, notify bool) {
+	for ; idx >= stop; idx-- {
+		step := st.saga.Steps[idx]
+		if step.Compensate == nil {
+			continue
+		}
+		ret := step.Compensate(n)
+		n.incStats(TransStatsOp_Compensate)
+		if ret == TransExeResult_Success {
+			st.retry = 0
+			continue
+		}
+		st.retry++
+		if st.saga.MaxRetry > 0 && st.retry > st.saga.MaxRetry {
+			logger.Logger.Errorf("saga %v compensate step %v(%v) failed after %v retries, give up", n.MyTnp.TId, idx, step.Name, st.saga.MaxRetry)
+			n.incStats(TransStatsOp_CompensateFailed)
+			st.retry = 0
+			continue
+		}
+		logger.Logger.Warnf("saga %v compensate step %v(%v) failed: %v, retry %v after %v", n.MyTnp.TId, idx, step.Name, ret, st.retry, st.saga.RetryInterval)
+		n.incStats(TransStatsOp_CompensateRetry)
+		timer.StartTimer(sagaRetryTimer, &sagaRetry{n: n, st: st, idx: idx, stop: stop, notify: notify}, st.saga.RetryInterval, 1)
+		return
+	}
+	if notify && st.saga.OnDone != nil {
+		st.saga.OnDone(n, false)
+	}
+}
+
+type sagaRetry struct {
+	n      *TransNode
+	st     *sagaState
+	idx    int
+	stop   int
+	notify bool
+}
+
+type sagaRetryTimerAction struct {
+}
+
+var sagaRetryTimer = &sagaRetryTimerAction{}
+
+func (t *sagaRetryTimerAction) OnTimer(h timer.TimerHandle, ud interface{}) bool {
+	if r, ok := ud.(*sagaRetry); ok {
+		r.st.compensate(r.n, r.idx, r.stop, r.notify)
+		return true
+	}
+	return false
+}
+
+type sagaHandler struct {
+	saga *Saga
+}
+
+func (h *sagaHandler) OnExcute(n *TransNode, ud interface{}) TransExeResult {
+	st := &sagaState{saga: h.saga, ud: ud}
+	n.TransEnv.SetField(sagaCtxKey{}, st)
+	n.AsynCallback = st.onAsyncDone
+	return st.run(n)
+}
+
+func (h *sagaHandler) OnCommit(n *TransNode) TransExeResult {
+	if h.saga.OnDone != nil {
+		h.saga.OnDone(n, true)
+	}
+	return TransExeResult_Success
+}
+
+func (h *sagaHandler) OnRollBack(n *TransNode) TransExeResult {
+	st := getSagaState(n)
+	if st == nil {
+		return TransExeResult_Success
+	}
+	if st.next > 0 {
+		st.compensate(n, st.next-1, 0, true)
+	} else if h.saga.OnDone != nil {
+		h.saga.OnDone(n, false)
+	}
+	return TransExeResult_Success
+}
+
+func (h *sagaHandler) OnChildTransRep(n *TransNode, hChild TransNodeID, retCode int, ud interface{}) TransExeResult {
+	return TransExeResult_Success
+}
@@ -0,0 +1,166 @@
+package transact
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/timer"
+)
+
+func TestMain(m *testing.M) {
+	//事务和定时器都依赖core object
+	core.AppCtx.CoreObj = basic.NewObject(core.ObjId_CoreId, "core", basic.Options{MaxDone: 1024, QueueBacklog: 1024}, nil)
+	core.LaunchChild(core.AppCtx.CoreObj)
+	timer.Config.Init()
+	os.Exit(m.Run())
+}
+
+// runOnCore 在core object中执行并等待完成
+func runOnCore(f func()) {
+	done := make(chan struct{})
+	core.CoreObject().SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		f()
+		close(done)
+		return nil
+	}), true)
+	<-done
+}
+
+type sagaRecorder struct {
+	lock sync.Mutex
+	logs []string
+	done chan bool
+}
+
+func (r *sagaRecorder) add(s string) {
+	r.lock.Lock()
+	r.logs = append(r.logs, s)
+	r.lock.Unlock()
+}
+
+func (r *sagaRecorder) String() string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return strings.Join(r.logs, ",")
+}
+
+func (r *sagaRecorder) wait(t *testing.T) bool {
+	select {
+	case committed := <-r.done:
+		return committed
+	case <-time.After(3 * time.Second):
+		t.Fatalf("wait saga done timeout, logs=%v", r)
+	}
+	return false
+}
+
+func (r *sagaRecorder) step(name string, ret TransExeResult) *SagaStep {
+	return &SagaStep{
+		Name: name,
+		Action: func(n *TransNode, ud interface{}) TransExeResult {
+			r.add(name)
+			return ret
+		},
+		Compensate: func(n *TransNode) TransExeResult {
+			r.add("undo-" + name)
+			return TransExeResult_Success
+		},
+	}
+}
+
+func startSaga(tt TransType) {
+	runOnCore(func() {
+		tnode := DTCModule.StartTrans(&TransNodeParam{Tt: tt, Tct: TransactCommitPolicy_Saga}, nil, time.Minute)
+		tnode.Go(core.CoreObject())
+	})
+}
+
+var sagaTypeSeq TransType = 1000
+
+// newSagaRecorder 每次注册新的事务类型，保证测试可以重复执行
+func newSagaRecorder(saga *Saga, steps func(r *sagaRecorder) []*SagaStep) (*sagaRecorder, TransType) {
+	sagaTypeSeq++
+	tt := sagaTypeSeq
+	r := &sagaRecorder{done: make(chan bool, 1)}
+	saga.Steps = steps(r)
+	saga.OnDone = func(n *TransNode, committed bool) { r.done <- committed }
+	RegisteSaga(tt, saga)
+	return r, tt
+}
+
+func TestSagaCommit(t *testing.T) {
+	r, tt := newSagaRecorder(&Saga{}, func(r *sagaRecorder) []*SagaStep {
+		return []*SagaStep{r.step("purchase", TransExeResult_Success), r.step("grant", TransExeResult_Success), r.step("notify", TransExeResult_Success)}
+	})
+	startSaga(tt)
+	if !r.wait(t) || r.String() != "purchase,grant,notify" {
+		t.Fatalf("saga commit logs=%v", r)
+	}
+}
+
+func TestSagaCompensate(t *testing.T) {
+	r, tt := newSagaRecorder(&Saga{}, func(r *sagaRecorder) []*SagaStep {
+		return []*SagaStep{r.step("purchase", TransExeResult_Success), r.step("grant", TransExeResult_Success), r.step("notify", TransExeResult_Failed)}
+	})
+	startSaga(tt)
+	if r.wait(t) || r.String() != "purchase,grant,notify,undo-grant,undo-purchase" {
+		t.Fatalf("saga compensate logs=%v", r)
+	}
+}
+
+func TestSagaCompensateRetry(t *testing.T) {
+	failures := 2
+	r, tt := newSagaRecorder(&Saga{RetryInterval: 10 * time.Millisecond}, func(r *sagaRecorder) []*SagaStep {
+		purchase := r.step("purchase", TransExeResult_Success)
+		purchase.Compensate = func(n *TransNode) TransExeResult {
+			if failures > 0 {
+				failures--
+				r.add("undo-failed")
+				return TransExeResult_Failed
+			}
+			r.add("undo-purchase")
+			return TransExeResult_Success
+		}
+		return []*SagaStep{purchase, r.step("grant", TransExeResult_Failed)}
+	})
+	startSaga(tt)
+	if r.wait(t) || r.String() != "purchase,grant,undo-failed,undo-failed,undo-purchase" {
+		t.Fatalf("saga retry logs=%v", r)
+	}
+	if stats := Stats()[int(tt)]; stats.CompensateRetryTimes != 2 || stats.CompensateTimes != 3 || stats.RollbackTimes != 1 {
+		t.Fatalf("saga stats=%+v", stats)
+	}
+}
+
+func TestSagaAsyncStep(t *testing.T) {
+	pending := make(chan *TransNode, 1)
+	r, tt := newSagaRecorder(&Saga{}, func(r *sagaRecorder) []*SagaStep {
+		grant := r.step("grant", TransExeResult_Success)
+		grant.Action = func(n *TransNode, ud interface{}) TransExeResult {
+			r.add("grant-async")
+			pending <- n
+			return TransExeResult_Yield
+		}
+		return []*SagaStep{r.step("purchase", TransExeResult_Success), grant, r.step("notify", TransExeResult_Success)}
+	})
+	startSaga(tt)
+	var n *TransNode
+	select {
+	case n = <-pending:
+	case <-time.After(3 * time.Second):
+		t.Fatalf("async step not started, logs=%v", r)
+	}
+	runOnCore(func() {
+		n.TransRep.RetCode = TransResult_Success
+		n.Resume()
+	})
+	if !r.wait(t) || r.String() != "purchase,grant-async,notify" {
+		t.Fatalf("saga async logs=%v", r)
+	}
+}
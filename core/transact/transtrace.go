@@ -0,0 +1,283 @@
+// transtrace
+package transact
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	TransEvent_Created int = iota
+	TransEvent_Executed
+	TransEvent_Yielded
+	TransEvent_Resumed
+	TransEvent_ChildStarted
+	TransEvent_ChildResult
+	TransEvent_Commit
+	TransEvent_RollBack
+	TransEvent_Timeout
+)
+
+const (
+	maxTraceEvents       = 64
+	DefaultTraceHistory  = 1024
+	DefaultFailedHistory = 64
+)
+
+var transEventNames = []string{"created", "executed", "yielded", "resumed", "child_started", "child_result", "commit", "rollback", "timeout"}
+
+// TransEvent 事务节点时间线上的一个事件
+type TransEvent struct {
+	Type int
+	Ts   time.Time
+	TId  TransNodeID
+	//子节点事件对应的子节点id
+	Peer TransNodeID
+	//执行结果或者子节点返回的结果
+	Code int
+}
+
+func (e TransEvent) Name() string {
+	if e.Type >= 0 && e.Type < len(transEventNames) {
+		return transEventNames[e.Type]
+	}
+	return "unknown"
+}
+
+// TransNodeTrace 事务节点的时间线快照，Childs按节点id排序
+// 子节点在其它服务器上时只有ChildStarted事件中的信息，Remote为true
+type TransNodeTrace struct {
+	TId        TransNodeID
+	ParentTId  TransNodeID
+	RootTId    TransNodeID
+	Tt         TransType
+	LevelNo    int
+	AreaID     int
+	SkeletonID int
+	Remote     bool
+	Finished   bool
+	Result     string
+	Events     []TransEvent
+	Childs     []*TransNodeTrace
+}
+
+// transTimeline 节点的事件记录，节点只在core object中访问，查询可能来自其它协程
+type transTimeline struct {
+	lock     sync.Mutex
+	events   []TransEvent
+	dropped  int
+	finished bool
+}
+
+func (this *TransNode) trace(typ int, peer TransNodeID, code int) {
+	tl := &this.timeline
+	tl.lock.Lock()
+	defer tl.lock.Unlock()
+	if len(tl.events) >= maxTraceEvents {
+		//保留第一条创建事件，丢弃最早的其它事件
+		copy(tl.events[1:], tl.events[2:])
+		tl.events = tl.events[:len(tl.events)-1]
+		tl.dropped++
+	}
+	tl.events = append(tl.events, TransEvent{Type: typ, Ts: time.Now(), TId: this.MyTnp.TId, Peer: peer, Code: code})
+}
+
+func (this *TransNode) snapshotTrace() *TransNodeTrace {
+	t := &TransNodeTrace{
+		TId:        this.MyTnp.TId,
+		RootTId:    this.MyTnp.RootTId,
+		Tt:         this.MyTnp.Tt,
+		LevelNo:    this.MyTnp.LevelNo,
+		AreaID:     this.MyTnp.AreaID,
+		SkeletonID: this.MyTnp.SkeletonID,
+	}
+	if this.ParentTnp != nil {
+		t.ParentTId = this.ParentTnp.TId
+	}
+	tl := &this.timeline
+	tl.lock.Lock()
+	t.Events = append([]TransEvent(nil), tl.events...)
+	t.Finished = tl.finished
+	tl.lock.Unlock()
+	t.Result = traceResult(t.Events, t.Finished)
+	return t
+}
+
+func traceResult(events []TransEvent, finished bool) string {
+	result := "running"
+	for _, e := range events {
+		switch e.Type {
+		case TransEvent_Commit:
+			result = "committed"
+		case TransEvent_RollBack:
+			if result != "timeout" {
+				result = "rollback"
+			}
+		case TransEvent_Timeout:
+			result = "timeout"
+		}
+	}
+	if finished && result == "running" {
+		result = "finished"
+	}
+	return result
+}
+
+// transTracer 保存已经结束的节点的时间线，以及失败事务的历史
+type transTracer struct {
+	lock     sync.Mutex
+	finished map[TransNodeID][]*TransNodeTrace
+	order    []*TransNodeTrace
+	failed   []*TransNodeTrace
+}
+
+func (this *transTracer) onFinished(t *TransNodeTrace) {
+	limit := Config.TraceHistory
+	if limit <= 0 {
+		limit = DefaultTraceHistory
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.finished == nil {
+		this.finished = make(map[TransNodeID][]*TransNodeTrace)
+	}
+	this.finished[t.RootTId] = append(this.finished[t.RootTId], t)
+	this.order = append(this.order, t)
+	for len(this.order) > limit {
+		old := this.order[0]
+		this.order = this.order[1:]
+		traces := this.finished[old.RootTId]
+		for i, v := range traces {
+			if v == old {
+				traces = append(traces[:i], traces[i+1:]...)
+				break
+			}
+		}
+		if len(traces) == 0 {
+			delete(this.finished, old.RootTId)
+		} else {
+			this.finished[old.RootTId] = traces
+		}
+	}
+}
+
+func (this *transTracer) onFailed(tree *TransNodeTrace) {
+	limit := Config.FailedHistory
+	if limit <= 0 {
+		limit = DefaultFailedHistory
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.failed = append(this.failed, tree)
+	if len(this.failed) > limit {
+		this.failed = this.failed[len(this.failed)-limit:]
+	}
+}
+
+func (this *transTracer) getFinished(root TransNodeID) []*TransNodeTrace {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	return append([]*TransNodeTrace(nil), this.finished[root]...)
+}
+
+func (this *transTracer) getFailed() []*TransNodeTrace {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	failed := make([]*TransNodeTrace, 0, len(this.failed))
+	for i := len(this.failed) - 1; i >= 0; i-- {
+		failed = append(failed, this.failed[i])
+	}
+	return failed
+}
+
+// traceFinish 节点释放时保存时间线，本地的顶层节点失败时保存整棵树
+func (this *transactCoordinater) traceFinish(tnode *TransNode) {
+	tl := &tnode.timeline
+	tl.lock.Lock()
+	if tl.finished {
+		tl.lock.Unlock()
+		return
+	}
+	tl.finished = true
+	tl.lock.Unlock()
+
+	t := tnode.snapshotTrace()
+	this.tracer.onFinished(t)
+	if t.Result != "committed" && t.Result != "finished" {
+		if tnode.MyTnp.LevelNo <= TransRootNodeLevel || tnode.ParentTnp == nil || this.getTransNode(tnode.ParentTnp.TId) == nil {
+			if tree := this.GetTransTrace(t.RootTId); tree != nil {
+				this.tracer.onFailed(tree)
+			}
+		}
+	}
+}
+
+// GetTransTrace 获取根节点为root的事务树，包括正在执行的和最近结束的本地节点
+func (this *transactCoordinater) GetTransTrace(root TransNodeID) *TransNodeTrace {
+	nodes := make(map[TransNodeID]*TransNodeTrace)
+	for _, t := range this.tracer.getFinished(root) {
+		nodes[t.TId] = t
+	}
+	this.lock.Lock()
+	var live []*TransNode
+	for _, tnode := range this.transPool {
+		if tnode.MyTnp.RootTId == root {
+			live = append(live, tnode)
+		}
+	}
+	this.lock.Unlock()
+	for _, tnode := range live {
+		nodes[tnode.MyTnp.TId] = tnode.snapshotTrace()
+	}
+	if len(nodes) == 0 {
+		return nil
+	}
+	return buildTraceTree(root, nodes)
+}
+
+// buildTraceTree 按父子关系组装树，不在本地的子节点根据ChildStarted事件补充为远程节点
+func buildTraceTree(root TransNodeID, nodes map[TransNodeID]*TransNodeTrace) *TransNodeTrace {
+	all := make(map[TransNodeID]*TransNodeTrace, len(nodes))
+	for tid, t := range nodes {
+		c := *t
+		c.Childs = nil
+		all[tid] = &c
+	}
+	for _, t := range nodes {
+		for _, e := range t.Events {
+			if e.Type == TransEvent_ChildStarted {
+				if _, exist := all[e.Peer]; !exist {
+					all[e.Peer] = &TransNodeTrace{TId: e.Peer, ParentTId: t.TId, RootTId: root, LevelNo: t.LevelNo + 1, Remote: true, Result: "remote"}
+				}
+			}
+		}
+	}
+	var top []*TransNodeTrace
+	for _, t := range all {
+		if parent, exist := all[t.ParentTId]; exist && t.TId != root {
+			parent.Childs = append(parent.Childs, t)
+		} else {
+			top = append(top, t)
+		}
+	}
+	for _, t := range all {
+		sort.Slice(t.Childs, func(i, j int) bool { return t.Childs[i].TId < t.Childs[j].TId })
+	}
+	if t, exist := all[root]; exist {
+		return t
+	}
+	//根节点在其它服务器上，返回本地的子树
+	sort.Slice(top, func(i, j int) bool { return top[i].TId < top[j].TId })
+	return &TransNodeTrace{TId: root, RootTId: root, Remote: true, Result: "remote", Childs: top}
+}
+
+// GetTransTrace 获取事务树，可以在任意协程中调用
+func GetTransTrace(root TransNodeID) *TransNodeTrace {
+	return DTCModule.GetTransTrace(root)
+}
+
+// GetFailedTrans 获取最近失败的事务，最新的在前
+func GetFailedTrans() []*TransNodeTrace {
+	return DTCModule.tracer.getFailed()
+}
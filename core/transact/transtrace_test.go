@@ -0,0 +1,87 @@
+package transact
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/cmdline"
+)
+
+func waitTrace(t *testing.T, root TransNodeID, result string) *TransNodeTrace {
+	deadline := time.Now().Add(3 * time.Second)
+	for time.Now().Before(deadline) {
+		if tr := GetTransTrace(root); tr != nil && tr.Finished && tr.Result == result {
+			return tr
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("wait trace %v result %v timeout, trace=%+v", root, result, GetTransTrace(root))
+	return nil
+}
+
+func TestTransTraceTimeline(t *testing.T) {
+	roots := make(chan TransNodeID, 1)
+	saga := &Saga{}
+	r, tt := newSagaRecorder(saga, func(r *sagaRecorder) []*SagaStep {
+		return []*SagaStep{r.step("purchase", TransExeResult_Success)}
+	})
+	old := saga.OnDone
+	saga.OnDone = func(n *TransNode, committed bool) {
+		roots <- n.MyTnp.RootTId
+		old(n, committed)
+	}
+	startSaga(tt)
+	if !r.wait(t) {
+		t.Fatalf("saga rollback, logs=%v", r)
+	}
+	root := <-roots
+	tr := waitTrace(t, root, "committed")
+	if tr.LevelNo != TransRootNodeLevel || tr.RootTId != tr.TId {
+		t.Fatalf("unexpected root trace %+v", tr)
+	}
+	if len(tr.Events) < 3 || tr.Events[0].Type != TransEvent_Created || tr.Events[len(tr.Events)-1].Type != TransEvent_Commit {
+		t.Fatalf("unexpected events %+v", tr.Events)
+	}
+	res := cmdline.NewAdminServer().Exec(fmt.Sprintf("trans trace -root=%v", root))
+	if res.Error != "" || !strings.Contains(res.Data.(*cmdline.Table).String(), "committed") {
+		t.Fatalf("trans trace command error=%v data=%v", res.Error, res.Data)
+	}
+}
+
+func TestTransTraceFailedHistory(t *testing.T) {
+	roots := make(chan TransNodeID, 1)
+	saga := &Saga{}
+	r, tt := newSagaRecorder(saga, func(r *sagaRecorder) []*SagaStep {
+		return []*SagaStep{r.step("purchase", TransExeResult_Success), r.step("grant", TransExeResult_Failed)}
+	})
+	old := saga.OnDone
+	saga.OnDone = func(n *TransNode, committed bool) {
+		roots <- n.MyTnp.RootTId
+		old(n, committed)
+	}
+	startSaga(tt)
+	if r.wait(t) {
+		t.Fatalf("saga should rollback, logs=%v", r)
+	}
+	root := <-roots
+	waitTrace(t, root, "rollback")
+	for _, f := range GetFailedTrans() {
+		if f.TId == root {
+			return
+		}
+	}
+	t.Fatalf("failed trans %v not in history", root)
+}
+
+func TestBuildTraceTreeRemote(t *testing.T) {
+	nodes := map[TransNodeID]*TransNodeTrace{
+		1: {TId: 1, RootTId: 1, Events: []TransEvent{{Type: TransEvent_ChildStarted, Peer: 3}}},
+		2: {TId: 2, ParentTId: 1, RootTId: 1, LevelNo: 1},
+	}
+	tree := buildTraceTree(1, nodes)
+	if len(tree.Childs) != 2 || tree.Childs[0].TId != 2 || !tree.Childs[1].Remote {
+		t.Fatalf("unexpected tree %+v", tree.Childs)
+	}
+}
@@ -0,0 +1,181 @@
+// transtyped
+package transact
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core/logger"
+)
+
+// TypedHandler 带类型的事务处理器
+// Req为事务启动参数的类型，Rep为子事务返回结果的类型，Ctx为保存在TransEnv中的事务上下文类型
+// 参数类型不匹配时不会调用回调，记录错误日志后按失败处理
+type TypedHandler[Req, Rep, Ctx any] struct {
+	OnExcute        func(n *TransNode, ctx *Ctx, req Req) TransExeResult
+	OnCommit        func(n *TransNode, ctx *Ctx) TransExeResult
+	OnRollBack      func(n *TransNode, ctx *Ctx) TransExeResult
+	OnChildTransRep func(n *TransNode, ctx *Ctx, hChild TransNodeID, retCode int, rep Rep) TransExeResult
+}
+
+var (
+	typedReqLock sync.RWMutex
+	//typedReqTypes 记录带类型的事务的启动参数类型，本地启动时提前检查
+	typedReqTypes = make(map[TransType]reflect.Type)
+)
+
+// TypeMismatchError 事务的启动参数或子事务结果类型不匹配，Got为nil表示没有数据
+type TypeMismatchError struct {
+	Tt   TransType
+	What string
+	Want reflect.Type
+	Got  reflect.Type
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("transact type=%v %v type mismatch, want %v got %v", e.Tt, e.What, e.Want, e.Got)
+}
+
+// RegisteTypedHandler 注册带类型的事务处理器，适配到RegisteHandler
+// 跨服务器传递的Req和Rep需要通过txrpc.RegisteUserData注册
+func RegisteTypedHandler[Req, Rep, Ctx any](tt TransType, h *TypedHandler[Req, Rep, Ctx]) {
+	if h == nil || h.OnExcute == nil {
+		panic(fmt.Sprintf("RegisteTypedHandler OnExcute is nil, type=%v", tt))
+	}
+	RegisteHandler(tt, &typedHandlerAdapter[Req, Rep, Ctx]{h: h})
+	typedReqLock.Lock()
+	typedReqTypes[tt] = typeOf[Req]()
+	typedReqLock.Unlock()
+}
+
+type typedCtxKey[Ctx any] struct{}
+
+// CtxOf 获取节点的类型化上下文，不存在时创建
+func CtxOf[Ctx any](n *TransNode) *Ctx {
+	if ctx, ok := n.TransEnv.GetField(typedCtxKey[Ctx]{}).(*Ctx); ok {
+		return ctx
+	}
+	ctx := new(Ctx)
+	n.TransEnv.SetField(typedCtxKey[Ctx]{}, ctx)
+	return ctx
+}
+
+// CtxKey 类型化的TransCtx键，Get不会因为类型断言失败而静默返回零值
+type CtxKey[T any] struct {
+	name string
+}
+
+func NewCtxKey[T any](name string) CtxKey[T] {
+	return CtxKey[T]{name: name}
+}
+
+func (k CtxKey[T]) Set(tc *TransCtx, v T) {
+	tc.SetField(k, v)
+}
+
+// Get 键不存在时exist为false，类型不匹配时返回错误
+func (k CtxKey[T]) Get(tc *TransCtx) (v T, exist bool, err error) {
+	f := tc.GetField(k)
+	if f == nil {
+		return v, false, nil
+	}
+	v, ok := f.(T)
+	if !ok {
+		return v, true, fmt.Errorf("TransCtx key %v type mismatch, want %T got %T", k.name, v, f)
+	}
+	return v, true, nil
+}
+
+// StartTypedTrans 启动带类型参数的事务
+func StartTypedTrans[Req any](tnp *TransNodeParam, req Req, timeout time.Duration) (*TransNode, error) {
+	if err := checkTypedReq[Req](tnp.Tt); err != nil {
+		return nil, err
+	}
+	return DTCModule.StartTrans(tnp, req, timeout), nil
+}
+
+// StartTypedChildTrans 启动带类型参数的子事务，本地注册过的事务类型会检查参数类型
+func StartTypedChildTrans[Req any](n *TransNode, tnp *TransNodeParam, req Req, timeout time.Duration) TransExeResult {
+	if err := checkTypedReq[Req](tnp.Tt); err != nil {
+		logger.Logger.Error(err)
+		return TransExeResult_TypeMismatch
+	}
+	return n.StartChildTrans(tnp, req, timeout)
+}
+
+func checkTypedReq[Req any](tt TransType) error {
+	typedReqLock.RLock()
+	want, exist := typedReqTypes[tt]
+	typedReqLock.RUnlock()
+	if !exist {
+		return nil
+	}
+	if got := typeOf[Req](); got != want {
+		return &TypeMismatchError{Tt: tt, What: "request", Want: want, Got: got}
+	}
+	return nil
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// castUserData 类型不匹配时返回TypeMismatchError，nil只能转换为接口类型
+func castUserData[T any](tt TransType, what string, ud interface{}) (v T, err error) {
+	want := typeOf[T]()
+	if ud == nil {
+		if want.Kind() == reflect.Interface {
+			return v, nil
+		}
+		return v, &TypeMismatchError{Tt: tt, What: what, Want: want}
+	}
+	v, ok := ud.(T)
+	if !ok {
+		return v, &TypeMismatchError{Tt: tt, What: what, Want: want, Got: reflect.TypeOf(ud)}
+	}
+	return v, nil
+}
+
+type typedHandlerAdapter[Req, Rep, Ctx any] struct {
+	h *TypedHandler[Req, Rep, Ctx]
+}
+
+func (this *typedHandlerAdapter[Req, Rep, Ctx]) OnExcute(n *TransNode, ud interface{}) TransExeResult {
+	req, err := castUserData[Req](n.MyTnp.Tt, "request", ud)
+	if err != nil {
+		logger.Logger.Errorf("tid=%v %v", n.MyTnp.TId, err)
+		return TransExeResult_Failed
+	}
+	return this.h.OnExcute(n, CtxOf[Ctx](n), req)
+}
+
+func (this *typedHandlerAdapter[Req, Rep, Ctx]) OnCommit(n *TransNode) TransExeResult {
+	if this.h.OnCommit != nil {
+		return this.h.OnCommit(n, CtxOf[Ctx](n))
+	}
+	return TransExeResult_Success
+}
+
+func (this *typedHandlerAdapter[Req, Rep, Ctx]) OnRollBack(n *TransNode) TransExeResult {
+	if this.h.OnRollBack != nil {
+		return this.h.OnRollBack(n, CtxOf[Ctx](n))
+	}
+	return TransExeResult_Success
+}
+
+func (this *typedHandlerAdapter[Req, Rep, Ctx]) OnChildTransRep(n *TransNode, hChild TransNodeID, retCode int, ud interface{}) TransExeResult {
+	if this.h.OnChildTransRep == nil {
+		return TransExeResult_Success
+	}
+	rep, err := castUserData[Rep](n.MyTnp.Tt, "result", ud)
+	if err != nil {
+		//失败的子事务可能没有返回结果，只有成功的结果类型不匹配才按失败处理
+		if retCode == TransResult_Success {
+			logger.Logger.Errorf("tid=%v child=%v %v", n.MyTnp.TId, hChild, err)
+			return TransExeResult_Failed
+		}
+	}
+	return this.h.OnChildTransRep(n, CtxOf[Ctx](n), hChild, retCode, rep)
+}
@@ -0,0 +1,98 @@
+package transact
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type typedReq struct {
+	Gold int
+}
+
+type typedCtx struct {
+	Gold int
+}
+
+func TestTypedHandler(t *testing.T) {
+	sagaTypeSeq++
+	tt := sagaTypeSeq
+	done := make(chan int, 1)
+	RegisteTypedHandler(tt, &TypedHandler[*typedReq, int, typedCtx]{
+		OnExcute: func(n *TransNode, ctx *typedCtx, req *typedReq) TransExeResult {
+			ctx.Gold = req.Gold
+			return TransExeResult_Success
+		},
+		OnCommit: func(n *TransNode, ctx *typedCtx) TransExeResult {
+			done <- ctx.Gold
+			return TransExeResult_Success
+		},
+		OnRollBack: func(n *TransNode, ctx *typedCtx) TransExeResult {
+			done <- -1
+			return TransExeResult_Success
+		},
+	})
+
+	var mismatch *TypeMismatchError
+	if _, err := StartTypedTrans(&TransNodeParam{Tt: tt}, typedReq{Gold: 1}, time.Minute); !errors.As(err, &mismatch) || mismatch.What != "request" {
+		t.Fatalf("request type mismatch not detected, err=%v", err)
+	}
+
+	start := func(ud interface{}) int {
+		runOnCore(func() {
+			tnode := DTCModule.StartTrans(&TransNodeParam{Tt: tt, Tct: TransactCommitPolicy_SelfDecide}, ud, time.Minute)
+			tnode.Go(nil)
+		})
+		select {
+		case gold := <-done:
+			return gold
+		case <-time.After(3 * time.Second):
+			t.Fatal("wait typed trans timeout")
+		}
+		return 0
+	}
+	if gold := start(&typedReq{Gold: 100}); gold != 100 {
+		t.Fatalf("typed trans commit gold=%v", gold)
+	}
+	//未经检查的启动参数类型不匹配时回滚
+	if gold := start("100"); gold != -1 {
+		t.Fatalf("typed trans with wrong request should rollback, gold=%v", gold)
+	}
+	//非接口类型的启动参数不能是nil
+	if gold := start(nil); gold != -1 {
+		t.Fatalf("typed trans with nil request should rollback, gold=%v", gold)
+	}
+}
+
+func TestCastUserData(t *testing.T) {
+	if v, err := castUserData[interface{}](1, "request", nil); err != nil || v != nil {
+		t.Fatalf("cast nil to interface v=%v err=%v", v, err)
+	}
+	var mismatch *TypeMismatchError
+	if _, err := castUserData[*typedReq](1, "request", nil); !errors.As(err, &mismatch) || mismatch.Got != nil {
+		t.Fatalf("cast nil to pointer err=%v", err)
+	}
+	if _, err := castUserData[int](1, "result", "1"); !errors.As(err, &mismatch) || mismatch.Got.Kind() != reflect.String {
+		t.Fatalf("cast string to int err=%v", err)
+	}
+	if v, err := castUserData[int](1, "result", 1); err != nil || v != 1 {
+		t.Fatalf("cast int v=%v err=%v", v, err)
+	}
+}
+
+func TestCtxKey(t *testing.T) {
+	tc := NewTransCtx()
+	key := NewCtxKey[int]("gold")
+	if _, exist, _ := key.Get(tc); exist {
+		t.Fatal("empty ctx key exist")
+	}
+	key.Set(tc, 10)
+	if v, exist, err := key.Get(tc); !exist || err != nil || v != 10 {
+		t.Fatalf("ctx key get v=%v exist=%v err=%v", v, exist, err)
+	}
+	tc.SetField(key, "10")
+	if _, _, err := key.Get(tc); err == nil {
+		t.Fatal("ctx key type mismatch not detected")
+	}
+}
@@ -0,0 +1,118 @@
+package txrpc
+
+import (
+	"errors"
+	"sync"
+)
+
+var ErrPeerUnreachable = errors.New("txrpc peer unreachable")
+
+// Channel 节点间的消息通道，Send失败的消息由Skeleton负责重发
+// 通道收到消息后调用Skeleton.OnReceive，连接(重新)建立后调用Skeleton.OnPeerConnected
+type Channel interface {
+	Open(sk *Skeleton) error
+	Send(to NodeAddr, data []byte) error
+	Close()
+}
+
+// LoopbackChannel 所有消息都投递给自己，用于单进程部署和测试
+type LoopbackChannel struct {
+	sk *Skeleton
+}
+
+func NewLoopbackChannel() *LoopbackChannel {
+	return &LoopbackChannel{}
+}
+
+func (c *LoopbackChannel) Open(sk *Skeleton) error {
+	c.sk = sk
+	return nil
+}
+
+func (c *LoopbackChannel) Send(to NodeAddr, data []byte) error {
+	c.sk.OnReceive(data)
+	return nil
+}
+
+func (c *LoopbackChannel) Close() {}
+
+// Hub 进程内的消息交换，多个Skeleton通过Hub互相通信，用于模拟多节点部署
+type Hub struct {
+	lock  sync.RWMutex
+	nodes map[NodeAddr]*Skeleton
+	down  map[NodeAddr]bool
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		nodes: make(map[NodeAddr]*Skeleton),
+		down:  make(map[NodeAddr]bool),
+	}
+}
+
+// Channel 为一个节点创建接入Hub的通道
+func (h *Hub) Channel() Channel {
+	return &hubChannel{hub: h}
+}
+
+// SetDown 模拟节点断线，恢复时通知其它节点重发
+func (h *Hub) SetDown(addr NodeAddr, down bool) {
+	h.lock.Lock()
+	h.down[addr] = down
+	var peers []*Skeleton
+	if !down {
+		for a, sk := range h.nodes {
+			if a != addr {
+				peers = append(peers, sk)
+			}
+		}
+	}
+	h.lock.Unlock()
+	for _, sk := range peers {
+		sk.OnPeerConnected(addr)
+	}
+}
+
+func (h *Hub) deliver(to NodeAddr, data []byte) error {
+	h.lock.RLock()
+	sk := h.nodes[to]
+	down := h.down[to]
+	h.lock.RUnlock()
+	if sk == nil || down {
+		return ErrPeerUnreachable
+	}
+	sk.OnReceive(data)
+	return nil
+}
+
+type hubChannel struct {
+	hub  *Hub
+	addr NodeAddr
+}
+
+func (c *hubChannel) Open(sk *Skeleton) error {
+	c.addr = sk.Addr()
+	c.hub.lock.Lock()
+	defer c.hub.lock.Unlock()
+	if _, exist := c.hub.nodes[c.addr]; exist {
+		return errors.New("txrpc hub node already exist: " + c.addr.String())
+	}
+	c.hub.nodes[c.addr] = sk
+	return nil
+}
+
+func (c *hubChannel) Send(to NodeAddr, data []byte) error {
+	c.hub.lock.RLock()
+	down := c.hub.down[c.addr]
+	c.hub.lock.RUnlock()
+	if down {
+		return ErrPeerUnreachable
+	}
+	return c.hub.deliver(to, data)
+}
+
+func (c *hubChannel) Close() {
+	c.hub.lock.Lock()
+	delete(c.hub.nodes, c.addr)
+	c.hub.lock.Unlock()
+}
@@ -0,0 +1,81 @@
+package txrpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/netlib"
+	"github.com/acoderup/goserver.v1/core/transact"
+)
+
+const (
+	SkeletonName = "txrpc"
+
+	Mode_Loopback = "loopback"
+	Mode_Net      = "net"
+)
+
+var (
+	Config = Configuration{}
+	//DefaultSkeleton 注册到transact的默认实现，配置tx.TxSkeletonName为txrpc即可启用
+	DefaultSkeleton = NewSkeleton(0, 0, nil)
+)
+
+type Configuration struct {
+	AreaID     int
+	SkeletonID int
+	//loopback或者net，默认为net
+	Mode   string
+	Listen netlib.SessionConfig
+	Peers  []Peer
+	//重发间隔(毫秒)
+	ResendInterval time.Duration
+	//事务过期后消息继续保留的时间(毫秒)
+	MsgTTL time.Duration
+}
+
+func (c *Configuration) Name() string {
+	return "txrpc"
+}
+
+func (c *Configuration) Init() error {
+	if c.AreaID < 0 || c.AreaID > 0xffff || c.SkeletonID < 0 || c.SkeletonID > 0xffff {
+		return fmt.Errorf("txrpc AreaID(%v) and SkeletonID(%v) must be in [0,65535]", c.AreaID, c.SkeletonID)
+	}
+	DefaultSkeleton.addr = NodeAddr{AreaID: c.AreaID, SkeletonID: c.SkeletonID}
+	if c.ResendInterval > 0 {
+		DefaultSkeleton.ResendInterval = time.Millisecond * c.ResendInterval
+	}
+	if c.MsgTTL > 0 {
+		DefaultSkeleton.MsgTTL = time.Millisecond * c.MsgTTL
+	}
+	switch c.Mode {
+	case Mode_Loopback:
+		DefaultSkeleton.ch = NewLoopbackChannel()
+	case Mode_Net, "":
+		DefaultSkeleton.ch = NewNetChannel(c.Listen, c.Peers...)
+	default:
+		return fmt.Errorf("txrpc mode [%v] not supported", c.Mode)
+	}
+	return nil
+}
+
+func (c *Configuration) Close() error {
+	return nil
+}
+
+func init() {
+	core.RegistePackage(&Config)
+	transact.RegisteTxCommSkeleton(SkeletonName, DefaultSkeleton)
+	core.RegisteHook(core.HOOK_BEFORE_START, func() error {
+		if DefaultSkeleton.ch == nil {
+			return nil
+		}
+		return DefaultSkeleton.Open()
+	})
+	core.RegisteHook(core.HOOK_AFTER_STOP, func() error {
+		DefaultSkeleton.Close()
+		return nil
+	})
+}
@@ -0,0 +1,77 @@
+package txrpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/acoderup/goserver.v1/core/transact"
+)
+
+const (
+	msgKind_TransStart int = iota + 1
+	msgKind_TransResult
+	msgKind_TransCmd
+	msgKind_TransQuery
+	msgKind_Ack
+)
+
+// NodeAddr 节点地址，对应TransNodeID中的AreaID和SkeletonID
+type NodeAddr struct {
+	AreaID     int
+	SkeletonID int
+}
+
+func (a NodeAddr) String() string {
+	return fmt.Sprintf("%v-%v", a.AreaID, a.SkeletonID)
+}
+
+// AddrOfTid 从事务节点id中解出所在节点的地址，与spawnTransNodeID的位布局一致
+// area(16bit) | skeleton(16bit) | id(32bit)
+func AddrOfTid(tid transact.TransNodeID) NodeAddr {
+	return NodeAddr{
+		AreaID:     int(int64(tid) >> 48 & 0xffff),
+		SkeletonID: int(int64(tid) >> 32 & 0xffff),
+	}
+}
+
+// addrOfParam 事务节点参数所在的节点，参数中没有填写地址时从id中解出
+func addrOfParam(tnp *transact.TransNodeParam) NodeAddr {
+	if tnp.AreaID != 0 || tnp.SkeletonID != 0 {
+		return NodeAddr{AreaID: tnp.AreaID, SkeletonID: tnp.SkeletonID}
+	}
+	return AddrOfTid(tnp.TId)
+}
+
+type rpcMessage struct {
+	Kind   int
+	From   NodeAddr
+	Epoch  int64
+	Seq    uint64
+	Parent *transact.TransNodeParam
+	Me     *transact.TransNodeParam
+	Result *transact.TransResult
+	Cmd    transact.TransCmd
+	Ud     interface{}
+}
+
+// RegisteUserData 注册事务启动参数和返回结果中用到的具体类型，跨节点传递前必须注册
+func RegisteUserData(v interface{}) {
+	gob.Register(v)
+}
+
+func encodeMessage(msg *rpcMessage) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(msg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeMessage(data []byte) (*rpcMessage, error) {
+	msg := &rpcMessage{}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
@@ -0,0 +1,160 @@
+package txrpc
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/netlib"
+)
+
+var netChannelSeq int32
+
+// Peer 对端节点，SessionConfig中的Id、Ip、Port等由使用者填写
+type Peer struct {
+	AreaID     int
+	SkeletonID int
+	netlib.SessionConfig
+}
+
+// NetChannel 基于netlib tcp连接的消息通道
+// 本节点监听Listen，并主动连接每个对端；消息和确认都通过主动连接发出，因此对端之间需要互相配置
+type NetChannel struct {
+	Listen     netlib.SessionConfig
+	lock       sync.Mutex
+	sk         *Skeleton
+	handler    string
+	acceptor   *netlib.Acceptor
+	peers      map[int]NodeAddr
+	connectors map[NodeAddr]*netlib.Connector
+	//Open之前配置的对端
+	pendingPeers []Peer
+}
+
+func NewNetChannel(listen netlib.SessionConfig, peers ...Peer) *NetChannel {
+	c := &NetChannel{
+		Listen:     listen,
+		peers:      make(map[int]NodeAddr),
+		connectors: make(map[NodeAddr]*netlib.Connector),
+	}
+	c.pendingPeers = peers
+	return c
+}
+
+func (c *NetChannel) Open(sk *Skeleton) error {
+	c.sk = sk
+	c.handler = fmt.Sprintf("txrpc_%v_%v", sk.Addr(), atomic.AddInt32(&netChannelSeq, 1))
+	netlib.RegisteSessionHandler(c.handler, &netlib.SessionHandlerWrapper{
+		OnSessionOpenedWrapper:  c.onSessionOpened,
+		OnPacketReceivedWrapper: c.onPacketReceived,
+	})
+	c.Listen.IsClient = false
+	c.Listen.Codec = netlib.DefaultCodecName
+	c.Listen.Handler = c.handler
+	a, err := netlib.NetModule.Listen(&c.Listen)
+	if err != nil {
+		return err
+	}
+	c.acceptor = a
+	peers := c.pendingPeers
+	c.pendingPeers = nil
+	for _, p := range peers {
+		if err = c.AddPeer(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddPeer 增加对端节点，可以在运行时调用
+func (c *NetChannel) AddPeer(p Peer) error {
+	addr := NodeAddr{AreaID: p.AreaID, SkeletonID: p.SkeletonID}
+	sc := p.SessionConfig
+	sc.IsClient = true
+	sc.IsAutoReconn = true
+	sc.Codec = netlib.DefaultCodecName
+	sc.Handler = c.handler
+	//连接建立时按id查找对端，未指定id时在连接之前分配
+	if sc.Id == 0 {
+		sc.Id = core.NextObjId()
+	}
+	c.lock.Lock()
+	if _, exist := c.peers[sc.Id]; exist {
+		c.lock.Unlock()
+		return fmt.Errorf("peer [%v] id [%v] already in use", sc.Name, sc.Id)
+	}
+	c.peers[sc.Id] = addr
+	c.lock.Unlock()
+	conn, err := netlib.NetModule.Connect(&sc)
+	if err != nil {
+		c.lock.Lock()
+		delete(c.peers, sc.Id)
+		c.lock.Unlock()
+		return err
+	}
+	c.lock.Lock()
+	c.connectors[addr] = conn
+	c.lock.Unlock()
+	return nil
+}
+
+// ListenAddr 实际监听的地址
+func (c *NetChannel) ListenAddr() string {
+	if c.acceptor == nil {
+		return ""
+	}
+	return c.acceptor.Addr().String()
+}
+
+func (c *NetChannel) Send(to NodeAddr, data []byte) error {
+	if to == c.sk.Addr() {
+		c.sk.OnReceive(data)
+		return nil
+	}
+	c.lock.Lock()
+	conn := c.connectors[to]
+	c.lock.Unlock()
+	if conn == nil || !conn.Send(data) {
+		return ErrPeerUnreachable
+	}
+	return nil
+}
+
+func (c *NetChannel) Close() {
+	c.lock.Lock()
+	var ids []int
+	for id := range c.peers {
+		ids = append(ids, id)
+	}
+	c.connectors = make(map[NodeAddr]*netlib.Connector)
+	c.lock.Unlock()
+	for _, id := range ids {
+		netlib.NetModule.StopService(id)
+	}
+	if c.acceptor != nil {
+		netlib.NetModule.StopService(c.Listen.Id)
+	}
+	if c.handler != "" {
+		netlib.UnregisteSessionHandler(c.handler)
+	}
+}
+
+func (c *NetChannel) onSessionOpened(s *netlib.Session) {
+	sc := s.GetSessionConfig()
+	if !sc.IsClient {
+		return
+	}
+	c.lock.Lock()
+	addr, exist := c.peers[sc.Id]
+	c.lock.Unlock()
+	if exist {
+		c.sk.OnPeerConnected(addr)
+	}
+}
+
+func (c *NetChannel) onPacketReceived(s *netlib.Session, pack interface{}) {
+	if data, ok := pack.([]byte); ok {
+		c.sk.OnReceive(data)
+	}
+}
@@ -0,0 +1,304 @@
+package txrpc
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/logger"
+	"github.com/acoderup/goserver.v1/core/transact"
+)
+
+const (
+	DefaultResendInterval = time.Second
+	DefaultMsgTTL         = time.Minute
+)
+
+type pendingMessage struct {
+	to       NodeAddr
+	data     []byte
+	lastSend time.Time
+	deadline time.Time
+}
+
+type peerEpoch struct {
+	addr  NodeAddr
+	epoch int64
+}
+
+// Skeleton 基于节点间消息通道的TransactCommSkeleton实现
+// 发出的消息在收到确认前会一直重发，直到对应的事务过期；接收方按(节点,启动时间,序号)去重
+// 收到的事务消息投递到Executor(默认为core object)中处理
+type Skeleton struct {
+	addr           NodeAddr
+	ch             Channel
+	epoch          int64
+	seq            uint64
+	Executor       *basic.Object
+	ResendInterval time.Duration
+	MsgTTL         time.Duration
+	lock           sync.Mutex
+	pending        map[uint64]*pendingMessage
+	seen           map[peerEpoch]map[uint64]time.Time
+	quit           chan struct{}
+	opened         int32
+	//收到的消息的处理函数，默认交给DTCModule
+	dispatch func(msg *rpcMessage)
+}
+
+func NewSkeleton(areaId, skeletonId int, ch Channel) *Skeleton {
+	return &Skeleton{
+		addr:           NodeAddr{AreaID: areaId, SkeletonID: skeletonId},
+		ch:             ch,
+		epoch:          time.Now().UnixNano(),
+		ResendInterval: DefaultResendInterval,
+		MsgTTL:         DefaultMsgTTL,
+		pending:        make(map[uint64]*pendingMessage),
+		seen:           make(map[peerEpoch]map[uint64]time.Time),
+		dispatch:       dispatchToDTC,
+	}
+}
+
+func (sk *Skeleton) Addr() NodeAddr {
+	return sk.addr
+}
+
+func (sk *Skeleton) GetAreaID() int {
+	return sk.addr.AreaID
+}
+
+func (sk *Skeleton) GetSkeletonID() int {
+	return sk.addr.SkeletonID
+}
+
+// Open 打开消息通道并启动重发协程
+func (sk *Skeleton) Open() error {
+	if !atomic.CompareAndSwapInt32(&sk.opened, 0, 1) {
+		return nil
+	}
+	if err := sk.ch.Open(sk); err != nil {
+		atomic.StoreInt32(&sk.opened, 0)
+		return err
+	}
+	sk.quit = make(chan struct{})
+	go sk.resendRoutine()
+	logger.Logger.Infof("txrpc skeleton [%v] opened", sk.addr)
+	return nil
+}
+
+func (sk *Skeleton) Close() {
+	if !atomic.CompareAndSwapInt32(&sk.opened, 1, 0) {
+		return
+	}
+	close(sk.quit)
+	sk.ch.Close()
+	sk.lock.Lock()
+	if n := len(sk.pending); n > 0 {
+		logger.Logger.Warnf("txrpc skeleton [%v] closed with %v unacked messages", sk.addr, n)
+	}
+	sk.lock.Unlock()
+}
+
+// PendingCount 还未收到确认的消息数量
+func (sk *Skeleton) PendingCount() int {
+	sk.lock.Lock()
+	defer sk.lock.Unlock()
+	return len(sk.pending)
+}
+
+func (sk *Skeleton) SendTransStart(parent, me *transact.TransNodeParam, ud interface{}) bool {
+	return sk.send(addrOfParam(me), &rpcMessage{Kind: msgKind_TransStart, Parent: parent, Me: me, Ud: ud}, me.ExpiresTs)
+}
+
+func (sk *Skeleton) SendTransResult(parent, me *transact.TransNodeParam, tr *transact.TransResult) bool {
+	if parent == nil {
+		return false
+	}
+	return sk.send(addrOfParam(parent), &rpcMessage{Kind: msgKind_TransResult, Parent: parent, Me: me, Result: tr}, parent.ExpiresTs)
+}
+
+func (sk *Skeleton) SendCmdToTransNode(tnp *transact.TransNodeParam, cmd transact.TransCmd) bool {
+	return sk.send(addrOfParam(tnp), &rpcMessage{Kind: msgKind_TransCmd, Me: tnp, Cmd: cmd}, tnp.ExpiresTs)
+}
+
+// SendTransQuery 恢复的参与者向父节点查询事务结果，实现transact.TransactQuerySkeleton
+func (sk *Skeleton) SendTransQuery(parent, me *transact.TransNodeParam) bool {
+	if parent == nil {
+		return false
+	}
+	return sk.send(addrOfParam(parent), &rpcMessage{Kind: msgKind_TransQuery, Parent: parent, Me: me}, 0)
+}
+
+func (sk *Skeleton) send(to NodeAddr, msg *rpcMessage, expiresTs int64) bool {
+	msg.From = sk.addr
+	msg.Epoch = sk.epoch
+	msg.Seq = atomic.AddUint64(&sk.seq, 1)
+	data, err := encodeMessage(msg)
+	if err != nil {
+		logger.Logger.Errorf("txrpc skeleton [%v] encode message to %v error: %v", sk.addr, to, err)
+		return false
+	}
+	now := time.Now()
+	deadline := now
+	if expiresTs > 0 {
+		if expires := time.Unix(0, expiresTs); expires.After(deadline) {
+			deadline = expires
+		}
+	}
+	sk.lock.Lock()
+	sk.pending[msg.Seq] = &pendingMessage{
+		to:       to,
+		data:     data,
+		lastSend: now,
+		deadline: deadline.Add(sk.MsgTTL),
+	}
+	sk.lock.Unlock()
+	if err = sk.ch.Send(to, data); err != nil {
+		logger.Logger.Tracef("txrpc skeleton [%v] send to %v failed, will resend: %v", sk.addr, to, err)
+	}
+	return true
+}
+
+// OnReceive 通道收到消息，可以在任意协程中调用
+func (sk *Skeleton) OnReceive(data []byte) {
+	msg, err := decodeMessage(data)
+	if err != nil {
+		logger.Logger.Warnf("txrpc skeleton [%v] decode message error: %v", sk.addr, err)
+		return
+	}
+	if msg.Kind == msgKind_Ack {
+		sk.lock.Lock()
+		delete(sk.pending, msg.Seq)
+		sk.lock.Unlock()
+		return
+	}
+	//重复的消息也要确认，对方可能没有收到之前的确认
+	sk.ack(msg)
+	if sk.isDuplicate(msg) {
+		return
+	}
+	executor := sk.Executor
+	if executor == nil {
+		executor = core.CoreObject()
+	}
+	if executor == nil {
+		logger.Logger.Warnf("txrpc skeleton [%v] no executor, drop message from %v", sk.addr, msg.From)
+		return
+	}
+	executor.SendCommand(basic.CommandWrapper(func(o *basic.Object) error {
+		defer o.ProcessSeqnum()
+		sk.dispatch(msg)
+		return nil
+	}), true)
+}
+
+// OnPeerConnected 与对端的连接(重新)建立，立即重发发往它的消息
+func (sk *Skeleton) OnPeerConnected(addr NodeAddr) {
+	sk.resend(func(pm *pendingMessage) bool { return pm.to == addr })
+}
+
+func (sk *Skeleton) ack(msg *rpcMessage) {
+	data, err := encodeMessage(&rpcMessage{Kind: msgKind_Ack, From: sk.addr, Epoch: msg.Epoch, Seq: msg.Seq})
+	if err != nil {
+		return
+	}
+	if err = sk.ch.Send(msg.From, data); err != nil {
+		logger.Logger.Tracef("txrpc skeleton [%v] ack %v:%v failed: %v", sk.addr, msg.From, msg.Seq, err)
+	}
+}
+
+func (sk *Skeleton) isDuplicate(msg *rpcMessage) bool {
+	key := peerEpoch{addr: msg.From, epoch: msg.Epoch}
+	sk.lock.Lock()
+	defer sk.lock.Unlock()
+	seqs, exist := sk.seen[key]
+	if !exist {
+		seqs = make(map[uint64]time.Time)
+		sk.seen[key] = seqs
+	}
+	if _, exist = seqs[msg.Seq]; exist {
+		return true
+	}
+	seqs[msg.Seq] = time.Now().Add(sk.MsgTTL + time.Minute)
+	return false
+}
+
+func (sk *Skeleton) resendRoutine() {
+	ticker := time.NewTicker(sk.ResendInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			sk.resend(func(pm *pendingMessage) bool { return now.Sub(pm.lastSend) >= sk.ResendInterval })
+			sk.purge(now)
+		case <-sk.quit:
+			return
+		}
+	}
+}
+
+func (sk *Skeleton) resend(filter func(pm *pendingMessage) bool) {
+	now := time.Now()
+	var msgs []*pendingMessage
+	sk.lock.Lock()
+	for seq, pm := range sk.pending {
+		if now.After(pm.deadline) {
+			logger.Logger.Warnf("txrpc skeleton [%v] message %v to %v expired, drop it", sk.addr, seq, pm.to)
+			delete(sk.pending, seq)
+			continue
+		}
+		if filter(pm) {
+			pm.lastSend = now
+			msgs = append(msgs, pm)
+		}
+	}
+	sk.lock.Unlock()
+	for _, pm := range msgs {
+		if err := sk.ch.Send(pm.to, pm.data); err != nil {
+			logger.Logger.Tracef("txrpc skeleton [%v] resend to %v failed: %v", sk.addr, pm.to, err)
+		}
+	}
+}
+
+// purge 清理过期的去重记录
+func (sk *Skeleton) purge(now time.Time) {
+	sk.lock.Lock()
+	defer sk.lock.Unlock()
+	for key, seqs := range sk.seen {
+		for seq, expire := range seqs {
+			if now.After(expire) {
+				delete(seqs, seq)
+			}
+		}
+		if len(seqs) == 0 {
+			delete(sk.seen, key)
+		}
+	}
+}
+
+func dispatchToDTC(msg *rpcMessage) {
+	switch msg.Kind {
+	case msgKind_TransStart:
+		if !transact.ProcessTransStart(msg.Parent, msg.Me, msg.Ud, msg.Me.TimeOut) {
+			logger.Logger.Warnf("txrpc ProcessTransStart failed, parent=%v me=%v", msg.Parent, msg.Me)
+		}
+	case msgKind_TransResult:
+		var retCode int
+		var ud interface{}
+		if msg.Result != nil {
+			retCode, ud = msg.Result.RetCode, msg.Result.RetFiels
+		}
+		if !transact.ProcessTransResult(msg.Parent.TId, msg.Me.TId, retCode, ud) {
+			logger.Logger.Tracef("txrpc ProcessTransResult failed, parent=%v me=%v", msg.Parent, msg.Me)
+		}
+	case msgKind_TransCmd:
+		if !transact.ProcessTransCmd(msg.Me.TId, msg.Cmd) {
+			logger.Logger.Tracef("txrpc ProcessTransCmd failed, tnp=%v cmd=%v", msg.Me, msg.Cmd)
+		}
+	case msgKind_TransQuery:
+		transact.ProcessTransQuery(msg.Parent.TId, msg.Me)
+	}
+}
@@ -0,0 +1,188 @@
+package txrpc
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/acoderup/goserver.v1/core"
+	"github.com/acoderup/goserver.v1/core/basic"
+	"github.com/acoderup/goserver.v1/core/netlib"
+	"github.com/acoderup/goserver.v1/core/transact"
+)
+
+var testExecutor = newTestExecutor()
+
+func newTestExecutor() *basic.Object {
+	o := basic.NewObject(1000, "txrpc-test", basic.Options{MaxDone: 1024, QueueBacklog: 1024}, nil)
+	core.LaunchChild(o)
+	return o
+}
+
+func newTestSkeleton(area, skeleton int, ch Channel, recv chan *rpcMessage) *Skeleton {
+	sk := NewSkeleton(area, skeleton, ch)
+	sk.Executor = testExecutor
+	sk.ResendInterval = 20 * time.Millisecond
+	sk.dispatch = func(msg *rpcMessage) { recv <- msg }
+	return sk
+}
+
+func waitMessage(t *testing.T, recv chan *rpcMessage, kind int) *rpcMessage {
+	select {
+	case msg := <-recv:
+		if msg.Kind != kind {
+			t.Fatalf("message kind=%v, want %v", msg.Kind, kind)
+		}
+		return msg
+	case <-time.After(3 * time.Second):
+		t.Fatalf("wait message kind %v timeout", kind)
+	}
+	return nil
+}
+
+func waitAcked(t *testing.T, sk *Skeleton) {
+	deadline := time.Now().Add(3 * time.Second)
+	for sk.PendingCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("skeleton [%v] pending=%v", sk.Addr(), sk.PendingCount())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newTnp(tid int64, area, skeleton int) *transact.TransNodeParam {
+	return &transact.TransNodeParam{
+		TId:        transact.TransNodeID(tid),
+		AreaID:     area,
+		SkeletonID: skeleton,
+		TimeOut:    time.Minute,
+		ExpiresTs:  time.Now().Add(time.Minute).UnixNano(),
+	}
+}
+
+func TestAddrOfTid(t *testing.T) {
+	tid := transact.TransNodeID(int64(3)<<48 | int64(7)<<32 | 12345)
+	if addr := AddrOfTid(tid); addr != (NodeAddr{AreaID: 3, SkeletonID: 7}) {
+		t.Fatalf("AddrOfTid=%v", addr)
+	}
+	if addr := addrOfParam(&transact.TransNodeParam{TId: tid}); addr.SkeletonID != 7 {
+		t.Fatalf("addrOfParam=%v", addr)
+	}
+}
+
+func TestHubResendAndDedup(t *testing.T) {
+	hub := NewHub()
+	recvA := make(chan *rpcMessage, 16)
+	recvB := make(chan *rpcMessage, 16)
+	a := newTestSkeleton(1, 1, hub.Channel(), recvA)
+	b := newTestSkeleton(1, 2, hub.Channel(), recvB)
+	if err := a.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if err := b.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	hub.SetDown(b.Addr(), true)
+	parent := newTnp(int64(1)<<48|int64(1)<<32|1, 1, 1)
+	child := newTnp(int64(1)<<48|int64(1)<<32|2, 1, 2)
+	a.SendTransStart(parent, child, nil)
+	time.Sleep(50 * time.Millisecond)
+	if a.PendingCount() != 1 {
+		t.Fatalf("message should be pending while peer is down, pending=%v", a.PendingCount())
+	}
+	hub.SetDown(b.Addr(), false)
+	msg := waitMessage(t, recvB, msgKind_TransStart)
+	if msg.Me.TId != child.TId || msg.Parent.TId != parent.TId {
+		t.Fatalf("start message mismatch: %v", msg)
+	}
+	waitAcked(t, a)
+
+	//子事务返回结果，按父节点的地址路由
+	b.SendTransResult(parent, child, &transact.TransResult{RetCode: transact.TransResult_Success})
+	if msg = waitMessage(t, recvA, msgKind_TransResult); msg.Me.TId != child.TId {
+		t.Fatalf("result message mismatch: %v", msg)
+	}
+	waitAcked(t, b)
+
+	//重复的消息只处理一次
+	data, _ := encodeMessage(&rpcMessage{Kind: msgKind_TransCmd, From: a.Addr(), Epoch: 1, Seq: 99, Me: child, Cmd: transact.TransCmd_Commit})
+	b.OnReceive(data)
+	b.OnReceive(data)
+	waitMessage(t, recvB, msgKind_TransCmd)
+	select {
+	case msg = <-recvB:
+		t.Fatalf("duplicate message dispatched: %v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	recv := make(chan *rpcMessage, 16)
+	sk := newTestSkeleton(2, 1, NewLoopbackChannel(), recv)
+	if err := sk.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer sk.Close()
+	sk.SendCmdToTransNode(newTnp(int64(2)<<48|int64(1)<<32|1, 2, 1), transact.TransCmd_RollBack)
+	if msg := waitMessage(t, recv, msgKind_TransCmd); msg.Cmd != transact.TransCmd_RollBack {
+		t.Fatalf("cmd=%v", msg.Cmd)
+	}
+	waitAcked(t, sk)
+}
+
+func TestNetChannel(t *testing.T) {
+	recvA := make(chan *rpcMessage, 16)
+	recvB := make(chan *rpcMessage, 16)
+	chA := NewNetChannel(netlib.SessionConfig{Id: 200, Name: "txrpc-a", Ip: "127.0.0.1"})
+	chB := NewNetChannel(netlib.SessionConfig{Id: 201, Name: "txrpc-b", Ip: "127.0.0.1"})
+	a := newTestSkeleton(3, 1, chA, recvA)
+	b := newTestSkeleton(3, 2, chB, recvB)
+	if err := a.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if err := b.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	peer := func(id int, sk *Skeleton, ch *NetChannel) Peer {
+		_, port, _ := net.SplitHostPort(ch.ListenAddr())
+		p := Peer{AreaID: sk.GetAreaID(), SkeletonID: sk.GetSkeletonID()}
+		p.Id, p.Ip, p.ReconnInterval = id, "127.0.0.1", 20
+		p.Port, _ = strconv.Atoi(port)
+		return p
+	}
+	//先发送，连接建立后重发
+	child := newTnp(int64(3)<<48|int64(2)<<32|1, 3, 2)
+	a.SendTransStart(newTnp(int64(3)<<48|int64(1)<<32|1, 3, 1), child, nil)
+	if err := chA.AddPeer(peer(202, b, chB)); err != nil {
+		t.Fatal(err)
+	}
+	if err := chB.AddPeer(peer(203, a, chA)); err != nil {
+		t.Fatal(err)
+	}
+	if msg := waitMessage(t, recvB, msgKind_TransStart); msg.Me.TId != child.TId {
+		t.Fatalf("start message mismatch: %v", msg)
+	}
+	waitAcked(t, a)
+}
+
+func TestNetChannelCloseUnregistersHandler(t *testing.T) {
+	ch := NewNetChannel(netlib.SessionConfig{Id: 210, Name: "txrpc-close", Ip: "127.0.0.1"})
+	sk := newTestSkeleton(3, 5, ch, make(chan *rpcMessage, 1))
+	if err := sk.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if netlib.GetSessionHandler(ch.handler) == nil {
+		t.Fatal("session handler not registered")
+	}
+	sk.Close()
+	if netlib.GetSessionHandler(ch.handler) != nil {
+		t.Fatal("session handler not unregistered on Close")
+	}
+}
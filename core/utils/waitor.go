@@ -34,15 +34,21 @@ func (w *Waitor) Wait(name string) {
 	}
 	cnt := atomic.LoadInt32(&w.waiters)
 	logger.Logger.Debugf("(w *Waitor)(%v:%p) Waiter(%v) waiters(%v)", w.name, w, name, cnt)
-	for w.counter > 0 {
+	for atomic.LoadInt32(&w.counter) > 0 {
 		dname := <-w.c
-		v = atomic.AddInt32(&w.counter, -1)
 		cnt = atomic.LoadInt32(&w.counter)
 		logger.Logger.Debugf("(w *Waitor)(%v:%p) Waiter(%v) after(%v)done! counter(%v)", w.name, w, name, dname, cnt)
 	}
 }
 
+// Done 计数减一并通知等待者
+// 短生命周期的Object(例如网络会话)会频繁调用Done，这里不能因为没有等待者而阻塞
 func (w *Waitor) Done(name string) {
-	w.c <- name
+	atomic.AddInt32(&w.counter, -1)
+	select {
+	case w.c <- name:
+	default:
+		//通道已满说明等待者还有未处理的通知，它会重新检查计数
+	}
 	logger.Logger.Debugf("(w *Waitor)(%v:%p) Done(%v)!!!", w.name, w, name)
 }
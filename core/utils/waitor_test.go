@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaitorDoneWithoutWaiter(t *testing.T) {
+	w := NewWaitor("test")
+	done := make(chan struct{})
+	go func() {
+		//没有等待者时Done不能阻塞，即使超过通道的容量
+		for i := 0; i < 100; i++ {
+			w.Add("obj", 1)
+			w.Done("obj")
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Done blocked without waiter")
+	}
+	w.Wait("main")
+}
+
+func TestWaitorWait(t *testing.T) {
+	w := NewWaitor("test")
+	w.Add("a", 1)
+	w.Add("b", 1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		w.Done("a")
+		time.Sleep(10 * time.Millisecond)
+		w.Done("b")
+	}()
+	returned := make(chan struct{})
+	go func() {
+		w.Wait("main")
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Fatal("Wait not returned after all Done")
+	}
+}
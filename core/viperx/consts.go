@@ -48,3 +48,8 @@ var data = `{
     "SlowMS": 500
   }
 }`
+
+// BuiltinConfig 内置的基础配置
+func BuiltinConfig() []byte {
+	return []byte(data)
+}
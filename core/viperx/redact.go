@@ -0,0 +1,70 @@
+package viperx
+
+import (
+	"strings"
+	"sync"
+)
+
+// RedactedValue 敏感配置输出时的替代值
+const RedactedValue = "******"
+
+var (
+	secretLock sync.RWMutex
+	//key包含这些词(不区分大小写)时认为是敏感配置
+	secretWords = []string{"password", "passwd", "pwd", "secret", "token", "credential", "privatekey", "apikey", "accesskey"}
+)
+
+// RegisteSecretWord 增加敏感配置的关键词
+func RegisteSecretWord(words ...string) {
+	secretLock.Lock()
+	defer secretLock.Unlock()
+	for _, w := range words {
+		secretWords = append(secretWords, strings.ToLower(w))
+	}
+}
+
+// IsSecretKey 判断配置项是否敏感
+func IsSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	secretLock.RLock()
+	defer secretLock.RUnlock()
+	for _, w := range secretWords {
+		if strings.Contains(key, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact 复制配置，敏感配置项的值替换为RedactedValue
+func Redact(settings map[string]interface{}) map[string]interface{} {
+	ret := make(map[string]interface{}, len(settings))
+	for k, v := range settings {
+		switch {
+		case IsSecretKey(k):
+			if v == nil || v == "" {
+				ret[k] = v
+			} else {
+				ret[k] = RedactedValue
+			}
+		default:
+			ret[k] = redactValue(v)
+		}
+	}
+	return ret
+}
+
+func redactValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		return Redact(vv)
+	case []interface{}:
+		list := make([]interface{}, len(vv))
+		for i, item := range vv {
+			list[i] = redactValue(item)
+		}
+		return list
+	default:
+		return v
+	}
+}
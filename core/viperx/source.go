@@ -0,0 +1,143 @@
+package viperx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Source 分层的配置来源，优先级从低到高:
+//  1. Defaults 功能包内置的默认配置
+//  2. 基础配置 Data，为空时读取基础文件 Name.Type
+//  3. 环境覆盖文件 Name.<env>.Type，按Envs的顺序覆盖，Envs为空时取环境变量<EnvPrefix>_ENV，逗号分隔
+//  4. 环境变量 <EnvPrefix>_<PACKAGE>_<KEY>，例如 GOSERVER_SIGNAL_STOPDEADLINE=3000，嵌套的key用_连接
+//  5. Sets 命令行参数，形如 signal.stopdeadline=3000
+//
+// 后面的层只覆盖它设置的项，map按key合并；环境变量和命令行参数的值可以是json，不是json时当作字符串
+type Source struct {
+	Name      string
+	Type      string
+	Data      []byte
+	Envs      []string
+	EnvPrefix string
+	Sets      []string
+	Defaults  map[string]interface{}
+}
+
+// envList 环境覆盖的名字
+func (this *Source) envList() []string {
+	if len(this.Envs) > 0 || this.EnvPrefix == "" {
+		return this.Envs
+	}
+	var envs []string
+	for _, e := range strings.Split(os.Getenv(this.EnvPrefix+"_ENV"), ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			envs = append(envs, e)
+		}
+	}
+	return envs
+}
+
+// Files 实际读取的配置文件，基础文件在前
+func (this *Source) Files() []string {
+	var files []string
+	if this.Name == "" {
+		return files
+	}
+	if len(this.Data) == 0 {
+		if file := FindFile(this.Name, this.Type); file != "" {
+			files = append(files, file)
+		}
+	}
+	for _, env := range this.envList() {
+		if file := FindFile(this.Name+"."+env, this.Type); file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// Load 按优先级合并所有的层
+func (this *Source) Load() (*viper.Viper, error) {
+	filetype := this.Type
+	if filetype == "" {
+		filetype = "json"
+	}
+	vp := viper.New()
+	vp.SetConfigType(filetype)
+	if len(this.Defaults) > 0 {
+		if err := vp.MergeConfigMap(this.Defaults); err != nil {
+			return nil, fmt.Errorf("Error while merging defaults: %v", err)
+		}
+	}
+
+	if len(this.Data) > 0 {
+		if err := mergeBuffer(vp, "built-in config", this.Data); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range this.Files() {
+		buf, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("Error while reading config file %s: %v", file, err)
+		}
+		if err = mergeBuffer(vp, file, buf); err != nil {
+			return nil, err
+		}
+	}
+
+	if this.EnvPrefix != "" {
+		prefix := strings.ToUpper(this.EnvPrefix) + "_"
+		for _, kv := range os.Environ() {
+			idx := strings.Index(kv, "=")
+			if idx <= 0 || !strings.HasPrefix(kv[:idx], prefix) || kv[:idx] == prefix+"ENV" {
+				continue
+			}
+			key := strings.ReplaceAll(strings.ToLower(kv[len(prefix):idx]), "_", ".")
+			if err := mergeValue(vp, key, kv[idx+1:]); err != nil {
+				return nil, fmt.Errorf("env %s: %v", kv[:idx], err)
+			}
+		}
+	}
+
+	for _, set := range this.Sets {
+		idx := strings.Index(set, "=")
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid config override %q, want key=value", set)
+		}
+		if err := mergeValue(vp, strings.TrimSpace(set[:idx]), set[idx+1:]); err != nil {
+			return nil, fmt.Errorf("config override %s: %v", set, err)
+		}
+	}
+	return vp, nil
+}
+
+func mergeBuffer(vp *viper.Viper, name string, buf []byte) error {
+	if configFileEH != nil && configFileEH.IsCipherText(buf) {
+		buf = configFileEH.Decrypt(buf)
+	}
+	if err := vp.MergeConfig(bytes.NewReader(buf)); err != nil {
+		return fmt.Errorf("Error while reading config file %s: %v", name, err)
+	}
+	return nil
+}
+
+// mergeValue 把 a.b.c=value 合并到配置中
+func mergeValue(vp *viper.Viper, key, value string) error {
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		v = value
+	}
+	path := strings.Split(strings.ToLower(key), ".")
+	for i := len(path) - 1; i >= 0; i-- {
+		if path[i] == "" {
+			return fmt.Errorf("invalid key %q", key)
+		}
+		v = map[string]interface{}{path[i]: v}
+	}
+	return vp.MergeConfigMap(v.(map[string]interface{}))
+}
@@ -0,0 +1,71 @@
+package viperx
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSourceLayers(t *testing.T) {
+	t.Chdir(t.TempDir())
+	files := map[string]string{
+		"app.json":       `{"net": {"port": 1000, "host": "base", "tls": {"on": false}}, "db": {"password": "p@ss", "user": "root"}}`,
+		"app.prod.json":  `{"net": {"port": 2000, "tls": {"on": true}}}`,
+		"app.local.json": `{"net": {"port": 3000}}`,
+	}
+	for name, body := range files {
+		if err := os.WriteFile(name, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GSTEST_ENV", "prod,local")
+	t.Setenv("GSTEST_NET_PORT", "4000")
+	t.Setenv("GSTEST_NET_HOST", "env")
+	src := &Source{
+		Name:      "app",
+		Type:      "json",
+		EnvPrefix: "GSTEST",
+		Sets:      []string{"net.host=flag", "net.tags=[\"a\",\"b\"]"},
+		Defaults:  map[string]interface{}{"net": map[string]interface{}{"timeout": 30, "port": 1}},
+	}
+	if n := len(src.Files()); n != 3 {
+		t.Fatalf("files=%v", src.Files())
+	}
+	vp, err := src.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]interface{}{
+		"net.timeout": 30,     //默认值
+		"db.user":     "root", //基础文件
+		"net.tls.on":  true,   //环境覆盖文件
+		"net.port":    "4000", //环境变量覆盖环境文件
+		"net.host":    "flag", //命令行覆盖环境变量
+	}
+	for key, want := range cases {
+		if got := vp.Get(key); got != want && vp.GetString(key) != want {
+			t.Errorf("%v=%v want %v", key, got, want)
+		}
+	}
+	if vp.GetInt("net.port") != 4000 {
+		t.Errorf("net.port=%v", vp.Get("net.port"))
+	}
+	if tags := vp.GetStringSlice("net.tags"); len(tags) != 2 {
+		t.Errorf("net.tags=%v", tags)
+	}
+	if vp.IsSet("env") {
+		t.Error("GSTEST_ENV merged as config")
+	}
+
+	red := Redact(vp.AllSettings())
+	db := red["db"].(map[string]interface{})
+	if db["password"] != RedactedValue || db["user"] != "root" {
+		t.Fatalf("redacted db=%v", db)
+	}
+	if vp.GetString("db.password") != "p@ss" {
+		t.Fatal("redact modified the source settings")
+	}
+
+	if _, err = (&Source{Name: "app", Type: "json", Sets: []string{"novalue"}}).Load(); err == nil {
+		t.Fatal("invalid override accepted")
+	}
+}
@@ -32,9 +32,18 @@ func RegisterConfigEncryptor(h ConfigFileEncryptorHook) {
 // name: 配置文件名,不带后缀
 // filetype: 配置文件类型，如json、yaml、ini等
 func GetViper(name, filetype string) *viper.Viper {
+	vp, err := ReadViper(name, filetype)
+	if err != nil {
+		panic(err.Error())
+	}
+	return vp
+}
+
+// ReadViper 与GetViper相同，出错时返回错误，用于重新加载配置
+func ReadViper(name, filetype string) (*viper.Viper, error) {
 	buf, err := ReadFile(name, filetype)
 	if err != nil {
-		panic(fmt.Sprintf("Error while reading config file %s: %v", name+filetype, err))
+		return nil, fmt.Errorf("Error while reading config file %s: %v", name+filetype, err)
 	}
 
 	if configFileEH != nil {
@@ -47,10 +56,22 @@ func GetViper(name, filetype string) *viper.Viper {
 	vp.SetConfigName(name)
 	vp.SetConfigType(filetype)
 	if err = vp.ReadConfig(bytes.NewReader(buf)); err != nil {
-		panic(fmt.Sprintf("Error while reading config file %s: %v", name+filetype, err))
+		return nil, fmt.Errorf("Error while reading config file %s: %v", name+filetype, err)
 	}
-	return vp
+	return vp, nil
+}
+
+// FindFile 返回配置文件所在的路径，找不到时返回空
+func FindFile(name, filetype string) string {
+	for _, v := range paths {
+		file := fmt.Sprintf("%s/%s.%s", v, name, filetype)
+		if _, err := os.Stat(file); err == nil {
+			return file
+		}
+	}
+	return ""
 }
+
 func GetViperByString() *viper.Viper {
 	//buf, err := ReadFile(name, filetype)
 	//if err != nil {
@@ -74,11 +95,8 @@ func GetViperByString() *viper.Viper {
 	return vp
 }
 func ReadFile(name, filetype string) ([]byte, error) {
-	for _, v := range paths {
-		file := fmt.Sprintf("%s/%s.%s", v, name, filetype)
-		if _, err := os.Stat(file); err == nil {
-			return os.ReadFile(file)
-		}
+	if file := FindFile(name, filetype); file != "" {
+		return os.ReadFile(file)
 	}
 	return nil, nil
 }